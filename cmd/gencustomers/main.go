@@ -0,0 +1,65 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/IllicLanthresh/TeamworkGoTests/pkg/customerGenerator"
+	"os"
+)
+
+func main() {
+	defaults := customerGenerator.DefaultOptions()
+	rows := flag.Int("rows", defaults.Rows, "number of customers to generate")
+	seed := flag.Int64("seed", defaults.Seed, "seed of the random generator, the same seed always generates the same file")
+	format := flag.String("format", defaults.Format, "output format: csv or ndjson")
+	domains := flag.Int("domains", defaults.Domains, "number of different email domains")
+	distribution := flag.String("distribution", defaults.Distribution, "how customers are spread over domains: uniform or zipf")
+	zipfExponent := flag.Float64("zipf-exponent", defaults.ZipfExponent, "exponent of the zipf distribution, greater than 1, higher values concentrate customers in fewer domains")
+	invalidRatio := flag.Float64("invalid-ratio", defaults.InvalidRatio, "ratio of customers with an invalid email address")
+	duplicateRatio := flag.Float64("duplicate-ratio", defaults.DuplicateRatio, "ratio of customers repeating the email address of a previous one")
+	idnRatio := flag.Float64("idn-ratio", defaults.IdnRatio, "ratio of domains with non ASCII characters")
+	encoding := flag.String("encoding", defaults.Encoding, "output encoding: utf-8, utf-8-bom, utf-16 or latin1")
+	output := flag.String("o", "", "file to write, standard output by default")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags]\n\nGenerates a reproducible synthetic customers file, e.g. the 1m rows benchmark dataset with\n\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "\tgo run ./cmd/gencustomers -rows 1000000 -domains 10000 -o test/data/importer/emails-1m.csv\n\nFlags:\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() > 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	w := os.Stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		w = file
+	}
+
+	err := customerGenerator.Generate(w, customerGenerator.Options{
+		Rows:           *rows,
+		Seed:           *seed,
+		Format:         *format,
+		Domains:        *domains,
+		Distribution:   *distribution,
+		ZipfExponent:   *zipfExponent,
+		InvalidRatio:   *invalidRatio,
+		DuplicateRatio: *duplicateRatio,
+		IdnRatio:       *idnRatio,
+		Encoding:       *encoding,
+	})
+	if w != os.Stdout {
+		if closeErr := w.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,389 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/IllicLanthresh/TeamworkGoTests/internal/customerimporter"
+	"github.com/IllicLanthresh/TeamworkGoTests/pkg/metrics"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"strings"
+	"time"
+)
+
+// hmacKeyEnv is the environment variable holding the key for -export-hashed, so it doesn't show up in the process list
+const hmacKeyEnv = "CUSTOMER_HMAC_KEY"
+
+const countUsage = `Usage: %[1]s [count] [flags] customers.csv...
+
+Counts the customers of each email domain in csv files with a headers row, sorted by domain.
+Rows with an invalid email address or which can't be parsed are rejected and logged to stderr.
+Inputs can be - for standard input, .csv.gz files, directories holding csv files and glob patterns, several of them
+are counted concurrently and added up into a single result.
+
+Examples:
+  %[1]s customers.csv
+  gunzip -c customers.csv.gz | %[1]s -
+  %[1]s -by-source -format csv 2023/ 'exports/*.csv.gz' extra.csv
+  %[1]s count -order count -format csv -o counts.csv customers.csv
+  %[1]s count -order count -percentage -cumulative -format markdown customers.csv
+  %[1]s count -format html -percentage -o report.html customers.csv
+  %[1]s count -delimiter ';' -comment '#' -email-column mail export.csv
+  %[1]s count -where 'gender == "Female"' -categories customers.csv
+  %[1]s count -by gender -format csv customers.csv
+  %[1]s count -max-rejected 0.01 customers.csv || echo "too many bad rows"
+  %[1]s count -metrics-file /var/lib/node_exporter/customers.prom customers.csv
+  %[1]s count -stats -format json -o counts.json customers.csv
+`
+
+const topUsage = `Usage: %[1]s top [flags] customers.csv...
+
+Shows the -k domains with the most customers in csv files with a headers row, ties sorted by domain. Inputs are
+given like for count.
+
+Examples:
+  %[1]s top customers.csv
+  %[1]s top -k 5 -percentage -cumulative -format markdown customers.csv
+  %[1]s top -where 'gender == "Female"' -only-categories freemail customers.csv
+`
+
+// runCount runs the count command, or the top one if `top` is set, which only takes the flags making sense for it
+func runCount(args []string, stdin io.Reader, stdout, stderr io.Writer, top bool) (err error) {
+	usage, name := countUsage, "count"
+	if top {
+		usage, name = topUsage, "top"
+	}
+	flags := newFlagSet(name, usage, stderr)
+	input := addInputFlags(flags)
+	output := flags.String("o", "", "file to write the results to, standard output by default")
+	format := flags.String("format", "", "output format: text, json, csv, ndjson, table, markdown or html, only table or csv for -by results; text or table by default")
+	percentage := flags.Bool("percentage", false, "add the share of all customers each domain has")
+	cumulative := flags.Bool("cumulative", false, "add the share of all customers held by each domain and the ones before it, best with -order count")
+	maxRejected := flags.Float64("max-rejected", 1, "fail with exit code 4 when a bigger fraction of the rows than this is rejected, 0 to fail on any")
+	showCategories := flags.Bool("categories", false, "print the category of each domain and the totals of each category")
+	onlyCategories := flags.String("only-categories", "", "comma separated categories to include, all of them by default")
+	excludeCategories := flags.String("exclude-categories", "", "comma separated categories to exclude")
+	fixTypos := flags.Bool("fix-typos", false, "merge the customers of domains which look like typos into the domain they meant")
+	typoConfidence := flags.Float64("typo-confidence", 0.8, "lowest confidence of the typo corrections merged with -fix-typos")
+	verifyMX := flags.Bool("verify-mx", false, "check whether each domain can receive email through its MX or A/AAAA records")
+	mxConcurrency := flags.Int("mx-concurrency", 16, "how many domains are checked at the same time with -verify-mx")
+	mxTimeout := flags.Duration("mx-timeout", 5*time.Second, "how long the lookups of each domain can take with -verify-mx")
+	kAnonymity := flags.Int("k-anonymity", 0, "suppress the domains with fewer customers than this")
+	bucketSuppressed := flags.Bool("bucket-suppressed", false, "add the customers of domains suppressed by -k-anonymity to an \"other\" domain")
+	noiseEpsilon := flags.Float64("noise-epsilon", 0, "add Laplace noise to every count, with this privacy budget (lower is noisier)")
+	noiseSeed := flags.Int64("noise-seed", 0, "seed for -noise-epsilon, a random one is used by default")
+	live := flags.Bool("live", false, "refresh the top domains in place on stderr while the file is being read")
+	liveEvery := flags.Duration("live-every", time.Second, "how often -live refreshes the output")
+	liveTop := flags.Int("live-top", 20, "how many domains -live shows, by customer count")
+	showStats := flags.Bool("stats", false, "print how the import went to stderr: rows, unique domains and addresses, throughput and peak memory; with -format json they're added to the output instead")
+	bySource := flags.Bool("by-source", false, "add the customers of each domain in every input to the results")
+	inputConcurrency := flags.Int("input-concurrency", 4, "how many inputs are read at the same time")
+	metricsFile := flags.String("metrics-file", "", "file to write the rows, bytes, domains and timings of the count to, in the Prometheus text format")
+
+	// Flags of only one of the commands, the top one always orders by count
+	var groupBy, networkKey, attributes, hmacKeyFile string
+	var ipv4Prefix, ipv6Prefix, limit int
+	var duplicates, canonicalize, typos, exportHashed bool
+	order := string(customerimporter.OrderByCount)
+	if top {
+		flags.IntVar(&limit, "k", 10, "how many domains to show")
+	} else {
+		flags.StringVar(&order, "order", string(customerimporter.OrderByDomain), "order of the domains: domain, count, reversed (labels, com.acme.mail) or tld")
+		flags.StringVar(&groupBy, "by", "", "split the customer count of each domain by the values of this column")
+		flags.StringVar(&networkKey, "network", "", "count customers per network of the IP addresses in this column instead of per email domain")
+		flags.IntVar(&ipv4Prefix, "ipv4-prefix", 24, "prefix length used to group IPv4 addresses with -network")
+		flags.IntVar(&ipv6Prefix, "ipv6-prefix", 48, "prefix length used to group IPv6 addresses with -network")
+		flags.BoolVar(&duplicates, "duplicates", false, "report the email addresses appearing in more than one row instead of counting")
+		flags.BoolVar(&canonicalize, "canonicalize", false, "strip tags and gmail dots from addresses when looking for -duplicates")
+		flags.StringVar(&attributes, "attributes", "", "comma separated columns reported for each row with -duplicates, all of them by default")
+		flags.BoolVar(&typos, "typos", false, "report the domains which look like typos of more popular ones instead of counting")
+		flags.BoolVar(&exportHashed, "export-hashed", false, "write every row as CSV with its email address replaced by a keyed HMAC instead of counting")
+		flags.StringVar(&hmacKeyFile, "hmac-key-file", "", "file holding the key for -export-hashed, the "+hmacKeyEnv+" environment variable is used otherwise")
+	}
+	if err := parseFlags(flags, args, 1, -1, "csv file paths"); err != nil {
+		return err
+	}
+
+	mode := "count"
+	switch {
+	case exportHashed, duplicates, networkKey != "":
+		mode = "report"
+	case groupBy != "":
+		mode = "by"
+	case typos:
+		mode = "typos"
+	}
+	if !validFormat(mode, *format) {
+		return usageError(fmt.Errorf("format %q can't be used for these results", *format))
+	}
+	if err := checkMaxRejected(*maxRejected); err != nil {
+		return err
+	}
+	if *maxRejected < 1 && mode != "count" && mode != "typos" {
+		return usageError(errors.New("-max-rejected can only be used when counting domains"))
+	}
+	if top && limit < 1 {
+		return usageError(fmt.Errorf("-k must be at least 1, not %d", limit))
+	}
+	if *inputConcurrency < 1 {
+		return usageError(fmt.Errorf("-input-concurrency must be at least 1, not %d", *inputConcurrency))
+	}
+	if *metricsFile != "" && mode != "count" && mode != "typos" {
+		return usageError(errors.New("-metrics-file can only be used when counting domains"))
+	}
+	ordering, err := customerimporter.ParseOrdering(order)
+	if err != nil {
+		return usageError(err)
+	}
+	options, err := input.importerOptions()
+	if err != nil {
+		return err
+	}
+	sortDomains := customerimporter.SortDomains
+	if *metricsFile != "" {
+		registry := metrics.NewRegistry()
+		importerMetrics := customerimporter.NewImporterMetrics(registry)
+		options = append(options, customerimporter.WithMetrics(importerMetrics))
+		sortDomains = importerMetrics.SortDomains
+		// Failed counts are worth recording too
+		defer func() {
+			if writeErr := writeOutput(*metricsFile, nil, registry.WriteText); writeErr != nil && err == nil {
+				err = fmt.Errorf("couldn't write metrics: %w", writeErr)
+			}
+		}()
+	}
+	paths, err := expandInputs(flags.Args())
+	if err != nil {
+		return err
+	}
+	combined := len(paths) > 1 || *bySource
+	if combined && (mode != "count" && mode != "typos" || *live) {
+		return usageError(errors.New("several inputs and -by-source can only be used when counting domains without -live"))
+	}
+	if *bySource && (mode != "count" || *fixTypos || *kAnonymity > 0 || *noiseEpsilon != 0) {
+		return usageError(errors.New("-by-source can't be used along with -typos, -fix-typos, -k-anonymity or -noise-epsilon"))
+	}
+	importers, err := customerimporter.NewCustomerImporters(paths, stdin, *input.emailColumn, options...)
+	if err != nil {
+		return usageError(err)
+	}
+	importer := importers[0]
+
+	if exportHashed {
+		key := []byte(os.Getenv(hmacKeyEnv))
+		if hmacKeyFile != "" {
+			if key, err = ioutil.ReadFile(hmacKeyFile); err != nil {
+				return exitError{code: exitUnreadableInput, err: err}
+			}
+		}
+		return writeOutput(*output, stdout, func(w io.Writer) error {
+			err := importer.ExportHashedRows(w, key)
+			var missingKey customerimporter.MissingHmacKey
+			if errors.As(err, &missingKey) {
+				return usageError(fmt.Errorf("%w, with -hmac-key-file or the %s environment variable", err, hmacKeyEnv))
+			}
+			return readingError(err)
+		})
+	}
+
+	if duplicates {
+		groups, err := importer.DuplicateCustomers(canonicalize, splitList(attributes)...)
+		if err != nil {
+			return readingError(err)
+		}
+		return writeOutput(*output, stdout, func(w io.Writer) error {
+			for _, group := range groups {
+				fmt.Fprintf(w, "%s(%d)", group.Address, len(group.Rows))
+				if len(group.DifferingColumns) > 0 {
+					fmt.Fprintf(w, " differs in %s", strings.Join(group.DifferingColumns, ", "))
+				}
+				fmt.Fprintln(w)
+				for _, row := range group.Rows {
+					fmt.Fprintf(w, "\tline %d: %s %s\n", row.Line, row.Address, strings.Join(row.Attributes, " "))
+				}
+			}
+			return nil
+		})
+	}
+
+	if networkKey != "" {
+		customerCountByNetwork, err := importer.CustomerCountByNetwork(networkKey, ipv4Prefix, ipv6Prefix)
+		if err != nil {
+			var prefixError customerimporter.InvalidPrefixLengthError
+			if errors.As(err, &prefixError) {
+				return usageError(err)
+			}
+			return readingError(err)
+		}
+		return writeOutput(*output, stdout, func(w io.Writer) error {
+			for _, network := range customerCountByNetwork {
+				fmt.Fprintf(w, "%s(%d)\n", network.Network, network.CustomerCount)
+			}
+			return nil
+		})
+	}
+
+	if groupBy != "" {
+		pivot, err := importer.CustomerCountByDomainGroupedBy(groupBy)
+		if err != nil {
+			return readingError(err)
+		}
+		return writeOutput(*output, stdout, func(w io.Writer) error {
+			if *format == "csv" {
+				return pivot.WriteCsv(w)
+			}
+			return pivot.WriteTable(w)
+		})
+	}
+
+	var customerCountByDomain []customerimporter.EmailDomain
+	var stats customerimporter.RunStats
+	if *live {
+		customerCountByDomain, stats, err = importer.CustomerCountByDomainLive(0, *liveEvery, *liveTop, printLiveSnapshot(stderr))
+	} else if combined {
+		customerCountByDomain, stats, err = customerimporter.CombineCustomerCounts(importers, *inputConcurrency)
+	} else {
+		customerCountByDomain, stats, err = importer.CustomerCountByDomainWithStats()
+	}
+	if *showStats && err == nil && (typos || *format != string(customerimporter.FormatJson)) {
+		printRunStats(stderr, stats)
+	}
+	if err != nil {
+		return readingError(err)
+	}
+	if typos {
+		err = writeOutput(*output, stdout, func(w io.Writer) error {
+			for _, suggestion := range customerimporter.SuggestTypoCorrections(customerCountByDomain, customerimporter.DefaultTypoOptions()) {
+				fmt.Fprintf(w, "%s(%d) -> %s(%d) distance %.1f confidence %.2f\n", suggestion.Domain, suggestion.CustomerCount,
+					suggestion.Correction, suggestion.CorrectionCount, suggestion.Distance, suggestion.Confidence)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		return checkRejected(stats, *maxRejected)
+	}
+	if *fixTypos {
+		suggestions := customerimporter.SuggestTypoCorrections(customerCountByDomain, customerimporter.DefaultTypoOptions())
+		customerCountByDomain = customerimporter.ApplyTypoCorrections(customerCountByDomain, suggestions, *typoConfidence)
+	}
+	if *noiseEpsilon != 0 {
+		seed := *noiseSeed
+		if seed == 0 {
+			seed = time.Now().UnixNano()
+		}
+		customerCountByDomain, err = customerimporter.AddLaplaceNoise(customerCountByDomain, *noiseEpsilon, rand.New(rand.NewSource(seed)))
+		if err != nil {
+			return usageError(err)
+		}
+	}
+	if *kAnonymity > 0 {
+		customerCountByDomain = customerimporter.SuppressSmallDomains(customerCountByDomain, *kAnonymity, *bucketSuppressed)
+	}
+	customerCountByDomain = customerimporter.FilterByCategory(customerCountByDomain, splitList(*onlyCategories), splitList(*excludeCategories))
+	customerCountByDomain = sortDomains(customerCountByDomain, ordering)
+	total := 0
+	for _, domain := range customerCountByDomain {
+		total += domain.CustomerCount
+	}
+	if top && len(customerCountByDomain) > limit {
+		customerCountByDomain = customerCountByDomain[:limit]
+	}
+	if *verifyMX {
+		checker := customerimporter.NewSystemDeliverabilityChecker(*mxConcurrency, *mxTimeout)
+		customerCountByDomain = checker.CheckDomains(context.Background(), customerCountByDomain)
+	}
+
+	outputFormat := customerimporter.FormatText
+	if *format != "" {
+		outputFormat = customerimporter.Format(*format)
+	}
+	formatOptions := customerimporter.FormatOptions{
+		Percentage:     *percentage,
+		Cumulative:     *cumulative,
+		Category:       *showCategories,
+		Deliverability: *verifyMX,
+		Total:          total,
+	}
+	if *bySource {
+		formatOptions.Sources = paths
+	}
+	formatOptions.Stats = &stats
+	formatOptions.JsonStats = *showStats
+	err = writeOutput(*output, stdout, func(w io.Writer) error {
+		if err := customerimporter.WriteDomains(w, customerCountByDomain, outputFormat, formatOptions); err != nil {
+			return err
+		}
+		if *showCategories && outputFormat == customerimporter.FormatText {
+			fmt.Fprintln(w)
+			for _, total := range customerimporter.CategoryTotals(customerCountByDomain) {
+				fmt.Fprintf(w, "%s: %d domains, %d customers\n", total.Category, total.DomainCount, total.CustomerCount)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	// The results are written even if too many rows were rejected, the exit code tells they can't be trusted
+	return checkRejected(stats, *maxRejected)
+}
+
+// validFormat tells whether results of the given mode can be written in `format`, an empty format being the default
+// one of every mode
+func validFormat(mode string, format string) bool {
+	switch mode {
+	case "count":
+		_, err := customerimporter.ParseFormat(format)
+		return format == "" || err == nil
+	case "by":
+		return format == "" || format == "table" || format == "csv"
+	default:
+		return format == "" || format == "text"
+	}
+}
+
+// printRunStats prints the summary of an import for -stats
+func printRunStats(w io.Writer, stats customerimporter.RunStats) {
+	fmt.Fprintf(w, "read path: %s\nrows read: %d\nrows rejected: %d\n", stats.ReadPath, stats.RowsRead, stats.RowsRejected)
+	fmt.Fprintf(w, "  malformed: %d\n  empty email: %d\n  invalid email: %d\n",
+		stats.Rejected.Malformed, stats.Rejected.EmptyEmail, stats.Rejected.InvalidEmail)
+	fmt.Fprintf(w, "unique domains: %d\nunique addresses: %d (estimated)\nbytes read: %s\n",
+		stats.UniqueDomains, stats.UniqueAddresses, formatBytes(float64(stats.BytesRead)))
+	fmt.Fprintf(w, "duration: %s\nthroughput: %.0f rows/s, %s/s\npeak memory: %s\n",
+		stats.Duration.Round(time.Microsecond), stats.RowsPerSecond, formatBytes(stats.BytesPerSecond), formatBytes(float64(stats.PeakMemory)))
+}
+
+// formatBytes formats a number of bytes in the biggest binary unit it holds at least one of
+func formatBytes(bytes float64) string {
+	units := []string{"B", "KiB", "MiB", "GiB", "TiB"}
+	unit := 0
+	for bytes >= 1024 && unit < len(units)-1 {
+		bytes /= 1024
+		unit++
+	}
+	if unit == 0 {
+		return fmt.Sprintf("%.0f %s", bytes, units[unit])
+	}
+	return fmt.Sprintf("%.1f %s", bytes, units[unit])
+}
+
+// printLiveSnapshot returns a callback for CustomerCountByDomainLive which redraws the top domains by customer count
+// in place on `w`, clearing the terminal before the complete result is printed
+func printLiveSnapshot(w io.Writer) func(snapshot customerimporter.DomainSnapshot) {
+	return func(snapshot customerimporter.DomainSnapshot) {
+		// Move the cursor home and clear the screen
+		fmt.Fprint(w, "\x1b[H\x1b[2J")
+		if snapshot.Complete {
+			return
+		}
+		fmt.Fprintf(w, "%d customers counted so far, top %d domains:\n", snapshot.CustomersCounted, len(snapshot.Domains))
+		for _, domain := range snapshot.Domains {
+			fmt.Fprintf(w, "%s(%d)\n", domain.Domain, domain.CustomerCount)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"github.com/IllicLanthresh/TeamworkGoTests/internal/customerimporter"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const diffUsage = `Usage: %[1]s diff [flags] before after
+
+Compares the customer count of every domain in two result files of the count or top commands, written in the text,
+json, csv or ndjson format. The format of each file is taken from its extension, .json, .ndjson, .csv or text for
+anything else, unless -input-format is given.
+
+Examples:
+  %[1]s diff january.txt february.txt
+  %[1]s diff -all -format csv january.csv february.csv
+`
+
+func runDiff(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	flags := newFlagSet("diff", diffUsage, stderr)
+	inputFormat := flags.String("input-format", "", "format of both result files: text, json, csv or ndjson, taken from their extensions by default")
+	output := flags.String("o", "", "file to write the changes to, standard output by default")
+	format := flags.String("format", "text", "output format: text, json or csv")
+	all := flags.Bool("all", false, "include the domains whose customer count didn't change")
+	if err := parseFlags(flags, args, 2, 2, "result files, before and after"); err != nil {
+		return err
+	}
+	if *format != "text" && *format != "json" && *format != "csv" {
+		return usageError(fmt.Errorf("format %q can't be used for a diff", *format))
+	}
+	// Only the formats ReadDomains parses can be read back
+	switch *inputFormat {
+	case "", "text", "json", "csv", "ndjson":
+	default:
+		return usageError(fmt.Errorf("input format %q can't be read, use text, json, csv or ndjson", *inputFormat))
+	}
+
+	var results [2][]customerimporter.EmailDomain
+	for i, path := range flags.Args() {
+		resultFormat := customerimporter.Format(*inputFormat)
+		if resultFormat == "" {
+			resultFormat = formatOfPath(path)
+		}
+		domains, err := readResultFile(path, resultFormat)
+		if err != nil {
+			return readingError(fmt.Errorf("couldn't read %s: %w", path, err))
+		}
+		results[i] = domains
+	}
+	diff := customerimporter.DiffDomains(results[0], results[1], *all)
+
+	return writeOutput(*output, stdout, func(w io.Writer) error {
+		switch *format {
+		case "json":
+			return diff.WriteJson(w)
+		case "csv":
+			return diff.WriteCsv(w)
+		}
+		return diff.WriteText(w)
+	})
+}
+
+// formatOfPath returns the format of a result file from its extension
+func formatOfPath(path string) customerimporter.Format {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return customerimporter.FormatJson
+	case ".ndjson", ".jsonl":
+		return customerimporter.FormatNdjson
+	case ".csv":
+		return customerimporter.FormatCsv
+	}
+	return customerimporter.FormatText
+}
+
+func readResultFile(path string, format customerimporter.Format) ([]customerimporter.EmailDomain, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return customerimporter.ReadDomains(file, format)
+}
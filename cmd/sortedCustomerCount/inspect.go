@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/IllicLanthresh/TeamworkGoTests/internal/customerimporter"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+const inspectUsage = `Usage: %[1]s inspect [flags] file.csv
+
+Guesses the delimiter and comment character of a csv file unless they're given, whether its first row is a headers
+row, and the type of the values of every column from a sample of its rows, along with the column holding email
+addresses. Handy to find the flags count needs for a file coming from somewhere else.
+
+Examples:
+  %[1]s inspect export.csv
+  %[1]s inspect -rows 100000 -format json export.csv
+`
+
+func runInspect(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	flags := newFlagSet("inspect", inspectUsage, stderr)
+	delimiter := flags.String("delimiter", "", "field delimiter of the csv file, a single ASCII character or \\t for tabs, guessed by default")
+	comment := flags.String("comment", "", "lines starting with this character are ignored, guessed by default")
+	sampleRows := flags.Int("rows", 1000, "how many rows to sample after the first one")
+	output := flags.String("o", "", "file to write the report to, standard output by default")
+	format := flags.String("format", "text", "output format: text or json")
+	if err := parseFlags(flags, args, 1, 1, "csv file path"); err != nil {
+		return err
+	}
+	if *format != "text" && *format != "json" {
+		return usageError(fmt.Errorf("format %q can't be used for an inspection report", *format))
+	}
+	if *sampleRows < 1 {
+		return usageError(fmt.Errorf("-rows must be at least 1, not %d", *sampleRows))
+	}
+	dialect, err := parseDialect(*delimiter, *comment)
+	if err != nil {
+		return usageError(err)
+	}
+	csvPath := flags.Arg(0)
+	if err := checkInputFile(csvPath); err != nil {
+		return err
+	}
+	if *delimiter == "" || *comment == "" {
+		sniffed, err := customerimporter.SniffDialect(csvPath)
+		if err != nil {
+			return readingError(err)
+		}
+		if *delimiter == "" {
+			dialect.Delimiter = sniffed.Delimiter
+		}
+		if *comment == "" {
+			dialect.Comment = sniffed.Comment
+		}
+	}
+	inspection, err := customerimporter.InspectCsv(csvPath, dialect, *sampleRows)
+	if err != nil {
+		return readingError(err)
+	}
+
+	return writeOutput(*output, stdout, func(w io.Writer) error {
+		if *format == "json" {
+			encoder := json.NewEncoder(w)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(inspection)
+		}
+		comment := "none"
+		if inspection.Comment != "" {
+			comment = strconv.Quote(inspection.Comment)
+		}
+		emailColumn := "none found"
+		if inspection.EmailColumn != "" {
+			emailColumn = inspection.EmailColumn
+		}
+		fmt.Fprintf(w, "delimiter: %s\ncomment: %s\nheaders row: %v\nrows sampled: %d, %d malformed\nemail column: %s\n\n",
+			strconv.Quote(inspection.Delimiter), comment, inspection.HasHeaders, inspection.RowsSampled, inspection.MalformedRows, emailColumn)
+		tabWriter := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tabWriter, "column\ttype\tempty\tdistinct\tmax length\tsamples")
+		for _, column := range inspection.Columns {
+			fmt.Fprintf(tabWriter, "%s\t%s\t%d\t%d\t%d\t%s\n", column.Name, column.Type, column.Empty, column.Distinct,
+				column.MaxLength, strings.Join(column.Samples, ", "))
+		}
+		return tabWriter.Flush()
+	})
+}
@@ -1,28 +1,382 @@
 package main
 
 import (
+	"bufio"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"github.com/IllicLanthresh/TeamworkGoTests/internal/customerimporter"
+	"github.com/IllicLanthresh/TeamworkGoTests/pkg/rowFilter"
+	"io"
 	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
 )
 
+const commandName = "sortedCustomerCount"
+
+// Exit codes, so scripts can tell apart what went wrong
+const (
+	exitFailure = 1
+	// exitUsage is returned for unknown commands and flags, invalid flag values and missing or extra arguments
+	exitUsage = 2
+	// exitUnreadableInput is returned when an input file, or any other file given in the flags, can't be read
+	exitUnreadableInput = 3
+	// exitDataQuality is returned when more rows than -max-rejected allows are rejected, after writing the results
+	exitDataQuality = 4
+)
+
+const exitCodesText = `Exit codes:
+  0  success
+  1  unexpected failure
+  2  usage error: unknown commands or flags, invalid flag values, missing or extra arguments
+  3  unreadable input: a file is missing, can't be read, or has no headers row or email column
+  4  data quality failure: more rows were rejected than -max-rejected allows, the results are written anyway
+`
+
+const usageText = `Usage: %[1]s [command] [flags] [arguments]
+
+Counts the customers of each email domain in a csv file with a headers row, and a few other things around it.
+
+Commands:
+  count     count the customers of each email domain, the default command
+  top       show the domains with the most customers
+  validate  check every row and report how many were rejected, along with how many domains were found
+  inspect   guess the dialect, headers and column types of a csv file
+  diff      compare the domain counts of two result files
+  sort      sort lines alphabetically with the radix sorter
+  serve     count uploaded customers over an HTTP API
+
+Run '%[1]s <command> -help' for the flags and examples of a command.
+
+` + exitCodesText
+
+// commands maps every command name to the function running it with the arguments following the name
+var commands = map[string]func(args []string, stdin io.Reader, stdout, stderr io.Writer) error{
+	"count": func(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+		return runCount(args, stdin, stdout, stderr, false)
+	},
+	"top": func(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+		return runCount(args, stdin, stdout, stderr, true)
+	},
+	"validate": runValidate,
+	"inspect":  runInspect,
+	"diff":     runDiff,
+	"sort":     runSort,
+	"serve":    runServe,
+}
+
 func main() {
-	if len(os.Args) > 2 {
-		panic("unexpected arguments")
-	} else if len(os.Args) != 2 {
-		panic("missing filepath")
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run runs the command named by the first argument, count if it isn't a command name, writing the results to
+// `stdout` unless -o is given and any errors to `stderr`, returning the exit code
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	command := commands["count"]
+	if len(args) > 0 {
+		switch args[0] {
+		case "help", "-h", "-help", "--help":
+			fmt.Fprintf(stderr, usageText, commandName)
+			return 0
+		}
+		if named, found := commands[args[0]]; found {
+			command, args = named, args[1:]
+		} else if isUnknownCommand(args[0]) {
+			return report(stderr, usageError(fmt.Errorf("unknown command %q", args[0])))
+		}
+	}
+	return report(stderr, command(args, stdin, stdout, stderr))
+}
+
+// isUnknownCommand tells whether the first argument, which isn't a command name, is a mistyped one rather than a
+// path for the count command: a plain word which isn't an existing file
+func isUnknownCommand(arg string) bool {
+	for _, char := range arg {
+		if (char < 'a' || char > 'z') && (char < 'A' || char > 'Z') {
+			return false
+		}
+	}
+	_, err := os.Stat(arg)
+	return err != nil
+}
+
+// exitError is an error ending the program with a specific exit code, errors without one exit with exitFailure.
+// A nil `err` means the error was already reported, by the flag package
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e exitError) Error() string {
+	if e.err == nil {
+		return "exit status " + strconv.Itoa(e.code)
+	}
+	return e.err.Error()
+}
+
+func (e exitError) Unwrap() error {
+	return e.err
+}
+
+func usageError(err error) error {
+	return exitError{code: exitUsage, err: err}
+}
+
+// readingError gives errors returned while reading input files the exitUnreadableInput code, when it's the file
+// which couldn't be read as expected
+func readingError(err error) error {
+	var pathError *os.PathError
+	var parseError *csv.ParseError
+	var keyNotFound customerimporter.KeyNotFoundError
+	var invalidLine customerimporter.InvalidResultLineError
+	var syntaxError *json.SyntaxError
+	var typeError *json.UnmarshalTypeError
+	if errors.As(err, &pathError) || errors.As(err, &parseError) || errors.As(err, &keyNotFound) ||
+		errors.As(err, &invalidLine) || errors.As(err, &syntaxError) || errors.As(err, &typeError) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, gzip.ErrHeader) ||
+		errors.Is(err, gzip.ErrChecksum) {
+		return exitError{code: exitUnreadableInput, err: err}
+	}
+	return err
+}
+
+// report prints `err` to `stderr`, returning the exit code it stands for
+func report(stderr io.Writer, err error) int {
+	if err == nil {
+		return 0
+	}
+	code := exitFailure
+	var exit exitError
+	if errors.As(err, &exit) {
+		code = exit.code
+		if exit.err == nil {
+			return code
+		}
+	}
+	var parseError *rowFilter.ParseError
+	if errors.As(err, &parseError) {
+		fmt.Fprintf(stderr, "%s\n%s\n", parseError, parseError.Pointer())
+		return code
+	}
+	fmt.Fprintf(stderr, "%s: %s\n", commandName, err)
+	if code == exitUsage {
+		fmt.Fprintf(stderr, "Run '%s -help' for usage.\n", commandName)
+	}
+	return code
+}
+
+// newFlagSet returns the flags of a command, printing `usage` followed by the flags and the exit codes on -help
+func newFlagSet(name string, usage string, stderr io.Writer) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), usage, commandName)
+		fmt.Fprintln(flags.Output(), "\nFlags:")
+		flags.PrintDefaults()
+		fmt.Fprintf(flags.Output(), "\n%s", exitCodesText)
+	}
+	return flags
+}
+
+// parseFlags parses the arguments of a command, checking it got between `minArgs` and `maxArgs` arguments after the
+// flags, -1 for no maximum. `argsName` names the arguments in the error for missing ones
+func parseFlags(flags *flag.FlagSet, args []string, minArgs, maxArgs int, argsName string) error {
+	if err := flags.Parse(args); err == flag.ErrHelp {
+		return exitError{code: 0}
+	} else if err != nil {
+		return exitError{code: exitUsage}
 	}
-	csvPath := os.Args[1]
-	importer, err := customerimporter.NewCsvCustomerImporter(csvPath, "email")
+	if maxArgs != -1 && flags.NArg() > maxArgs {
+		return usageError(fmt.Errorf("unexpected arguments %q", flags.Args()[maxArgs:]))
+	} else if flags.NArg() < minArgs {
+		return usageError(fmt.Errorf("missing %s", argsName))
+	}
+	return nil
+}
+
+// inputFlags are the flags shared by the commands reading a customers csv file
+type inputFlags struct {
+	emailColumn    *string
+	delimiter      *string
+	comment        *string
+	where          *string
+	categoriesFile *string
+	memoryBudget   *int64
+	workers        *int
+	mmap           *bool
+}
+
+func addInputFlags(flags *flag.FlagSet) *inputFlags {
+	return &inputFlags{
+		emailColumn:    flags.String("email-column", "email", "name of the column holding email addresses"),
+		delimiter:      flags.String("delimiter", ",", "field delimiter of the csv file, a single ASCII character or \\t for tabs"),
+		comment:        flags.String("comment", "", "lines starting with this character are ignored, none by default"),
+		where:          flags.String("where", "", "only count rows matching this filter expression, e.g. 'gender == \"Female\"'"),
+		categoriesFile: flags.String("categories-file", "", "file with extra domain classification rules, overriding the embedded ones"),
+		memoryBudget:   flags.Int64("memory-budget", 64, "MiB of memory used to hold unique domains and addresses before spilling them to disk"),
+		workers:        flags.Int("workers", runtime.NumCPU(), "how many goroutines validate and count addresses"),
+		mmap:           flags.Bool("mmap", false, "map the file into memory instead of reading it, regular files on Linux only"),
+	}
+}
+
+// importerOptions returns the importer options standing for the flags
+func (f *inputFlags) importerOptions() ([]customerimporter.ImporterOption, error) {
+	dialect, err := parseDialect(*f.delimiter, *f.comment)
 	if err != nil {
-		panic(err)
+		return nil, usageError(err)
+	}
+	options := []customerimporter.ImporterOption{
+		customerimporter.WithMemoryBudget(*f.memoryBudget << 20),
+		customerimporter.WithWorkers(*f.workers),
+		customerimporter.WithDialect(dialect),
+	}
+	if *f.where != "" {
+		options = append(options, customerimporter.WithFilter(*f.where))
 	}
+	if *f.mmap {
+		options = append(options, customerimporter.WithMemoryMap())
+	}
+	if *f.categoriesFile != "" {
+		option, err := classifierOption(*f.categoriesFile)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, option)
+	}
+	return options, nil
+}
 
-	customerCountByDomain, err := importer.CustomerCountByDomain()
+// classifierOption loads the domain classification rules in `path` on top of the embedded ones
+func classifierOption(path string) (customerimporter.ImporterOption, error) {
+	rules, err := os.Open(path)
+	if err != nil {
+		return nil, exitError{code: exitUnreadableInput, err: err}
+	}
+	classifier := customerimporter.NewDomainClassifier()
+	err = classifier.Load(rules)
+	_ = rules.Close()
 	if err != nil {
-		panic(err)
+		return nil, exitError{code: exitUnreadableInput, err: fmt.Errorf("couldn't load %s: %w", path, err)}
+	}
+	return customerimporter.WithDomainClassifier(classifier), nil
+}
+
+// expandInputs expands the directories and glob patterns in the input arguments, checking every file can be read.
+// Standard input, "-", can only be given once
+func expandInputs(args []string) ([]string, error) {
+	paths, err := customerimporter.ExpandCsvPaths(args)
+	if errors.Is(err, filepath.ErrBadPattern) {
+		return nil, usageError(err)
+	} else if err != nil {
+		return nil, exitError{code: exitUnreadableInput, err: err}
+	}
+	readsStdin := false
+	for _, path := range paths {
+		if path != customerimporter.StdinPath {
+			if err := checkInputFile(path); err != nil {
+				return nil, err
+			}
+		} else if readsStdin {
+			return nil, usageError(errors.New("standard input can only be read once"))
+		} else {
+			readsStdin = true
+		}
+	}
+	return paths, nil
+}
+
+// checkInputFile checks `path` is a file which exists, so it's reported as unreadable input instead of as a usage
+// error by the importer
+func checkInputFile(path string) error {
+	if info, err := os.Stat(path); err != nil {
+		return exitError{code: exitUnreadableInput, err: err}
+	} else if info.IsDir() {
+		return exitError{code: exitUnreadableInput, err: fmt.Errorf("%s is a directory", path)}
+	}
+	return nil
+}
+
+// parseDialect parses the -delimiter and -comment flags, which take a single ASCII character, or \t for tabs
+func parseDialect(delimiter, comment string) (customerimporter.Dialect, error) {
+	var dialect customerimporter.Dialect
+	for _, char := range []struct {
+		flag  string
+		value string
+		dst   *byte
+	}{
+		{flag: "-delimiter", value: delimiter, dst: &dialect.Delimiter},
+		{flag: "-comment", value: comment, dst: &dialect.Comment},
+	} {
+		switch {
+		case char.value == "":
+		case char.value == `\t`:
+			*char.dst = '\t'
+		case len(char.value) == 1:
+			*char.dst = char.value[0]
+		default:
+			return dialect, fmt.Errorf("%s must be a single character, not %s", char.flag, strconv.Quote(char.value))
+		}
+	}
+	return dialect, nil
+}
+
+// checkRejected returns an exitDataQuality error if a bigger fraction of the rows than `maxRejected` was rejected
+func checkRejected(stats customerimporter.RunStats, maxRejected float64) error {
+	if stats.RowsRead > 0 && float64(stats.RowsRejected)/float64(stats.RowsRead) > maxRejected {
+		return exitError{code: exitDataQuality, err: fmt.Errorf("%d of %d rows were rejected, more than -max-rejected %v allows",
+			stats.RowsRejected, stats.RowsRead, maxRejected)}
+	}
+	return nil
+}
+
+// checkMaxRejected validates the -max-rejected flag
+func checkMaxRejected(maxRejected float64) error {
+	if maxRejected < 0 || maxRejected > 1 {
+		return usageError(fmt.Errorf("-max-rejected must be between 0 and 1, not %v", maxRejected))
+	}
+	return nil
+}
+
+// createOutput creates the file results are written to, tests replace it to make writing fail
+var createOutput = func(path string) (io.WriteCloser, error) {
+	return os.Create(path)
+}
+
+// writeOutput calls `write` with a buffered writer to the file at `path`, or to `stdout` if `path` is empty. Failing
+// to close the file fails too, since whatever was written may not have made it to disk
+func writeOutput(path string, stdout io.Writer, write func(w io.Writer) error) (err error) {
+	if path != "" {
+		var file io.WriteCloser
+		if file, err = createOutput(path); err != nil {
+			return err
+		}
+		defer func() {
+			if closeErr := file.Close(); err == nil {
+				err = closeErr
+			}
+		}()
+		stdout = file
+	}
+	out := bufio.NewWriter(stdout)
+	if err := write(out); err != nil {
+		return err
+	}
+	if err := out.Flush(); err != nil {
+		return fmt.Errorf("couldn't write results: %w", err)
 	}
-	for _, domain := range customerCountByDomain {
-		fmt.Printf("%s(%d)\n", domain.Domain, domain.CustomerCount)
+	return nil
+}
+
+// splitList splits a comma separated flag value, an empty value being an empty list
+func splitList(value string) []string {
+	if value == "" {
+		return nil
 	}
+	return strings.Split(value, ",")
 }
@@ -0,0 +1,426 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"github.com/IllicLanthresh/TeamworkGoTests/internal/customerimporter"
+	"io"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_run(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	semicolonPath := filepath.Join(t.TempDir(), "semicolons.csv")
+	if err := ioutil.WriteFile(semicolonPath, []byte("# exported\nname;mail\nMildred;mhernandez0@github.io\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	beforePath := filepath.Join(t.TempDir(), "before.csv")
+	afterPath := filepath.Join(t.TempDir(), "after.txt")
+	if err := ioutil.WriteFile(afterPath, []byte("github.io(4)\n360.cn(1)\nnew.com(2)\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(beforePath, []byte("domain,customers\ngithub.io,3\n360.cn,1\nold.com,2\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	keyPath := filepath.Join(t.TempDir(), "hmac.key")
+	if err := ioutil.WriteFile(keyPath, []byte("k"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	inputsDir := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(inputsDir, "a.csv"), []byte("email\njohn@360.cn\njane@example.com\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	var compressed bytes.Buffer
+	compressor := gzip.NewWriter(&compressed)
+	if _, err := compressor.Write([]byte("email\nmary@example.com\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := compressor.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(inputsDir, "b.csv.gz"), compressed.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name       string
+		args       []string
+		stdin      string
+		wantCode   int
+		wantStdout string
+		wantStderr string
+	}{
+		{
+			name:       "count",
+			args:       []string{"-workers", "2", "../../test/data/importer/customers-small.csv"},
+			wantStdout: "360.cn(1)\ncyberchimps.com(2)\ngithub.io(4)\n",
+		},
+		{
+			name:       "csv format",
+			args:       []string{"-format", "csv", "-order", "count", "../../test/data/importer/customers-small.csv"},
+			wantStdout: "domain,customers\ngithub.io,4\ncyberchimps.com,2\n360.cn,1\n",
+		},
+		{
+			name: "ndjson with percentages",
+			args: []string{"-format", "ndjson", "-percentage", "-cumulative", "-order", "count", "../../test/data/importer/customers-small.csv"},
+			wantStdout: `{"domain":"github.io","customers":4,"percentage":57.14,"cumulative_percentage":57.14}` + "\n" +
+				`{"domain":"cyberchimps.com","customers":2,"percentage":28.57,"cumulative_percentage":85.71}` + "\n" +
+				`{"domain":"360.cn","customers":1,"percentage":14.29,"cumulative_percentage":100}` + "\n",
+		},
+		{
+			name:       "dialect and email column",
+			args:       []string{"-delimiter", ";", "-comment", "#", "-email-column", "mail", semicolonPath},
+			wantStdout: "github.io(1)\n",
+		},
+		{
+			name:       "standard input",
+			args:       []string{"-"},
+			stdin:      "email\njohn@360.cn\njane@example.com\n",
+			wantStdout: "360.cn(1)\nexample.com(1)\n",
+		},
+		{
+			name:       "duplicates on standard input",
+			args:       []string{"-duplicates", "-"},
+			stdin:      "email,name\na@b.com,x\na@b.com,y\n",
+			wantStdout: "a@b.com(2) differs in name\n\tline 2: a@b.com x\n\tline 3: a@b.com y\n",
+		},
+		{
+			name:       "hashed export of standard input",
+			args:       []string{"-export-hashed", "-hmac-key-file", keyPath, "-"},
+			stdin:      "email,name\na@b.com,x\n",
+			wantStdout: "email,name\n7100e543caae8feb41fc478b2970cc80ed1c813ffd1e5597e952e82ba35f921f,x\n",
+		},
+		{
+			name:       "several inputs",
+			args:       []string{"-order", "count", "../../test/data/importer/customers-small.csv", inputsDir},
+			wantStdout: "github.io(4)\n360.cn(2)\ncyberchimps.com(2)\nexample.com(2)\n",
+		},
+		{
+			name:       "by source",
+			args:       []string{"-by-source", "-format", "csv", "-", filepath.Join(inputsDir, "*.gz")},
+			stdin:      "email\njohn@360.cn\njane@example.com\n",
+			wantStdout: "domain,customers,-," + filepath.Join(inputsDir, "b.csv.gz") + "\n360.cn,1,1,0\nexample.com,2,1,1\n",
+		},
+		{
+			name:       "glob without matches",
+			args:       []string{filepath.Join(inputsDir, "*.tsv")},
+			wantCode:   exitUnreadableInput,
+			wantStderr: "file does not exist",
+		},
+		{
+			name:       "standard input twice",
+			args:       []string{"-", "-"},
+			wantCode:   exitUsage,
+			wantStderr: "standard input can only be read once",
+		},
+		{
+			name:       "several inputs without counting",
+			args:       []string{"-by", "gender", "../../test/data/importer/customers-small.csv", inputsDir},
+			wantCode:   exitUsage,
+			wantStderr: "several inputs",
+		},
+		{
+			name:       "count command",
+			args:       []string{"count", "-order", "count", "../../test/data/importer/customers-small.csv"},
+			wantStdout: "github.io(4)\ncyberchimps.com(2)\n360.cn(1)\n",
+		},
+		{
+			name:       "top",
+			args:       []string{"top", "-k", "2", "-percentage", "../../test/data/importer/customers-small.csv"},
+			wantStdout: "github.io(4) 57.14%\ncyberchimps.com(2) 28.57%\n",
+		},
+		{
+			name:       "top without count flags",
+			args:       []string{"top", "-by", "gender", "../../test/data/importer/customers-small.csv"},
+			wantCode:   exitUsage,
+			wantStderr: "flag provided but not defined: -by",
+		},
+		{
+			name:       "validate",
+			args:       []string{"validate", "../../test/data/importer/customers-small.csv"},
+			wantStdout: "rows read: 8\nrows rejected: 1 (12.50%)\nvalid rows: 7\ndomains: 3\n",
+		},
+		{
+			// The report itself is checked by Test_run_jsonStats, as its timings vary
+			name:       "validate with too many rejected rows",
+			args:       []string{"validate", "-max-rejected", "0", "-format", "json", "../../test/data/importer/customers-small.csv"},
+			wantCode:   exitDataQuality,
+			wantStderr: "1 of 8 rows were rejected",
+		},
+		{
+			name: "inspect",
+			args: []string{"inspect", semicolonPath},
+			wantStdout: "delimiter: \";\"\ncomment: \"#\"\nheaders row: true\nrows sampled: 1, 0 malformed\nemail column: mail\n\n" +
+				"column  type   empty  distinct  max length  samples\n" +
+				"name    text   0      1         7           Mildred\n" +
+				"mail    email  0      1         21          mhernandez0@github.io\n",
+		},
+		{
+			name:       "diff",
+			args:       []string{"diff", beforePath, afterPath},
+			wantStdout: "~ github.io 3 -> 4 (+1)\n+ new.com 0 -> 2 (+2)\n- old.com 2 -> 0 (-2)\ncustomers 6 -> 7 (+1)\n",
+		},
+		{
+			name:       "diff with a missing file",
+			args:       []string{"diff", beforePath, "missing.txt"},
+			wantCode:   exitUnreadableInput,
+			wantStderr: "no such file",
+		},
+		{
+			name:       "diff with an invalid file",
+			args:       []string{"diff", "-input-format", "text", beforePath, afterPath},
+			wantCode:   exitUnreadableInput,
+			wantStderr: "line 1 doesn't hold a domain",
+		},
+		{
+			name:       "diff with an unreadable input format",
+			args:       []string{"diff", "-input-format", "html", beforePath, afterPath},
+			wantCode:   exitUsage,
+			wantStderr: `input format "html" can't be read`,
+		},
+		{
+			name:       "sort",
+			args:       []string{"sort", "-u", "-"},
+			stdin:      "github.io\nB.com\r\nGitHub.io\na.com\n",
+			wantStdout: "a.com\nB.com\ngithub.io\n",
+		},
+		{
+			name:       "reversed sort",
+			args:       []string{"sort", "-r"},
+			stdin:      "b\nc\na",
+			wantStdout: "c\nb\na\n",
+		},
+		{
+			name:       "metrics file without counting",
+			args:       []string{"-by", "gender", "-metrics-file", "metrics.prom", "../../test/data/importer/customers-small.csv"},
+			wantCode:   exitUsage,
+			wantStderr: "-metrics-file can only be used",
+		},
+		{
+			name:       "serve with invalid limits",
+			args:       []string{"serve", "-workers", "0"},
+			wantCode:   exitUsage,
+			wantStderr: "-workers must be at least 1, not 0",
+		},
+		{
+			name:       "help",
+			args:       []string{"-help"},
+			wantStderr: "Commands:",
+		},
+		{
+			name:       "command help",
+			args:       []string{"inspect", "-help"},
+			wantStderr: "Exit codes:",
+		},
+		{
+			name:       "missing path",
+			args:       nil,
+			wantCode:   exitUsage,
+			wantStderr: "missing csv file path",
+		},
+		{
+			name:       "unknown command",
+			args:       []string{"cuont", "customers.csv"},
+			wantCode:   exitUsage,
+			wantStderr: `unknown command "cuont"`,
+		},
+		{
+			name:       "unknown flag",
+			args:       []string{"-bogus", "../../test/data/importer/customers-small.csv"},
+			wantCode:   exitUsage,
+			wantStderr: "flag provided but not defined",
+		},
+		{
+			name:       "invalid format",
+			args:       []string{"-by", "gender", "-format", "text", "../../test/data/importer/customers-small.csv"},
+			wantCode:   exitUsage,
+			wantStderr: "format \"text\"",
+		},
+		{
+			name:       "invalid delimiter",
+			args:       []string{"-delimiter", "\"", "../../test/data/importer/customers-small.csv"},
+			wantCode:   exitUsage,
+			wantStderr: "not a valid csv dialect",
+		},
+		{
+			name:       "filter syntax error",
+			args:       []string{"-where", "gender ==", "../../test/data/importer/customers-small.csv"},
+			wantCode:   exitUsage,
+			wantStderr: "^",
+		},
+		{
+			name:       "missing file",
+			args:       []string{"../../test/data/importer/missing.csv"},
+			wantCode:   exitUnreadableInput,
+			wantStderr: "no such file",
+		},
+		{
+			name:       "missing email column",
+			args:       []string{"-email-column", "mail", "../../test/data/importer/customers-small.csv"},
+			wantCode:   exitUnreadableInput,
+			wantStderr: "couldn't find \"mail\"",
+		},
+		{
+			name:       "empty file",
+			args:       []string{"../../test/data/importer/foo.csv"},
+			wantCode:   exitUnreadableInput,
+			wantStderr: "EOF",
+		},
+		{
+			name:       "too many rejected rows",
+			args:       []string{"-max-rejected", "0.1", "../../test/data/importer/customers-small.csv"},
+			wantCode:   exitDataQuality,
+			wantStdout: "360.cn(1)\ncyberchimps.com(2)\ngithub.io(4)\n",
+			wantStderr: "1 of 8 rows were rejected",
+		},
+		{
+			name:       "stats",
+			args:       []string{"-stats", "../../test/data/importer/customers-small.csv"},
+			wantStdout: "360.cn(1)\ncyberchimps.com(2)\ngithub.io(4)\n",
+			wantStderr: "rows read: 8\nrows rejected: 1\n  malformed: 0\n  empty email: 0\n  invalid email: 1\n" +
+				"unique domains: 3\nunique addresses: 6 (estimated)\nbytes read: 495 B\n",
+		},
+		{
+			name:       "few enough rejected rows",
+			args:       []string{"-max-rejected", "0.2", "-order", "count", "../../test/data/importer/customers-small.csv"},
+			wantStdout: "github.io(4)\ncyberchimps.com(2)\n360.cn(1)\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			if code := run(tt.args, strings.NewReader(tt.stdin), &stdout, &stderr); code != tt.wantCode {
+				t.Errorf("run() = %d, want %d, stderr %q", code, tt.wantCode, stderr.String())
+			}
+			if tt.wantStdout != "" && stdout.String() != tt.wantStdout {
+				t.Errorf("run() wrote %q, want %q", stdout.String(), tt.wantStdout)
+			}
+			if !strings.Contains(stderr.String(), tt.wantStderr) {
+				t.Errorf("run() wrote %q to stderr, want it to contain %q", stderr.String(), tt.wantStderr)
+			}
+		})
+	}
+}
+
+func Test_run_output(t *testing.T) {
+	outputPath := filepath.Join(t.TempDir(), "counts.csv")
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"-format", "csv", "-o", outputPath, "../../test/data/importer/customers-small.csv"}, nil, &stdout, &stderr); code != 0 {
+		t.Fatalf("run() = %d, stderr %q", code, stderr.String())
+	}
+	written, err := ioutil.ReadFile(outputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "domain,customers\n360.cn,1\ncyberchimps.com,2\ngithub.io,4\n"; string(written) != want || stdout.Len() > 0 {
+		t.Errorf("run() wrote %q to -o and %q to stdout, want %q to -o only", written, stdout.String(), want)
+	}
+}
+
+// failingCloser fails to close, as a file whose buffered writes fail on close
+type failingCloser struct {
+	bytes.Buffer
+}
+
+func (c *failingCloser) Close() error {
+	return errors.New("no space left on device")
+}
+
+func Test_run_outputCloseFails(t *testing.T) {
+	defer func(create func(path string) (io.WriteCloser, error)) {
+		createOutput = create
+	}(createOutput)
+	createOutput = func(path string) (io.WriteCloser, error) {
+		return &failingCloser{}, nil
+	}
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"-o", "counts.txt", "../../test/data/importer/customers-small.csv"}, nil, &stdout, &stderr); code != exitFailure {
+		t.Errorf("run() = %d, want %d, stderr %q", code, exitFailure, stderr.String())
+	}
+	if !strings.Contains(stderr.String(), "no space left on device") {
+		t.Errorf("run() wrote %q to stderr, want the close error", stderr.String())
+	}
+}
+
+func Test_run_metricsFile(t *testing.T) {
+	metricsPath := filepath.Join(t.TempDir(), "customers.prom")
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"-order", "count", "-metrics-file", metricsPath, "../../test/data/importer/customers-small.csv"}, nil, &stdout, &stderr); code != 0 {
+		t.Fatalf("run() = %d, stderr %q", code, stderr.String())
+	}
+	written, err := ioutil.ReadFile(metricsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"customer_import_rows_read_total 8\n",
+		"customer_import_rows_rejected_total{reason=\"invalid_email\"} 1\n",
+		"customer_import_sort_seconds_count{order=\"count\"} 1\n",
+	} {
+		if !strings.Contains(string(written), want) {
+			t.Errorf("run() wrote metrics %q, want them to contain %q", written, want)
+		}
+	}
+}
+
+func Test_run_jsonStats(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		// wantDomains is the length of the domains array, or -1 when the report has a domains count instead
+		wantDomains int
+		wantCode    int
+	}{
+		{name: "count", args: []string{"top", "-k", "2", "-stats", "-format", "json"}, wantDomains: 2},
+		{name: "validate", args: []string{"validate", "-max-rejected", "0", "-format", "json"}, wantDomains: -1, wantCode: exitDataQuality},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			args := append(tt.args, "../../test/data/importer/customers-small.csv")
+			if code := run(args, nil, &stdout, &stderr); code != tt.wantCode {
+				t.Fatalf("run() = %d, want %d, stderr %q", code, tt.wantCode, stderr.String())
+			}
+			var report struct {
+				customerimporter.RunStats
+				Stats     *customerimporter.RunStats `json:"stats"`
+				Domains   json.RawMessage            `json:"domains"`
+				ValidRows int64                      `json:"valid_rows"`
+			}
+			if err := json.Unmarshal(stdout.Bytes(), &report); err != nil {
+				t.Fatalf("run() wrote %q, not a JSON object: %s", stdout.String(), err)
+			}
+			stats := report.RunStats
+			if tt.wantDomains >= 0 {
+				var domains []json.RawMessage
+				if err := json.Unmarshal(report.Domains, &domains); err != nil || len(domains) != tt.wantDomains || report.Stats == nil {
+					t.Fatalf("run() wrote %q, want %d domains along with stats", stdout.String(), tt.wantDomains)
+				}
+				stats = *report.Stats
+			} else if string(report.Domains) != "3" || report.ValidRows != 7 {
+				t.Errorf("run() wrote %q, want 3 domains and 7 valid rows", stdout.String())
+			}
+			if stats.Duration <= 0 || stats.PeakMemory == 0 || stats.RowsPerSecond <= 0 || stats.BytesPerSecond <= 0 {
+				t.Errorf("run() wrote stats %+v, want a duration, peak memory and throughput", stats)
+			}
+			stats.Duration, stats.PeakMemory, stats.RowsPerSecond, stats.BytesPerSecond = 0, 0, 0, 0
+			want := customerimporter.RunStats{
+				ReadPath:        customerimporter.ReadPathBuffered,
+				RowsRead:        8,
+				RowsRejected:    1,
+				Rejected:        customerimporter.RejectedRows{InvalidEmail: 1},
+				UniqueDomains:   3,
+				UniqueAddresses: 6,
+				BytesRead:       495,
+			}
+			if stats != want {
+				t.Errorf("run() wrote stats %+v, want %+v", stats, want)
+			}
+		})
+	}
+}
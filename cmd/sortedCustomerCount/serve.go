@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/IllicLanthresh/TeamworkGoTests/internal/customerimporter"
+	"github.com/IllicLanthresh/TeamworkGoTests/internal/server"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"runtime"
+	"syscall"
+	"time"
+)
+
+const serveUsage = `Usage: %[1]s serve [flags]
+
+Serves an HTTP API counting the customers of each email domain in csv or NDJSON uploads, which are read as they
+arrive instead of being held in memory. POST the customers to /counts, the query parameters take the same options as
+the count command and the response holds the domains in JSON, CSV, NDJSON or an HTML report, along with the rows read
+and rejected in the X-Rows-Read and X-Rows-Rejected headers. Interrupting the server lets the requests in flight finish first.
+
+Uploads too big to wait for can be POSTed to /jobs instead, with the same query parameters: they are stored and
+counted in the background by -job-workers workers, answering right away with the status of a new job. GET /jobs/{id}
+tells how it's going, DELETE /jobs/{id} cancels it and GET /jobs/{id}/result returns the result once it's done, which
+is kept in -jobs-dir for -job-retention.
+
+GET /jobs/{id}/events follows a job as Server-Sent Events: its state, progress every -event-interval and, for jobs
+POSTed with the live parameter, the top domains counted so far, ?top=N of them. Clients reconnecting with a
+Last-Event-ID header get the events they missed. The page at / uploads a file as a live job and renders its events.
+
+GET /metrics returns the rows read and rejected, bytes read, unique domains and counting and sort timings of the
+counts run so far in the Prometheus text format. -pprof serves the runtime profiles of net/http/pprof under
+/debug/pprof/, don't enable it on a public address.
+
+Examples:
+  %[1]s serve -addr localhost:8080
+  curl --data-binary @customers.csv 'localhost:8080/counts?order=count&top=10&percentage'
+  curl -H 'Content-Type: application/x-ndjson' --data-binary @customers.ndjson 'localhost:8080/counts?format=csv'
+  curl --data-binary @customers-1m.csv 'localhost:8080/jobs?order=count'
+  curl -N 'localhost:8080/jobs/{id}/events?top=20'
+`
+
+func runServe(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	flags := newFlagSet("serve", serveUsage, stderr)
+	addr := flags.String("addr", "localhost:8080", "address to listen on")
+	maxBody := flags.Int64("max-body", server.DefaultMaxBodyBytes>>20, "MiB of the biggest upload accepted")
+	shutdownTimeout := flags.Duration("shutdown-timeout", 30*time.Second, "how long requests in flight are waited for when shutting down")
+	memoryBudget := flags.Int64("memory-budget", 64, "MiB of memory each upload uses to hold unique domains before spilling them to disk")
+	workers := flags.Int("workers", runtime.NumCPU(), "how many goroutines validate and count the addresses of each upload")
+	categoriesFile := flags.String("categories-file", "", "file with extra domain classification rules, overriding the embedded ones")
+	jobWorkers := flags.Int("job-workers", server.DefaultJobWorkers, "how many jobs run at the same time")
+	maxQueuedJobs := flags.Int("max-queued-jobs", server.DefaultMaxQueuedJobs, "how many jobs can wait for a worker before new ones are turned down")
+	jobsDir := flags.String("jobs-dir", "", "directory keeping the uploads and results of jobs, a temporary one by default")
+	jobRetention := flags.Duration("job-retention", server.DefaultJobRetention, "how long jobs and their results are kept after they finish")
+	pprof := flags.Bool("pprof", false, "serve runtime profiles under /debug/pprof/")
+	eventInterval := flags.Duration("event-interval", server.DefaultEventInterval, "how often running jobs send progress events and live snapshots")
+	if err := parseFlags(flags, args, 0, 0, ""); err != nil {
+		return err
+	}
+	// Invalid values are caught here instead of failing every request
+	for _, limit := range []struct {
+		flag  string
+		value int64
+	}{
+		{flag: "-max-body", value: *maxBody},
+		{flag: "-memory-budget", value: *memoryBudget},
+		{flag: "-workers", value: int64(*workers)},
+		{flag: "-job-workers", value: int64(*jobWorkers)},
+		{flag: "-max-queued-jobs", value: int64(*maxQueuedJobs)},
+		{flag: "-job-retention", value: int64(*jobRetention)},
+		{flag: "-event-interval", value: int64(*eventInterval)},
+	} {
+		if limit.value < 1 {
+			return usageError(fmt.Errorf("%s must be at least 1, not %d", limit.flag, limit.value))
+		}
+	}
+	options := []customerimporter.ImporterOption{
+		customerimporter.WithMemoryBudget(*memoryBudget << 20),
+		customerimporter.WithWorkers(*workers),
+	}
+	if *categoriesFile != "" {
+		option, err := classifierOption(*categoriesFile)
+		if err != nil {
+			return err
+		}
+		options = append(options, option)
+	}
+
+	api, err := server.NewServer(server.Config{
+		Options:       options,
+		MaxBodyBytes:  *maxBody << 20,
+		JobWorkers:    *jobWorkers,
+		MaxQueuedJobs: *maxQueuedJobs,
+		JobsDir:       *jobsDir,
+		JobRetention:  *jobRetention,
+		EventInterval: *eventInterval,
+		Pprof:         *pprof,
+	})
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := api.Close(); err != nil {
+			fmt.Fprintf(stderr, "couldn't clean up jobs: %s\n", err)
+		}
+	}()
+
+	listener, err := net.Listen("tcp", *addr)
+	if err != nil {
+		return err
+	}
+	httpServer := &http.Server{Handler: api, ReadHeaderTimeout: 10 * time.Second}
+	// Event streams only end with their job, so they're ended for Shutdown not to wait for them. The jobs are canceled
+	// and their directory removed by the deferred Close, once Shutdown has returned
+	httpServer.RegisterOnShutdown(api.Shutdown)
+	fmt.Fprintf(stderr, "listening on %s\n", listener.Addr())
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	served := make(chan error, 1)
+	go func() {
+		served <- httpServer.Serve(listener)
+	}()
+	select {
+	case err := <-served:
+		return err
+	case <-signals:
+		fmt.Fprintln(stderr, "shutting down")
+		ctx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+		defer cancel()
+		if err := httpServer.Shutdown(ctx); err != nil {
+			return fmt.Errorf("couldn't shut down gracefully: %w", err)
+		}
+		if err := <-served; !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+		return nil
+	}
+}
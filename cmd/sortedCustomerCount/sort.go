@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/IllicLanthresh/TeamworkGoTests/pkg/radixSorter"
+	"io"
+	"os"
+	"strings"
+)
+
+const sortUsage = `Usage: %[1]s sort [flags] [file ...]
+
+Sorts the lines of the files, or of the standard input if there are none or the file is -, alphabetically with the
+radix sorter domains are sorted with. Lines are compared ignoring case, lines equal ignoring case keep their order.
+
+Examples:
+  %[1]s sort domains.txt
+  cut -d, -f3 customers.csv | %[1]s sort -u -r
+`
+
+func runSort(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	flags := newFlagSet("sort", sortUsage, stderr)
+	unique := flags.Bool("u", false, "only write the first of the lines equal ignoring case")
+	reverse := flags.Bool("r", false, "sort in reverse order")
+	output := flags.String("o", "", "file to write the sorted lines to, standard output by default")
+	if err := parseFlags(flags, args, 0, -1, "files"); err != nil {
+		return err
+	}
+	paths := flags.Args()
+	if len(paths) == 0 {
+		paths = []string{"-"}
+	}
+
+	var lines []string
+	for _, path := range paths {
+		var err error
+		if path == "-" {
+			lines, err = readLines(stdin, lines)
+		} else {
+			lines, err = readFileLines(path, lines)
+		}
+		if err != nil {
+			return readingError(fmt.Errorf("couldn't read %s: %w", path, err))
+		}
+	}
+	order := radixSorter.StringOrder(lines)
+	if *unique {
+		uniqueOrder := order[:0]
+		for i, index := range order {
+			if i == 0 || !strings.EqualFold(lines[index], lines[order[i-1]]) {
+				uniqueOrder = append(uniqueOrder, index)
+			}
+		}
+		order = uniqueOrder
+	}
+
+	return writeOutput(*output, stdout, func(w io.Writer) error {
+		for i := range order {
+			index := order[i]
+			if *reverse {
+				index = order[len(order)-1-i]
+			}
+			if _, err := io.WriteString(w, lines[index]+"\n"); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func readFileLines(path string, lines []string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return readLines(file, lines)
+}
+
+// readLines appends the lines of `r` to `lines`, without their line breaks
+func readLines(r io.Reader, lines []string) ([]string, error) {
+	reader := bufio.NewReader(r)
+	for {
+		line, err := reader.ReadString('\n')
+		if line != "" {
+			lines = append(lines, strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r"))
+		}
+		if err == io.EOF {
+			return lines, nil
+		} else if err != nil {
+			return nil, err
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/IllicLanthresh/TeamworkGoTests/internal/customerimporter"
+	"io"
+)
+
+const validateUsage = `Usage: %[1]s validate [flags] customers.csv
+
+Reads every row of a csv file with a headers row, reporting how many were rejected because they couldn't be parsed
+or had an empty or invalid email address. Rejected rows are logged to stderr. The JSON report also holds the unique
+domains and addresses, throughput and peak memory of the run.
+
+Examples:
+  %[1]s validate customers.csv
+  %[1]s validate -max-rejected 0 customers.csv && echo "every row is fine"
+  %[1]s validate -format json -delimiter ';' export.csv
+`
+
+// validationReport is the report of the validate command
+type validationReport struct {
+	customerimporter.RunStats
+	// ValidRows counts the rows read which weren't rejected, whether the -where filter matched them or not
+	ValidRows int64 `json:"valid_rows"`
+	Domains   int   `json:"domains"`
+}
+
+func runValidate(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	flags := newFlagSet("validate", validateUsage, stderr)
+	input := addInputFlags(flags)
+	output := flags.String("o", "", "file to write the report to, standard output by default")
+	format := flags.String("format", "text", "output format: text or json")
+	maxRejected := flags.Float64("max-rejected", 1, "fail with exit code 4 when a bigger fraction of the rows than this is rejected, 0 to fail on any")
+	if err := parseFlags(flags, args, 1, 1, "csv file path"); err != nil {
+		return err
+	}
+	if *format != "text" && *format != "json" {
+		return usageError(fmt.Errorf("format %q can't be used for a validation report", *format))
+	}
+	if err := checkMaxRejected(*maxRejected); err != nil {
+		return err
+	}
+	csvPath := flags.Arg(0)
+	options, err := input.importerOptions()
+	if err != nil {
+		return err
+	}
+	if err := checkInputFile(csvPath); err != nil {
+		return err
+	}
+	importer, err := customerimporter.NewCsvCustomerImporter(csvPath, *input.emailColumn, options...)
+	if err != nil {
+		return usageError(err)
+	}
+	domains, stats, err := importer.CustomerCountByDomainWithStats()
+	if err != nil {
+		return readingError(err)
+	}
+
+	report := validationReport{RunStats: stats, ValidRows: stats.RowsRead - stats.RowsRejected, Domains: len(domains)}
+	err = writeOutput(*output, stdout, func(w io.Writer) error {
+		if *format == "json" {
+			encoder := json.NewEncoder(w)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(report)
+		}
+		rejected := 0.0
+		if report.RowsRead > 0 {
+			rejected = float64(report.RowsRejected) / float64(report.RowsRead) * 100
+		}
+		_, err := fmt.Fprintf(w, "rows read: %d\nrows rejected: %d (%.2f%%)\nvalid rows: %d\ndomains: %d\n",
+			report.RowsRead, report.RowsRejected, rejected, report.ValidRows, report.Domains)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return checkRejected(stats, *maxRejected)
+}
@@ -1,3 +1,3 @@
 module github.com/IllicLanthresh/TeamworkGoTests
 
-go 1.16
+go 1.17
@@ -0,0 +1,128 @@
+package customerimporter
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"github.com/IllicLanthresh/TeamworkGoTests/pkg/helperTypes"
+	"io"
+	"sort"
+	"strings"
+)
+
+const (
+	CategoryCorporate  = "corporate"
+	CategoryFreemail   = "freemail"
+	CategoryDisposable = "disposable"
+)
+
+//go:embed domain_categories.txt
+var defaultDomainCategories string
+
+// domainClassifier assigns a category to email domains following a list of rules, see domain_categories.txt for
+// their syntax
+type domainClassifier struct {
+	exact    map[string]string
+	wildcard map[string]string
+}
+
+// NewDomainClassifier constructor for domainClassifier, it starts with the embedded classification list
+func NewDomainClassifier() *domainClassifier {
+	classifier := &domainClassifier{
+		exact:    make(map[string]string),
+		wildcard: make(map[string]string),
+	}
+	if err := classifier.Load(strings.NewReader(defaultDomainCategories)); err != nil {
+		panic("This should never happen, embedded domain categories are invalid: " + err.Error())
+	}
+	return classifier
+}
+
+// Load adds the rules read from `rules` to the classifier, replacing any previous rule for the same domain
+func (c *domainClassifier) Load(rules io.Reader) error {
+	scanner := bufio.NewScanner(rules)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Fields(text)
+		if len(fields) != 2 {
+			return InvalidClassificationRuleError{line: line, rule: text}
+		}
+		category, domain := strings.ToLower(fields[0]), strings.ToLower(fields[1])
+		if strings.HasPrefix(domain, "*.") {
+			c.wildcard[domain[2:]] = category
+		} else if strings.Contains(domain, "*") {
+			return InvalidClassificationRuleError{line: line, rule: text}
+		} else {
+			c.exact[domain] = category
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("couldn't read classification rules: %w", err)
+	}
+	return nil
+}
+
+// Classify returns the category of `domain`, CategoryCorporate if no rule matches it
+func (c *domainClassifier) Classify(domain string) string {
+	domain = strings.ToLower(domain)
+	if category, found := c.exact[domain]; found {
+		return category
+	}
+	// Walking from the left makes the longest matching wildcard win
+	for dot := strings.IndexByte(domain, '.'); dot != -1; {
+		suffix := domain[dot+1:]
+		if category, found := c.wildcard[suffix]; found {
+			return category
+		}
+		next := strings.IndexByte(suffix, '.')
+		if next == -1 {
+			break
+		}
+		dot += next + 1
+	}
+	return CategoryCorporate
+}
+
+type categoryTotal struct {
+	Category      string
+	DomainCount   int
+	CustomerCount int
+}
+
+// CategoryTotals outputs the number of domains and customers for each category found in `domains`, sorted by category
+func CategoryTotals(domains []EmailDomain) (totals []categoryTotal) {
+	totalsByCategory := make(map[string]*categoryTotal)
+	var categories []string
+	for _, domain := range domains {
+		total, found := totalsByCategory[domain.Category]
+		if !found {
+			total = &categoryTotal{Category: domain.Category}
+			totalsByCategory[domain.Category] = total
+			categories = append(categories, domain.Category)
+		}
+		total.DomainCount++
+		total.CustomerCount += domain.CustomerCount
+	}
+	sort.Strings(categories)
+	for _, category := range categories {
+		totals = append(totals, *totalsByCategory[category])
+	}
+	return
+}
+
+// FilterByCategory keeps the domains whose category is in `only`, or any category if it's empty, and not in `exclude`
+func FilterByCategory(domains []EmailDomain, only []string, exclude []string) (filtered []EmailDomain) {
+	for _, domain := range domains {
+		if len(only) > 0 && !helperTypes.StringSlice(only).Contains(domain.Category) {
+			continue
+		}
+		if helperTypes.StringSlice(exclude).Contains(domain.Category) {
+			continue
+		}
+		filtered = append(filtered, domain)
+	}
+	return
+}
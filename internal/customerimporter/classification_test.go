@@ -0,0 +1,110 @@
+package customerimporter
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_domainClassifier_Classify(t *testing.T) {
+	classifier := NewDomainClassifier()
+	err := classifier.Load(strings.NewReader(`
+# custom rules
+education *.edu
+disposable *.temp.example.com
+freemail github.io
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		domain string
+		want   string
+	}{
+		{domain: "gmail.com", want: CategoryFreemail},
+		{domain: "GMail.com", want: CategoryFreemail},
+		{domain: "mail.gmail.com", want: CategoryCorporate},
+		{domain: "mailinator.com", want: CategoryDisposable},
+		{domain: "foo.mailinator.com", want: CategoryDisposable},
+		{domain: "acme.com", want: CategoryCorporate},
+		{domain: "cs.mit.edu", want: "education"},
+		{domain: "edu", want: CategoryCorporate},
+		{domain: "a.temp.example.com", want: CategoryDisposable},
+		{domain: "github.io", want: CategoryFreemail},
+	}
+	for _, tt := range tests {
+		t.Run(tt.domain, func(t *testing.T) {
+			if got := classifier.Classify(tt.domain); got != tt.want {
+				t.Errorf("Classify() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_domainClassifier_Load(t *testing.T) {
+	tests := []struct {
+		name    string
+		rules   string
+		wantErr bool
+	}{
+		{name: "comments and blank lines", rules: "# foo\n\n  \nfreemail foo.com\n", wantErr: false},
+		{name: "missing domain", rules: "freemail\n", wantErr: true},
+		{name: "too many fields", rules: "freemail foo.com bar.com\n", wantErr: true},
+		{name: "wildcard in the middle", rules: "freemail foo.*.com\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := NewDomainClassifier().Load(strings.NewReader(tt.rules))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Load() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCategoryTotals(t *testing.T) {
+	domains := []EmailDomain{
+		{Domain: "acme.com", CustomerCount: 3, Category: CategoryCorporate},
+		{Domain: "gmail.com", CustomerCount: 10, Category: CategoryFreemail},
+		{Domain: "mailinator.com", CustomerCount: 1, Category: CategoryDisposable},
+		{Domain: "yahoo.com", CustomerCount: 5, Category: CategoryFreemail},
+	}
+	want := []categoryTotal{
+		{Category: CategoryCorporate, DomainCount: 1, CustomerCount: 3},
+		{Category: CategoryDisposable, DomainCount: 1, CustomerCount: 1},
+		{Category: CategoryFreemail, DomainCount: 2, CustomerCount: 15},
+	}
+	if got := CategoryTotals(domains); !reflect.DeepEqual(got, want) {
+		t.Errorf("CategoryTotals() = %v, want %v", got, want)
+	}
+}
+
+func TestFilterByCategory(t *testing.T) {
+	domains := []EmailDomain{
+		{Domain: "acme.com", CustomerCount: 3, Category: CategoryCorporate},
+		{Domain: "gmail.com", CustomerCount: 10, Category: CategoryFreemail},
+		{Domain: "mailinator.com", CustomerCount: 1, Category: CategoryDisposable},
+	}
+	tests := []struct {
+		name    string
+		only    []string
+		exclude []string
+		want    []string
+	}{
+		{name: "no filters", want: []string{"acme.com", "gmail.com", "mailinator.com"}},
+		{name: "only", only: []string{CategoryCorporate, CategoryDisposable}, want: []string{"acme.com", "mailinator.com"}},
+		{name: "exclude", exclude: []string{CategoryDisposable}, want: []string{"acme.com", "gmail.com"}},
+		{name: "both", only: []string{CategoryCorporate, CategoryDisposable}, exclude: []string{CategoryDisposable}, want: []string{"acme.com"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, domain := range FilterByCategory(domains, tt.only, tt.exclude) {
+				got = append(got, domain.Domain)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FilterByCategory() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package customerimporter
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// CombineCustomerCounts counts the customers of every domain in the input of each importer, running up to
+// `concurrency` importers at a time, and adds up the counts of the same domain. The domains are sorted following the
+// ordering of the first importer, and recorded in its metrics, with BySource holding their count in each importer in
+// the order of `importers`. The stats add up the rows, addresses and bytes of every importer, their read path being
+// ReadPathMixed if not all of them read the same way, and their duration and throughput are those of the whole
+// combination
+func CombineCustomerCounts(importers []*csvCustomerImporter, concurrency int) (sortedDomains []EmailDomain, stats RunStats, err error) {
+	if len(importers) == 0 {
+		return nil, stats, nil
+	}
+	started := time.Now()
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	results := make([][]EmailDomain, len(importers))
+	importerStats := make([]RunStats, len(importers))
+	errs := make([]error, len(importers))
+	slots := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, importer := range importers {
+		wg.Add(1)
+		go func(i int, importer *csvCustomerImporter) {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+			results[i], importerStats[i], errs[i] = importer.CustomerCountByDomainWithStats()
+		}(i, importer)
+	}
+	wg.Wait()
+
+	indexOf := make(map[string]int)
+	for i, domains := range results {
+		if errs[i] != nil {
+			return nil, stats, fmt.Errorf("couldn't count domains of %s: %w", importers[i].Source(), errs[i])
+		}
+		stats.add(importerStats[i])
+		if i == 0 {
+			stats.ReadPath = importerStats[i].ReadPath
+		} else if stats.ReadPath != importerStats[i].ReadPath {
+			stats.ReadPath = ReadPathMixed
+		}
+		for _, domain := range domains {
+			index, seen := indexOf[domain.Domain]
+			if !seen {
+				index = len(sortedDomains)
+				indexOf[domain.Domain] = index
+				domain.BySource = make([]int, len(importers))
+				sortedDomains = append(sortedDomains, domain)
+			} else {
+				sortedDomains[index].CustomerCount += domain.CustomerCount
+			}
+			sortedDomains[index].BySource[i] = domain.CustomerCount
+		}
+	}
+	stats.UniqueDomains = len(sortedDomains)
+	sortedDomains = importers[0].metrics.SortDomains(sortedDomains, importers[0].ordering)
+	stats.finish(time.Since(started))
+	return sortedDomains, stats, nil
+}
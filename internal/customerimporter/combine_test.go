@@ -0,0 +1,94 @@
+package customerimporter
+
+import (
+	"compress/gzip"
+	"errors"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCombineCustomerCounts(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	csvFile, err := ioutil.ReadFile("../../test/data/importer/customers-small.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	gzipPath := filepath.Join(t.TempDir(), "customers-small.csv.gz")
+	file, err := os.Create(gzipPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	compressor := gzip.NewWriter(file)
+	if _, err := compressor.Write(csvFile); err != nil {
+		t.Fatal(err)
+	}
+	if err := compressor.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := file.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, concurrency := range []int{1, 3} {
+		plain, err := NewCsvCustomerImporter("../../test/data/importer/customers-small.csv", "email")
+		if err != nil {
+			t.Fatal(err)
+		}
+		compressed, err := NewCsvCustomerImporter(gzipPath, "email", WithWorkers(2))
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader, err := NewReaderCustomerImporter("-", strings.NewReader("email\njohn@360.cn\njane@example.com\n"), "email")
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, stats, err := CombineCustomerCounts([]*csvCustomerImporter{plain, compressed, reader}, concurrency)
+		if err != nil {
+			t.Fatal(err)
+		}
+		classifier := NewDomainClassifier()
+		want := []EmailDomain{
+			{Domain: "360.cn", CustomerCount: 3, BySource: []int{1, 1, 1}},
+			{Domain: "cyberchimps.com", CustomerCount: 4, BySource: []int{2, 2, 0}},
+			{Domain: "example.com", CustomerCount: 1, BySource: []int{0, 0, 1}},
+			{Domain: "github.io", CustomerCount: 8, BySource: []int{4, 4, 0}},
+		}
+		for i := range want {
+			want[i].Category = classifier.Classify(want[i].Domain)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("CombineCustomerCounts() with concurrency %d = %v, want %v", concurrency, got, want)
+		}
+		if stats.Duration <= 0 || stats.PeakMemory == 0 || stats.BytesRead <= 0 || stats.RowsPerSecond <= 0 {
+			t.Errorf("CombineCustomerCounts() with concurrency %d stats = %+v, want a duration, peak memory, bytes and throughput", concurrency, stats)
+		}
+		stats.Duration, stats.PeakMemory, stats.BytesRead, stats.RowsPerSecond, stats.BytesPerSecond, stats.addresses = 0, 0, 0, 0, 0, nil
+		wantStats := RunStats{ReadPath: ReadPathBuffered, RowsRead: 18, RowsRejected: 2, Rejected: RejectedRows{InvalidEmail: 2},
+			UniqueDomains: 4, UniqueAddresses: 8}
+		if stats != wantStats {
+			t.Errorf("CombineCustomerCounts() with concurrency %d stats = %+v, want %+v", concurrency, stats, wantStats)
+		}
+	}
+}
+
+func TestCombineCustomerCounts_error(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	plain, err := NewCsvCustomerImporter("../../test/data/importer/customers-small.csv", "email")
+	if err != nil {
+		t.Fatal(err)
+	}
+	missingColumn, err := NewReaderCustomerImporter("-", strings.NewReader("mail\njohn@360.cn\n"), "email")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = CombineCustomerCounts([]*csvCustomerImporter{plain, missingColumn}, 2)
+	var keyNotFound KeyNotFoundError
+	if !errors.As(err, &keyNotFound) {
+		t.Errorf("CombineCustomerCounts() error = %v, want a KeyNotFoundError", err)
+	}
+}
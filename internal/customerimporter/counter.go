@@ -0,0 +1,137 @@
+package customerimporter
+
+import (
+	"fmt"
+	"github.com/IllicLanthresh/TeamworkGoTests/pkg/radixSorter"
+	"strconv"
+)
+
+// domainEntryOverhead is a rough estimate of the bytes a domain takes in the counter map and the radix sorter
+// besides the bytes of the domain itself, which is held twice
+const domainEntryOverhead = 80
+
+// domainCounter counts customers per domain keeping its memory usage under a budget, when it goes over it the counts
+// are written to disk as a run sorted by domain and counting starts over, runs being merged back when done
+type domainCounter struct {
+	budget int64
+	// counts holds pointers so counting an already seen domain doesn't need to allocate a string for it
+	counts     map[string]*int
+	usedMemory int64
+	runs       *spillRuns
+}
+
+func newDomainCounter(budget int64) *domainCounter {
+	return &domainCounter{
+		budget: budget,
+		counts: make(map[string]*int),
+	}
+}
+
+// add adds `count` customers to `domain`
+func (c *domainCounter) add(domain string, count int) error {
+	if current, exists := c.counts[domain]; exists {
+		*current += count
+		return nil
+	}
+	return c.insert(domain, count)
+}
+
+// addBytes adds a customer to `domain`, only allocating the first time the domain is seen
+func (c *domainCounter) addBytes(domain []byte) error {
+	if current, exists := c.counts[string(domain)]; exists {
+		*current++
+		return nil
+	}
+	return c.insert(string(domain), 1)
+}
+
+// insert adds a domain the counter doesn't hold yet, spilling every count to disk when going over the budget
+func (c *domainCounter) insert(domain string, count int) error {
+	c.counts[domain] = &count
+	c.usedMemory += int64(domainEntryOverhead + 2*len(domain))
+	if c.usedMemory <= c.budget {
+		return nil
+	}
+
+	if c.runs == nil {
+		runs, err := newSpillRuns()
+		if err != nil {
+			return err
+		}
+		c.runs = runs
+	}
+	if err := c.spill(); err != nil {
+		return err
+	}
+	c.counts = make(map[string]*int)
+	c.usedMemory = 0
+	return nil
+}
+
+// spill writes the counts held in memory as a new run
+func (c *domainCounter) spill() error {
+	return c.runs.write(func(write func(record []string) error) error {
+		for _, domain := range c.sortedDomains() {
+			if err := write([]string{domain, strconv.Itoa(*c.counts[domain])}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (c *domainCounter) sortedDomains() []string {
+	sorter := radixSorter.NewRadixSorter()
+	for domain := range c.counts {
+		sorter.Add(domain)
+	}
+	return sorter.Sort()
+}
+
+// each calls `fn` with the count of every domain, in alphabetical order, and frees any disk used by the counter
+func (c *domainCounter) each(fn func(domain string, count int)) error {
+	if c.runs == nil {
+		for _, domain := range c.sortedDomains() {
+			fn(domain, *c.counts[domain])
+		}
+		return nil
+	}
+	defer c.cleanup()
+
+	if len(c.counts) > 0 {
+		if err := c.spill(); err != nil {
+			return err
+		}
+		c.counts = make(map[string]*int)
+	}
+	current, currentCount := "", 0
+	err := c.runs.merge(func(record []string) error {
+		count, err := strconv.Atoi(record[1])
+		if err != nil {
+			return fmt.Errorf("corrupted spill run: %w", err)
+		}
+		if record[0] != current {
+			if currentCount > 0 {
+				fn(current, currentCount)
+			}
+			current, currentCount = record[0], 0
+		}
+		currentCount += count
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if currentCount > 0 {
+		fn(current, currentCount)
+	}
+	return nil
+}
+
+// cleanup removes any runs written to disk
+func (c *domainCounter) cleanup() {
+	if c.runs != nil {
+		c.runs.cleanup()
+		c.runs = nil
+	}
+}
@@ -0,0 +1,80 @@
+package customerimporter
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func Test_domainCounter_each(t *testing.T) {
+	tests := []struct {
+		name   string
+		budget int64
+	}{
+		{name: "in memory", budget: defaultMemoryBudget},
+		{name: "spilling every domain", budget: 1},
+		{name: "spilling every few domains", budget: 400},
+	}
+	domains := []string{"github.io", "360.cn", "github.io", "cyberchimps.com", "acme.com", "github.io", "360.cn"}
+	want := map[string]int{"360.cn": 2, "acme.com": 1, "cyberchimps.com": 1, "github.io": 3}
+	wantOrder := []string{"360.cn", "acme.com", "cyberchimps.com", "github.io"}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			counter := newDomainCounter(tt.budget)
+			for _, domain := range domains {
+				if err := counter.add(domain, 1); err != nil {
+					t.Fatal(err)
+				}
+			}
+			spillDir := ""
+			if counter.runs != nil {
+				spillDir = counter.runs.dir
+			}
+
+			got := make(map[string]int)
+			var gotOrder []string
+			err := counter.each(func(domain string, count int) {
+				got[domain] = count
+				gotOrder = append(gotOrder, domain)
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) || !reflect.DeepEqual(gotOrder, wantOrder) {
+				t.Errorf("each() = %v %v, want %v %v", got, gotOrder, want, wantOrder)
+			}
+			if spillDir != "" {
+				if _, err := os.Stat(spillDir); !os.IsNotExist(err) {
+					t.Errorf("each() left spill runs behind in %s", spillDir)
+				}
+			}
+		})
+	}
+}
+
+func TestWithMemoryBudget_CustomerCountByDomain(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	imp, err := NewCsvCustomerImporter("../../test/data/importer/customers.csv", "email")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := imp.CustomerCountByDomain()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	imp, err = NewCsvCustomerImporter("../../test/data/importer/customers.csv", "email", WithMemoryBudget(4096))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := imp.CustomerCountByDomain()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("CustomerCountByDomain() with a small memory budget differs from the in-memory result")
+	}
+}
@@ -1,28 +1,33 @@
 // Package customerimporter reads from the given customers.csv file and returns a
 // sorted (data structure of your choice) of email domains along with the number
-// of customers with e-mail addresses for each emailDomain.  Any errors should be
+// of customers with e-mail addresses for each EmailDomain.  Any errors should be
 // logged (or handled). Performance matters (this is only ~3k lines, but *could*
 // be 1m lines or run on a small machine).
 package customerimporter
 
 import (
+	"bytes"
 	"encoding/csv"
+	"errors"
 	"fmt"
 	"github.com/IllicLanthresh/TeamworkGoTests/pkg/helperTypes"
-	"github.com/IllicLanthresh/TeamworkGoTests/pkg/radixSorter"
+	"github.com/IllicLanthresh/TeamworkGoTests/pkg/rowFilter"
 	"io"
 	"log"
 	"os"
 	"regexp"
 	"strings"
+	"time"
+	"unicode/utf8"
 )
 
 // customerimporter just a demo of how this module could hold different implementations for different email sources
 // like: databases, APIs...
 type customerImporter interface {
 	// emailAddressesGenerator is a generator like closure that feeds from customer records
-	// and returns a channel of emailAddress objects,
-	emailAddressesGenerator() (emailAddresses chan emailAddress, err error)
+	// and returns a channel of customerRecord objects holding email addresses, carrying the values of any extra
+	// `columnKeys` requested
+	emailAddressesGenerator(columnKeys ...string) (emailAddresses chan customerRecord, err error)
 	// CustomerCountByDomain feeds from emailAddressesGenerator and outputs the count of customers for each email domain
 	CustomerCountByDomain() (customerCountByDomain map[string]int)
 }
@@ -30,67 +35,186 @@ type customerImporter interface {
 // csvCustomerImporter is the CSV implementation of a customerImporter, it recieves a csv file path and an email key
 // representing the header name for the email column
 type csvCustomerImporter struct {
-	csvPath  string
-	emailKey string
+	csvPath string
+	// reader is the input of importers created with NewReaderCustomerImporter, csvPath naming it
+	reader io.Reader
+	// readerConsumed is set once reader has been handed to an analysis
+	readerConsumed bool
+	emailKey       string
+	filter         *rowFilter.Expression
+	// memoryBudget is the amount of bytes analyses able to spill to disk can keep in memory
+	memoryBudget int64
+	classifier   *domainClassifier
+	ordering     Ordering
+	// workers is the number of goroutines validating and counting addresses in CustomerCountByDomain
+	workers   int
+	memoryMap bool
+	dialect   Dialect
+	metrics   *importerMetrics
 }
 
-// NewCsvCustomerImporter constructor for csvCustomerImporter
-func NewCsvCustomerImporter(csvPath string, emailKey string) (*csvCustomerImporter, error) {
-	if csvPath == "" || len(csvPath) < 4 || !strings.HasSuffix(csvPath, ".csv") {
+// NewCsvCustomerImporter constructor for csvCustomerImporter, its behaviour can be tweaked with `options`.
+// Files ending in .csv.gz are decompressed while reading them
+func NewCsvCustomerImporter(csvPath string, emailKey string, options ...ImporterOption) (*csvCustomerImporter, error) {
+	if csvPath == "" || len(csvPath) < 4 || !isCsvPath(csvPath) {
 		return nil, CsvPathInvalidError{path: csvPath}
 	}
 	if _, err := os.Stat(csvPath); os.IsNotExist(err) {
 		return nil, fmt.Errorf("path %s does not exist", csvPath)
 	}
+	return newImporter(csvPath, nil, emailKey, options)
+}
+
+// NewReaderCustomerImporter works like NewCsvCustomerImporter for csv data coming from `reader`, like standard input,
+// `name` standing for it in errors and logs. The reader can only be read once, so a single analysis can be run with
+// the importer, any other fails with a SourceConsumedError
+func NewReaderCustomerImporter(name string, reader io.Reader, emailKey string, options ...ImporterOption) (*csvCustomerImporter, error) {
+	return newImporter(name, reader, emailKey, options)
+}
+
+func newImporter(csvPath string, reader io.Reader, emailKey string, options []ImporterOption) (*csvCustomerImporter, error) {
 	if emailKey == "" {
 		return nil, MissingEmailKey{}
 	}
 	importer := csvCustomerImporter{
-		csvPath:  csvPath,
-		emailKey: emailKey,
+		csvPath:      csvPath,
+		reader:       reader,
+		emailKey:     emailKey,
+		memoryBudget: defaultMemoryBudget,
+		ordering:     OrderByDomain,
+		workers:      1,
+	}
+	for _, option := range options {
+		if err := option(&importer); err != nil {
+			return nil, err
+		}
+	}
+	if importer.classifier == nil {
+		importer.classifier = NewDomainClassifier()
 	}
 	return &importer, nil
 }
 
-type emailAddress struct {
-	Address string
-	Err     error
+// customerRecord holds the value of the column a generator was keyed on, along with the values of any extra columns
+type customerRecord struct {
+	Value string
+	// Columns holds the values of the extra columns requested to the generator, in the same order
+	Columns []string
+	// Line is the line of the csv file where the row starts
+	Line int
+	Err  error
 }
 
 // emailAddressesGenerator takes a path `csvPath` to a csv file with customer records,
 // the csv file is supposed to have a headers row and `emailKey` is the name of the row holding email addresses.
-// Any email addresses not complying with RFC 5322 will be ignored
-func (imp *csvCustomerImporter) emailAddressesGenerator() (emailAddresses chan emailAddress, err error) {
-	regex := regexp.MustCompile(emailRegex)
+// The values of `columnKeys` are carried along with each address.
+// Rows not matching the importer filter are skipped, and any email addresses not complying with RFC 5322 will be ignored
+func (imp *csvCustomerImporter) emailAddressesGenerator(columnKeys ...string) (emailAddresses chan customerRecord, err error) {
+	return imp.recordsGenerator(imp.emailKey, validateEmailAddress, columnKeys...)
+}
 
-	fileReader, err := os.Open(imp.csvPath)
+var (
+	emailRegexp            = regexp.MustCompile(emailRegex)
+	errInvalidEmailAddress = errors.New("email address not compliant with RFC 5322")
+	errEmptyEmailAddress   = errors.New("empty email address")
+)
+
+// validateEmailAddress errors out on any email addresses not complying with RFC 5322
+func validateEmailAddress(address string) error {
+	if !emailRegexp.MatchString(address) {
+		return errInvalidEmailAddress
+	}
+	return nil
+}
+
+// openCsv opens the csv file and reads its headers row, the caller is responsible for closing `fileReader`
+func (imp *csvCustomerImporter) openCsv() (fileReader io.ReadCloser, csvReader *csv.Reader, headers helperTypes.StringSlice, err error) {
+	fileReader, err = imp.openSource()
 	if err != nil {
-		return nil, fmt.Errorf("couldn't open file %s: %w", imp.csvPath, err)
+		return nil, nil, nil, err
 	}
-	csvReader := csv.NewReader(fileReader)
-	headers, err := csvReader.Read()
+	csvReader = csv.NewReader(fileReader)
+	csvReader.Comma = rune(imp.dialect.delimiter())
+	csvReader.Comment = rune(imp.dialect.Comment)
+	headers, err = csvReader.Read()
 	if err != nil {
-		return nil, fmt.Errorf("couldn't read headers row on CSV file: %w", err)
+		_ = fileReader.Close()
+		return nil, nil, nil, fmt.Errorf("couldn't read headers row on CSV file: %w", err)
+	}
+	return fileReader, csvReader, headers, nil
+}
+
+// Headers returns the names of the columns in the headers row of the csv file. Whatever it reads from the reader of
+// importers created with NewReaderCustomerImporter is kept, so the analysis run afterwards reads it again
+func (imp *csvCustomerImporter) Headers() ([]string, error) {
+	if imp.reader != nil {
+		var read bytes.Buffer
+		reader := imp.reader
+		imp.reader = io.TeeReader(reader, &read)
+		defer func() {
+			imp.reader = io.MultiReader(&read, reader)
+		}()
+	}
+	fileReader, _, headers, err := imp.openCsv()
+	if err != nil {
+		return nil, err
 	}
+	_ = fileReader.Close()
+	return headers, nil
+}
 
-	emailIndex := helperTypes.StringSlice(headers).IndexOf(imp.emailKey)
-	if emailIndex == -1 {
-		return nil, KeyNotFoundError{
-			key:   imp.emailKey,
+// bindColumns finds the indexes of `valueKey` and `columnKeys` in `headers` and binds the importer filter to them,
+// `filter` being nil when the importer has none
+func (imp *csvCustomerImporter) bindColumns(headers helperTypes.StringSlice, valueKey string, columnKeys ...string) (valueIndex int, columnIndexes []int, filter *rowFilter.Matcher, err error) {
+	valueIndex = headers.IndexOf(valueKey)
+	if valueIndex == -1 {
+		return 0, nil, nil, KeyNotFoundError{
+			key:   valueKey,
 			slice: headers,
 		}
 	}
+	columnIndexes = make([]int, len(columnKeys))
+	for i, key := range columnKeys {
+		columnIndexes[i] = headers.IndexOf(key)
+		if columnIndexes[i] == -1 {
+			return 0, nil, nil, KeyNotFoundError{
+				key:   key,
+				slice: headers,
+			}
+		}
+	}
+	if imp.filter != nil {
+		filter, err = imp.filter.Bind(headers)
+		if err != nil {
+			return 0, nil, nil, fmt.Errorf("couldn't apply filter: %w", err)
+		}
+	}
+	return valueIndex, columnIndexes, filter, nil
+}
 
-	emailAddresses = make(chan emailAddress)
+// recordsGenerator streams the value of the `valueKey` column for every row of the csv file matching the importer
+// filter, along with the values of `columnKeys`.
+// Rows whose value doesn't pass `validate` are logged and ignored
+func (imp *csvCustomerImporter) recordsGenerator(valueKey string, validate func(value string) error, columnKeys ...string) (records chan customerRecord, err error) {
+	fileReader, csvReader, headers, err := imp.openCsv()
+	if err != nil {
+		return nil, err
+	}
+	valueIndex, columnIndexes, filter, err := imp.bindColumns(headers, valueKey, columnKeys...)
+	if err != nil {
+		_ = fileReader.Close()
+		return nil, err
+	}
+
+	records = make(chan customerRecord)
 
 	go func() {
 		defer func() {
-			err = fileReader.Close()
-			if err != nil {
+			if err := fileReader.Close(); err != nil {
 				log.Printf("error trying to close file: %s", err)
 			}
 		}()
-		defer close(emailAddresses)
+		defer close(records)
 
 		for {
 			row, err := csvReader.Read()
@@ -100,7 +224,7 @@ func (imp *csvCustomerImporter) emailAddressesGenerator() (emailAddresses chan e
 					return
 				// We could explore adding a case here for ErrFieldCount, maybe there's a missing field but the email is still there?
 				default:
-					emailAddresses <- emailAddress{Address: "", Err: err}
+					records <- customerRecord{Value: "", Err: err}
 					continue
 				}
 			}
@@ -108,50 +232,200 @@ func (imp *csvCustomerImporter) emailAddressesGenerator() (emailAddresses chan e
 				// csv.Reader does not error out with ErrFieldCount when the row is empty
 				continue
 			}
-			if !regex.MatchString(row[emailIndex]) {
-				log.Printf("ignoring row %v, email address not compliant with RFC 5322", row)
+			if filter != nil && !filter.Match(row) {
 				continue
 			}
-			emailAddresses <- emailAddress{Address: row[emailIndex], Err: nil}
+			if err := validate(row[valueIndex]); err != nil {
+				log.Printf("ignoring row %v, %s", row, err)
+				continue
+			}
+			var columns []string
+			if len(columnIndexes) > 0 {
+				columns = make([]string, len(columnIndexes))
+				for i, index := range columnIndexes {
+					columns[i] = row[index]
+				}
+			}
+			line, _ := csvReader.FieldPos(0)
+			records <- customerRecord{Value: row[valueIndex], Columns: columns, Line: line, Err: nil}
 		}
 	}()
-	return emailAddresses, nil
+	return records, nil
 }
 
-type emailDomain struct {
+type EmailDomain struct {
 	Domain        string
 	CustomerCount int
+	// Category classifies the domain as corporate, freemail, disposable or any other category in the importer rules
+	Category string
+	// Deliverability is only set after checking the domain with a deliverabilityChecker
+	Deliverability string
+	// BySource holds the customers of the domain in each importer given to CombineCustomerCounts, nil otherwise
+	BySource []int
 }
 
 //CustomerCountByDomain outputs the count of customers for each email domain in the csv file you introduced in the constructor
-func (imp *csvCustomerImporter) CustomerCountByDomain() (sortedDomains []emailDomain, err error) {
-	emailAddresses, err := imp.emailAddressesGenerator()
+// When the unique domains go over the importer memory budget, their counts are spilled to disk and merged back at the end
+// With more than one worker, see WithWorkers, addresses are validated and counted concurrently with the same result
+func (imp *csvCustomerImporter) CustomerCountByDomain() (sortedDomains []EmailDomain, err error) {
+	sortedDomains, _, err = imp.CustomerCountByDomainWithStats()
+	return
+}
+
+// CustomerCountByDomainWithStats works like CustomerCountByDomain, also describing how the import went: the rows read
+// and rejected by reason, the unique domains and addresses, its duration, throughput and peak memory.
+// Importers created WithMetrics record the import in their metrics
+func (imp *csvCustomerImporter) CustomerCountByDomainWithStats() (sortedDomains []EmailDomain, stats RunStats, err error) {
+	return imp.countByDomain(nil)
+}
+
+// countByDomain counts the customers of every domain, handing them to `live` as well unless it's nil
+func (imp *csvCustomerImporter) countByDomain(live *liveTracker) (sortedDomains []EmailDomain, stats RunStats, err error) {
+	started := time.Now()
+	memory := sampleMemory()
+	input, err := imp.openInput()
+	if err != nil {
+		stats.PeakMemory = memory.stopSampling()
+		stats.finish(time.Since(started))
+		imp.metrics.observeImport(stats, err)
+		return nil, stats, err
+	}
+	defer func() {
+		stats.BytesRead = input.bytesRead()
+		stats.UniqueDomains = len(sortedDomains)
+		stats.PeakMemory = memory.stopSampling()
+		stats.finish(time.Since(started))
+		imp.metrics.observeImport(stats, err)
+		if err := input.Close(); err != nil {
+			log.Printf("error trying to close file: %s", err)
+		}
+	}()
+	stats.ReadPath = input.readPath
+
+	var counter *domainCounter
+	if imp.workers > 1 {
+		counter, err = imp.countDomainsConcurrently(input, &stats, live)
+	} else {
+		counter, err = imp.countDomains(input, &stats, live)
+	}
+	if err != nil {
+		return nil, stats, err
+	}
+	defer counter.cleanup()
+	counting := time.Since(started)
+
+	err = counter.each(func(domain string, count int) {
+		sortedDomains = append(sortedDomains, EmailDomain{
+			Domain:        domain,
+			CustomerCount: count,
+			Category:      imp.classifier.Classify(domain),
+		})
+	})
+	if err != nil {
+		return nil, stats, fmt.Errorf("couldn't count domains: %w", err)
+	}
+	if imp.ordering != OrderByDomain {
+		sortedDomains = SortDomains(sortedDomains, imp.ordering)
+	}
+	imp.metrics.observeCounting(counting, len(sortedDomains))
+	// The counter hands the domains over sorted by domain, so that's timed as sorting as well
+	imp.metrics.observeSort(time.Since(started)-counting, imp.ordering)
+	return
+}
+
+// countDomains counts the customers of every domain reading the addresses one by one, adding the rows read to `stats`
+// and the customers to `live`
+func (imp *csvCustomerImporter) countDomains(input *csvInput, stats *RunStats, live *liveTracker) (*domainCounter, error) {
+	scanner := input.scanner(imp.dialect)
+	if err := scanner.Scan(); err != nil {
+		return nil, fmt.Errorf("couldn't read headers row on CSV file: %w", err)
+	}
+	emailIndex, _, filter, err := imp.bindColumns(scanner.Strings(), imp.emailKey)
 	if err != nil {
 		return nil, fmt.Errorf("couldn't create address generator: %w", err)
 	}
 
-	customerCountByDomain := make(map[string]int)
-	sorter := radixSorter.NewRadixSorter()
+	counter := newDomainCounter(imp.memoryBudget)
+	if err := countRecords(scanner, emailIndex, filter, counter, stats, live); err != nil {
+		counter.cleanup()
+		return nil, err
+	}
+	return counter, nil
+}
 
-	for address := range emailAddresses {
-		if address.Err != nil {
-			log.Printf("couldn't process row: %s", address.Err)
+// countRecords adds the domain of the email address of every record left in `scanner` to `counter`, and the rows read
+// and rejected and the addresses to `stats`, and the customers to `live` unless it's nil.
+// The email column is scanned as bytes and lowercased into a reusable buffer, so only new domains allocate
+func countRecords(scanner *recordScanner, emailIndex int, filter *rowFilter.Matcher, counter *domainCounter, stats *RunStats, live *liveTracker) error {
+	var address []byte
+	batch := live.newBatch()
+	defer batch.flush()
+	for {
+		err := scanner.Scan()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			var parseError *csv.ParseError
+			if !errors.As(err, &parseError) {
+				return fmt.Errorf("couldn't read CSV file: %w", err)
+			}
+			stats.RowsRead++
+			stats.RowsRejected++
+			stats.Rejected.Malformed++
+			log.Printf("couldn't process row: %s", err)
 			continue
 		}
-		splitAddress := strings.Split(address.Address, "@")
-		domain := strings.ToLower(splitAddress[len(splitAddress)-1])
-
-		if _, exists := customerCountByDomain[domain]; !exists {
-			sorter.Add(domain)
+		stats.RowsRead++
+		if filter != nil && !filter.Match(scanner.Strings()) {
+			continue
 		}
-		customerCountByDomain[domain] += 1
+		field := scanner.Field(emailIndex)
+		if !emailRegexp.Match(field) {
+			stats.RowsRejected++
+			if len(bytes.TrimSpace(field)) == 0 {
+				stats.Rejected.EmptyEmail++
+				log.Printf("ignoring row %v, %s", scanner.Strings(), errEmptyEmailAddress)
+			} else {
+				stats.Rejected.InvalidEmail++
+				log.Printf("ignoring row %v, %s", scanner.Strings(), errInvalidEmailAddress)
+			}
+			continue
+		}
+		address = appendLower(address[:0], field)
+		stats.addAddress(address)
+		domain := address[bytes.LastIndexByte(address, '@')+1:]
+		if err := counter.addBytes(domain); err != nil {
+			return fmt.Errorf("couldn't count domains: %w", err)
+		}
+		batch.add(domain)
 	}
+}
 
-	for _, domain := range sorter.Sort() {
-		sortedDomains = append(sortedDomains, emailDomain{
-			Domain:        domain,
-			CustomerCount: customerCountByDomain[domain],
-		})
+// domainOf extracts the lowercase domain part of an email address
+func domainOf(address string) string {
+	splitAddress := strings.Split(address, "@")
+	return strings.ToLower(splitAddress[len(splitAddress)-1])
+}
+
+// appendDomain appends the lowercase domain part of `address` to `dst`, like domainOf but without allocating for ASCII
+// domains
+func appendDomain(dst []byte, address []byte) []byte {
+	return appendLower(dst, address[bytes.LastIndexByte(address, '@')+1:])
+}
+
+// appendLower appends `s` lowercased to `dst`, without allocating for ASCII
+func appendLower(dst []byte, s []byte) []byte {
+	for _, b := range s {
+		if b >= utf8.RuneSelf {
+			return append(dst, bytes.ToLower(s)...)
+		}
 	}
-	return
+	for _, b := range s {
+		if 'A' <= b && b <= 'Z' {
+			b += 'a' - 'A'
+		}
+		dst = append(dst, b)
+	}
+	return dst
 }
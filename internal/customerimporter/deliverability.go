@@ -0,0 +1,167 @@
+package customerimporter
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DeliverabilityDeliverable = "deliverable"
+	DeliverabilityNoMX        = "no MX"
+	DeliverabilityNXDomain    = "NXDOMAIN"
+	DeliverabilityTimeout     = "timeout"
+	// DeliverabilityUnknown is used when the resolver fails for any other reason, or can't tell whether a domain exists
+	DeliverabilityUnknown = "unknown"
+)
+
+// Resolver looks up the DNS records needed to know whether a domain can receive email, *net.Resolver implements it
+type Resolver interface {
+	LookupMX(ctx context.Context, name string) ([]*net.MX, error)
+	LookupHost(ctx context.Context, host string) (addrs []string, err error)
+	LookupNS(ctx context.Context, name string) ([]*net.NS, error)
+}
+
+// deliverabilityChecker checks whether domains can receive email through a Resolver, caching the status of every
+// domain it has checked
+type deliverabilityChecker struct {
+	resolver    Resolver
+	concurrency int
+	timeout     time.Duration
+	mut         *sync.Mutex
+	cache       map[string]*deliverabilityEntry
+}
+
+type deliverabilityEntry struct {
+	once   sync.Once
+	status string
+}
+
+// NewDeliverabilityChecker constructor for deliverabilityChecker, at most `concurrency` domains are checked at the
+// same time and each one of them can take up to `timeout`
+func NewDeliverabilityChecker(resolver Resolver, concurrency int, timeout time.Duration) *deliverabilityChecker {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	return &deliverabilityChecker{
+		resolver:    resolver,
+		concurrency: concurrency,
+		timeout:     timeout,
+		mut:         &sync.Mutex{},
+		cache:       make(map[string]*deliverabilityEntry),
+	}
+}
+
+// NewSystemDeliverabilityChecker constructor for a deliverabilityChecker using the system resolver
+func NewSystemDeliverabilityChecker(concurrency int, timeout time.Duration) *deliverabilityChecker {
+	return NewDeliverabilityChecker(net.DefaultResolver, concurrency, timeout)
+}
+
+// CheckDomains returns a copy of `domains` with their Deliverability set
+func (c *deliverabilityChecker) CheckDomains(ctx context.Context, domains []EmailDomain) []EmailDomain {
+	checked := make([]EmailDomain, len(domains))
+	copy(checked, domains)
+
+	var wg sync.WaitGroup
+	semaphore := make(chan struct{}, c.concurrency)
+	for i := range checked {
+		wg.Add(1)
+		semaphore <- struct{}{}
+		go func(domain *EmailDomain) {
+			defer wg.Done()
+			domain.Deliverability = c.Check(ctx, domain.Domain)
+			<-semaphore
+		}(&checked[i])
+	}
+	wg.Wait()
+	return checked
+}
+
+// Check returns the deliverability status of `domain`, looking it up only the first time it's asked for
+func (c *deliverabilityChecker) Check(ctx context.Context, domain string) string {
+	c.mut.Lock()
+	entry, found := c.cache[domain]
+	if !found {
+		entry = &deliverabilityEntry{}
+		c.cache[domain] = entry
+	}
+	c.mut.Unlock()
+
+	entry.once.Do(func() {
+		entry.status = c.lookup(ctx, domain)
+	})
+	return entry.status
+}
+
+// lookup resolves the MX records of `domain`, falling back to its A/AAAA records as RFC 5321 does when there are none.
+// Resolvers report both a domain which doesn't exist (NXDOMAIN) and one without records of the type asked for (NODATA)
+// as not found, the response code telling them apart isn't exposed by the net package. Only zone apexes have NS
+// records, so a domain without any is only NXDOMAIN right under a top-level domain, where names only exist as
+// delegations. Deeper ones may exist in the zone of their parent with other records, and are reported as unknown
+func (c *deliverabilityChecker) lookup(ctx context.Context, domain string) string {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	records, mxErr := c.resolver.LookupMX(ctx, domain)
+	switch {
+	case mxErr == nil && len(records) == 1 && (records[0].Host == "." || records[0].Host == ""):
+		// A null MX record (RFC 7505) means the domain explicitly doesn't accept email
+		return DeliverabilityNoMX
+	case mxErr == nil && len(records) > 0:
+		return DeliverabilityDeliverable
+	case isTimeout(mxErr):
+		return DeliverabilityTimeout
+	case mxErr != nil && !isNotFound(mxErr):
+		return DeliverabilityUnknown
+	}
+
+	addresses, hostErr := c.resolver.LookupHost(ctx, domain)
+	switch {
+	case hostErr == nil && len(addresses) > 0:
+		return DeliverabilityDeliverable
+	case isTimeout(hostErr):
+		return DeliverabilityTimeout
+	case hostErr != nil && !isNotFound(hostErr):
+		return DeliverabilityUnknown
+	case hostErr == nil || mxErr == nil:
+		// An empty answer still means the domain exists
+		return DeliverabilityNoMX
+	}
+
+	// Neither MX nor address records were found, the domain still exists if it has name servers
+	servers, nsErr := c.resolver.LookupNS(ctx, domain)
+	switch {
+	case nsErr == nil && len(servers) > 0:
+		return DeliverabilityNoMX
+	case isTimeout(nsErr):
+		return DeliverabilityTimeout
+	case nsErr != nil && !isNotFound(nsErr):
+		return DeliverabilityUnknown
+	case strings.Count(strings.TrimSuffix(domain, "."), ".") == 1:
+		return DeliverabilityNXDomain
+	default:
+		return DeliverabilityUnknown
+	}
+}
+
+func isTimeout(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+func isNotFound(err error) bool {
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr) && dnsErr.IsNotFound
+}
@@ -0,0 +1,143 @@
+package customerimporter
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeResolver is an in-memory Resolver, domains missing from every map don't exist. Like a real resolver, it reports
+// records missing from an existing domain as not found
+type fakeResolver struct {
+	mx      map[string][]*net.MX
+	hosts   map[string][]string
+	ns      map[string][]*net.NS
+	errs    map[string]error
+	slow    map[string]bool
+	mut     sync.Mutex
+	lookups int
+}
+
+func (r *fakeResolver) lookup(ctx context.Context, name string) error {
+	r.mut.Lock()
+	r.lookups++
+	r.mut.Unlock()
+	if r.slow[name] {
+		<-ctx.Done()
+		return &net.DNSError{Err: "i/o timeout", Name: name, IsTimeout: true}
+	}
+	return r.errs[name]
+}
+
+func (r *fakeResolver) LookupMX(ctx context.Context, name string) ([]*net.MX, error) {
+	if err := r.lookup(ctx, name); err != nil {
+		return nil, err
+	}
+	if records, found := r.mx[name]; found {
+		return records, nil
+	}
+	return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+}
+
+func (r *fakeResolver) LookupHost(ctx context.Context, name string) ([]string, error) {
+	if err := r.lookup(ctx, name); err != nil {
+		return nil, err
+	}
+	if addresses, found := r.hosts[name]; found {
+		return addresses, nil
+	}
+	return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+}
+
+func (r *fakeResolver) LookupNS(ctx context.Context, name string) ([]*net.NS, error) {
+	if err := r.lookup(ctx, name); err != nil {
+		return nil, err
+	}
+	if servers, found := r.ns[name]; found {
+		return servers, nil
+	}
+	return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+}
+
+func newFakeResolver() *fakeResolver {
+	return &fakeResolver{
+		mx: map[string][]*net.MX{
+			"gmail.com":  {{Host: "gmail-smtp-in.l.google.com.", Pref: 5}},
+			"nomail.com": {{Host: ".", Pref: 0}},
+			// An empty answer without an error, which some resolvers give
+			"empty.com": {},
+		},
+		hosts: map[string][]string{
+			"implicit.io": {"192.0.2.1"},
+		},
+		// nodata.com exists without MX nor address records
+		ns: map[string][]*net.NS{
+			"nodata.com":  {{Host: "ns1.nodata.com."}},
+			"implicit.io": {{Host: "ns1.implicit.io."}},
+		},
+		errs: map[string]error{
+			"broken.com": errors.New("server misbehaving"),
+		},
+		slow: map[string]bool{
+			"slow.com": true,
+		},
+	}
+}
+
+func Test_deliverabilityChecker_Check(t *testing.T) {
+	checker := NewDeliverabilityChecker(newFakeResolver(), 4, 10*time.Millisecond)
+	tests := []struct {
+		domain string
+		want   string
+	}{
+		{domain: "gmail.com", want: DeliverabilityDeliverable},
+		{domain: "implicit.io", want: DeliverabilityDeliverable},
+		{domain: "nomail.com", want: DeliverabilityNoMX},
+		{domain: "nodata.com", want: DeliverabilityNoMX},
+		{domain: "empty.com", want: DeliverabilityNoMX},
+		{domain: "missing.com", want: DeliverabilityNXDomain},
+		// A subdomain may exist without NS records, so it can't be told from one which doesn't
+		{domain: "mail.missing.com", want: DeliverabilityUnknown},
+		{domain: "mail.nodata.com", want: DeliverabilityUnknown},
+		{domain: "slow.com", want: DeliverabilityTimeout},
+		{domain: "broken.com", want: DeliverabilityUnknown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.domain, func(t *testing.T) {
+			if got := checker.Check(context.Background(), tt.domain); got != tt.want {
+				t.Errorf("Check() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_deliverabilityChecker_CheckDomains(t *testing.T) {
+	resolver := newFakeResolver()
+	checker := NewDeliverabilityChecker(resolver, 2, time.Second)
+	domains := []EmailDomain{
+		{Domain: "gmail.com", CustomerCount: 3},
+		{Domain: "implicit.io", CustomerCount: 2},
+		{Domain: "missing.com", CustomerCount: 1},
+	}
+	want := []EmailDomain{
+		{Domain: "gmail.com", CustomerCount: 3, Deliverability: DeliverabilityDeliverable},
+		{Domain: "implicit.io", CustomerCount: 2, Deliverability: DeliverabilityDeliverable},
+		{Domain: "missing.com", CustomerCount: 1, Deliverability: DeliverabilityNXDomain},
+	}
+	if got := checker.CheckDomains(context.Background(), domains); !reflect.DeepEqual(got, want) {
+		t.Errorf("CheckDomains() = %v, want %v", got, want)
+	}
+	if domains[0].Deliverability != "" {
+		t.Errorf("CheckDomains() modified its input")
+	}
+
+	lookups := resolver.lookups
+	checker.CheckDomains(context.Background(), domains)
+	if resolver.lookups != lookups {
+		t.Errorf("CheckDomains() made %d lookups for cached domains", resolver.lookups-lookups)
+	}
+}
@@ -0,0 +1,30 @@
+package customerimporter
+
+// Dialect describes the flavour of csv of a file, only ASCII delimiters and comment characters are supported
+type Dialect struct {
+	// Delimiter separates the fields of a record, ',' when zero
+	Delimiter byte
+	// Comment starts lines to be ignored, no line is ignored when zero
+	Comment byte
+}
+
+// delimiter returns the delimiter of the dialect, a comma for the zero value
+func (d Dialect) delimiter() byte {
+	if d.Delimiter == 0 {
+		return ','
+	}
+	return d.Delimiter
+}
+
+// validate checks the dialect can be parsed
+func (d Dialect) validate() error {
+	for _, char := range []byte{d.delimiter(), d.Comment} {
+		if char == '"' || char == '\r' || char == '\n' || char >= 0x80 {
+			return InvalidDialectError{dialect: d}
+		}
+	}
+	if d.delimiter() == d.Comment {
+		return InvalidDialectError{dialect: d}
+	}
+	return nil
+}
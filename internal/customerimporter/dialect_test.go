@@ -0,0 +1,71 @@
+package customerimporter
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestWithDialect(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	csvFile, err := ioutil.ReadFile("../../test/data/importer/customers-small.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	semicolonPath := filepath.Join(t.TempDir(), "semicolons.csv")
+	semicolonFile := append([]byte("# exported from the CRM\n"), bytes.ReplaceAll(csvFile, []byte{','}, []byte{';'})...)
+	semicolonFile = append(semicolonFile, "# 8 customers\n"...)
+	if err := ioutil.WriteFile(semicolonPath, semicolonFile, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	imp, err := NewCsvCustomerImporter("../../test/data/importer/customers-small.csv", "email")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := imp.CustomerCountByDomain()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, workers := range []int{1, 2} {
+		imp, err := NewCsvCustomerImporter(semicolonPath, "email", WithDialect(Dialect{Delimiter: ';', Comment: '#'}), WithWorkers(workers))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, stats, err := imp.CustomerCountByDomainWithStats()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("CustomerCountByDomainWithStats() with %d workers = %v, want %v", workers, got, want)
+		}
+		if stats.RowsRead != 8 || stats.RowsRejected != 1 {
+			t.Errorf("CustomerCountByDomainWithStats() with %d workers read %d rows and rejected %d, want 8 and 1", workers, stats.RowsRead, stats.RowsRejected)
+		}
+	}
+}
+
+func TestWithDialect_invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		dialect Dialect
+	}{
+		{name: "quote delimiter", dialect: Dialect{Delimiter: '"'}},
+		{name: "line break comment", dialect: Dialect{Comment: '\n'}},
+		{name: "non ASCII delimiter", dialect: Dialect{Delimiter: 0xa7}},
+		{name: "comment like the delimiter", dialect: Dialect{Comment: ','}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewCsvCustomerImporter("../../test/data/importer/customers-small.csv", "email", WithDialect(tt.dialect))
+			var invalidDialect InvalidDialectError
+			if !errors.As(err, &invalidDialect) {
+				t.Errorf("NewCsvCustomerImporter() error = %v, want an InvalidDialectError", err)
+			}
+		})
+	}
+}
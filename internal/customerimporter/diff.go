@@ -0,0 +1,134 @@
+package customerimporter
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/IllicLanthresh/TeamworkGoTests/pkg/radixSorter"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	ChangeAdded     = "added"
+	ChangeRemoved   = "removed"
+	ChangeChanged   = "changed"
+	ChangeUnchanged = "unchanged"
+)
+
+// domainDiff compares the customer count of every domain in two results, along with the totals of both
+type domainDiff struct {
+	Changes         []domainChange `json:"changes"`
+	CustomersBefore int            `json:"customers_before"`
+	CustomersAfter  int            `json:"customers_after"`
+}
+
+// domainChange holds the customer count of a domain before and after, a domain missing from a result counts zero
+type domainChange struct {
+	Domain string `json:"domain"`
+	Before int    `json:"before"`
+	After  int    `json:"after"`
+	Delta  int    `json:"delta"`
+	// Change is ChangeAdded, ChangeRemoved, ChangeChanged or ChangeUnchanged
+	Change string `json:"change"`
+}
+
+// DiffDomains compares the domains of two results, changes are sorted by domain and unchanged domains are only kept
+// if `keepUnchanged` is set. Domains are compared ignoring case, the counts of a domain appearing more than once in a
+// result are added up
+func DiffDomains(before, after []EmailDomain, keepUnchanged bool) *domainDiff {
+	diff := &domainDiff{Changes: []domainChange{}}
+	var changes []domainChange
+	var inBefore, inAfter []bool
+	indexOf := make(map[string]int)
+	add := func(domain EmailDomain, isAfter bool) {
+		key := strings.ToLower(domain.Domain)
+		index, found := indexOf[key]
+		if !found {
+			index = len(changes)
+			indexOf[key] = index
+			changes = append(changes, domainChange{Domain: key})
+			inBefore, inAfter = append(inBefore, false), append(inAfter, false)
+		}
+		if isAfter {
+			changes[index].After += domain.CustomerCount
+			inAfter[index] = true
+			diff.CustomersAfter += domain.CustomerCount
+		} else {
+			changes[index].Before += domain.CustomerCount
+			inBefore[index] = true
+			diff.CustomersBefore += domain.CustomerCount
+		}
+	}
+	for _, domain := range before {
+		add(domain, false)
+	}
+	for _, domain := range after {
+		add(domain, true)
+	}
+
+	names := make([]string, len(changes))
+	for i, change := range changes {
+		names[i] = change.Domain
+	}
+	for _, index := range radixSorter.StringOrder(names) {
+		change := changes[index]
+		change.Delta = change.After - change.Before
+		switch {
+		case !inBefore[index]:
+			change.Change = ChangeAdded
+		case !inAfter[index]:
+			change.Change = ChangeRemoved
+		case change.Delta != 0:
+			change.Change = ChangeChanged
+		default:
+			change.Change = ChangeUnchanged
+		}
+		if change.Change != ChangeUnchanged || keepUnchanged {
+			diff.Changes = append(diff.Changes, change)
+		}
+	}
+	return diff
+}
+
+// WriteText renders a line per change, prefixed with + for added domains, - for removed ones and ~ for the rest,
+// followed by a line with the totals
+func (d *domainDiff) WriteText(w io.Writer) error {
+	prefixes := map[string]string{ChangeAdded: "+", ChangeRemoved: "-", ChangeChanged: "~", ChangeUnchanged: " "}
+	for _, change := range d.Changes {
+		_, err := fmt.Fprintf(w, "%s %s %d -> %d (%+d)\n", prefixes[change.Change], change.Domain, change.Before, change.After, change.Delta)
+		if err != nil {
+			return fmt.Errorf("couldn't write diff as text: %w", err)
+		}
+	}
+	_, err := fmt.Fprintf(w, "customers %d -> %d (%+d)\n", d.CustomersBefore, d.CustomersAfter, d.CustomersAfter-d.CustomersBefore)
+	if err != nil {
+		return fmt.Errorf("couldn't write diff as text: %w", err)
+	}
+	return nil
+}
+
+// WriteCsv renders a row per change, without the totals
+func (d *domainDiff) WriteCsv(w io.Writer) error {
+	records := [][]string{{"domain", "before", "after", "delta", "change"}}
+	for _, change := range d.Changes {
+		records = append(records, []string{
+			change.Domain, strconv.Itoa(change.Before), strconv.Itoa(change.After), strconv.Itoa(change.Delta), change.Change,
+		})
+	}
+	if err := csv.NewWriter(w).WriteAll(records); err != nil {
+		return fmt.Errorf("couldn't write diff as CSV: %w", err)
+	}
+	return nil
+}
+
+// WriteJson renders the changes and the totals as a JSON object
+func (d *domainDiff) WriteJson(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(d); err != nil {
+		return fmt.Errorf("couldn't write diff as JSON: %w", err)
+	}
+	return nil
+}
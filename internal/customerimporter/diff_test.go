@@ -0,0 +1,77 @@
+package customerimporter
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestDiffDomains(t *testing.T) {
+	before := []EmailDomain{
+		{Domain: "github.io", CustomerCount: 4},
+		{Domain: "360.cn", CustomerCount: 1},
+		{Domain: "old.com", CustomerCount: 3},
+	}
+	after := []EmailDomain{
+		{Domain: "GitHub.io", CustomerCount: 6},
+		{Domain: "360.cn", CustomerCount: 1},
+		{Domain: "new.com", CustomerCount: 2},
+		{Domain: "new.com", CustomerCount: 1},
+	}
+	tests := []struct {
+		name          string
+		keepUnchanged bool
+		want          []domainChange
+	}{
+		{
+			name: "changes only",
+			want: []domainChange{
+				{Domain: "github.io", Before: 4, After: 6, Delta: 2, Change: ChangeChanged},
+				{Domain: "new.com", Before: 0, After: 3, Delta: 3, Change: ChangeAdded},
+				{Domain: "old.com", Before: 3, After: 0, Delta: -3, Change: ChangeRemoved},
+			},
+		},
+		{
+			name:          "unchanged too",
+			keepUnchanged: true,
+			want: []domainChange{
+				{Domain: "360.cn", Before: 1, After: 1, Delta: 0, Change: ChangeUnchanged},
+				{Domain: "github.io", Before: 4, After: 6, Delta: 2, Change: ChangeChanged},
+				{Domain: "new.com", Before: 0, After: 3, Delta: 3, Change: ChangeAdded},
+				{Domain: "old.com", Before: 3, After: 0, Delta: -3, Change: ChangeRemoved},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diff := DiffDomains(before, after, tt.keepUnchanged)
+			if !reflect.DeepEqual(diff.Changes, tt.want) {
+				t.Errorf("DiffDomains() = %+v, want %+v", diff.Changes, tt.want)
+			}
+			if diff.CustomersBefore != 8 || diff.CustomersAfter != 10 {
+				t.Errorf("DiffDomains() customers = %d -> %d, want 8 -> 10", diff.CustomersBefore, diff.CustomersAfter)
+			}
+		})
+	}
+}
+
+func Test_domainDiff_WriteText(t *testing.T) {
+	diff := &domainDiff{
+		Changes: []domainChange{
+			{Domain: "github.io", Before: 4, After: 6, Delta: 2, Change: ChangeChanged},
+			{Domain: "old.com", Before: 3, After: 0, Delta: -3, Change: ChangeRemoved},
+		},
+		CustomersBefore: 7,
+		CustomersAfter:  6,
+	}
+	want := "~ github.io 4 -> 6 (+2)\n" +
+		"- old.com 3 -> 0 (-3)\n" +
+		"customers 7 -> 6 (-1)\n"
+	var got bytes.Buffer
+	if err := diff.WriteText(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.String() != want {
+		t.Errorf("WriteText() = %q, want %q", got.String(), want)
+	}
+}
@@ -0,0 +1,216 @@
+package customerimporter
+
+import (
+	"fmt"
+	"github.com/IllicLanthresh/TeamworkGoTests/pkg/radixSorter"
+	"log"
+	"strconv"
+	"strings"
+)
+
+// duplicateRowOverhead is a rough estimate of the bytes used by a duplicateRow and its bookkeeping besides its strings
+const duplicateRowOverhead = 96
+
+// duplicateGroup holds every row sharing the same email address
+type duplicateGroup struct {
+	// Address is the address shared by every row, lowercase and canonicalized if requested
+	Address string
+	Rows    []duplicateRow
+	// DifferingColumns lists the columns whose values are not the same in every row, the email column included
+	DifferingColumns []string
+}
+
+type duplicateRow struct {
+	Line int
+	// Address is the address as written in the row
+	Address    string
+	Attributes []string
+}
+
+// DuplicateCustomers outputs the groups of rows sharing the same email address, sorted by address.
+// Addresses are always compared ignoring their case, if `canonicalize` is set tags (`+tag`) are stripped and dots
+// ignored for gmail.com addresses too.
+// The values of `attributeKeys` are reported for every row, every other column is reported if none is given.
+// When the rows kept in memory go over the importer memory budget they are spilled to disk.
+func (imp *csvCustomerImporter) DuplicateCustomers(canonicalize bool, attributeKeys ...string) (groups []duplicateGroup, err error) {
+	if len(attributeKeys) == 0 {
+		headers, err := imp.Headers()
+		if err != nil {
+			return nil, err
+		}
+		for _, header := range headers {
+			if header != imp.emailKey {
+				attributeKeys = append(attributeKeys, header)
+			}
+		}
+	}
+	emailAddresses, err := imp.emailAddressesGenerator(attributeKeys...)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't create address generator: %w", err)
+	}
+
+	var runs *spillRuns
+	defer func() {
+		if runs != nil {
+			runs.cleanup()
+		}
+	}()
+	rowsByAddress := make(map[string][]duplicateRow)
+	var usedMemory int64
+
+	for address := range emailAddresses {
+		if address.Err != nil {
+			log.Printf("couldn't process row: %s", address.Err)
+			continue
+		}
+		key := canonicalAddress(address.Value, canonicalize)
+		rowsByAddress[key] = append(rowsByAddress[key], duplicateRow{
+			Line:       address.Line,
+			Address:    address.Value,
+			Attributes: address.Columns,
+		})
+
+		usedMemory += int64(duplicateRowOverhead + len(key) + len(address.Value))
+		for _, attribute := range address.Columns {
+			usedMemory += int64(len(attribute))
+		}
+		if usedMemory > imp.memoryBudget {
+			if runs == nil {
+				if runs, err = newSpillRuns(); err != nil {
+					drain(emailAddresses)
+					return nil, err
+				}
+			}
+			if err = spillDuplicateRows(runs, rowsByAddress); err != nil {
+				drain(emailAddresses)
+				return nil, err
+			}
+			rowsByAddress = make(map[string][]duplicateRow)
+			usedMemory = 0
+		}
+	}
+
+	addGroup := func(group *duplicateGroup) {
+		group.DifferingColumns = differingColumns(group.Rows, imp.emailKey, attributeKeys)
+		groups = append(groups, *group)
+	}
+
+	if runs == nil {
+		sorter := radixSorter.NewRadixSorter()
+		for key, rows := range rowsByAddress {
+			if len(rows) > 1 {
+				sorter.Add(key)
+			}
+		}
+		for _, key := range sorter.Sort() {
+			addGroup(&duplicateGroup{Address: key, Rows: rowsByAddress[key]})
+		}
+		return groups, nil
+	}
+
+	if err = spillDuplicateRows(runs, rowsByAddress); err != nil {
+		return nil, err
+	}
+	var current *duplicateGroup
+	err = runs.merge(func(record []string) error {
+		row, err := duplicateRowFromRecord(record)
+		if err != nil {
+			return err
+		}
+		if current != nil && current.Address != record[0] {
+			if len(current.Rows) > 1 {
+				addGroup(current)
+			}
+			current = nil
+		}
+		if current == nil {
+			current = &duplicateGroup{Address: record[0]}
+		}
+		current.Rows = append(current.Rows, row)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if current != nil && len(current.Rows) > 1 {
+		addGroup(current)
+	}
+	return groups, nil
+}
+
+// spillDuplicateRows writes a run holding every row in `rowsByAddress` sorted by address, rows of the same address
+// are kept in the order they were read
+func spillDuplicateRows(runs *spillRuns, rowsByAddress map[string][]duplicateRow) error {
+	sorter := radixSorter.NewRadixSorter()
+	for key := range rowsByAddress {
+		sorter.Add(key)
+	}
+	return runs.write(func(write func(record []string) error) error {
+		for _, key := range sorter.Sort() {
+			for _, row := range rowsByAddress[key] {
+				record := append([]string{key, strconv.Itoa(row.Line), row.Address}, row.Attributes...)
+				if err := write(record); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+func duplicateRowFromRecord(record []string) (duplicateRow, error) {
+	line, err := strconv.Atoi(record[1])
+	if err != nil {
+		return duplicateRow{}, fmt.Errorf("corrupted spill run: %w", err)
+	}
+	return duplicateRow{Line: line, Address: record[2], Attributes: record[3:]}, nil
+}
+
+// differingColumns returns the columns, among the email one and `attributeKeys`, not having the same value in all rows
+func differingColumns(rows []duplicateRow, emailKey string, attributeKeys []string) (columns []string) {
+	for _, row := range rows[1:] {
+		if row.Address != rows[0].Address {
+			columns = append(columns, emailKey)
+			break
+		}
+	}
+	for i, key := range attributeKeys {
+		for _, row := range rows[1:] {
+			if row.Attributes[i] != rows[0].Attributes[i] {
+				columns = append(columns, key)
+				break
+			}
+		}
+	}
+	return
+}
+
+// canonicalAddress lowercases `address`, and if `canonicalize` is set it strips its tag and, for gmail addresses,
+// ignores the dots in the local part since gmail does too
+func canonicalAddress(address string, canonicalize bool) string {
+	address = strings.ToLower(address)
+	if !canonicalize {
+		return address
+	}
+	at := strings.LastIndex(address, "@")
+	if at == -1 {
+		return address
+	}
+	local, domain := address[:at], address[at+1:]
+	if tag := strings.Index(local, "+"); tag > 0 {
+		local = local[:tag]
+	}
+	if domain == "googlemail.com" {
+		domain = "gmail.com"
+	}
+	if domain == "gmail.com" {
+		local = strings.ReplaceAll(local, ".", "")
+	}
+	return local + "@" + domain
+}
+
+// drain consumes whatever is left in a generator, so its goroutine can finish and close the file
+func drain(records chan customerRecord) {
+	for range records {
+	}
+}
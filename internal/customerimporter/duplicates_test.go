@@ -0,0 +1,139 @@
+package customerimporter
+
+import (
+	"io/ioutil"
+	"log"
+	"reflect"
+	"testing"
+)
+
+func Test_csvCustomerImporter_DuplicateCustomers(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	type args struct {
+		canonicalize  bool
+		attributeKeys []string
+	}
+	tests := []struct {
+		name       string
+		args       args
+		options    []ImporterOption
+		wantGroups []duplicateGroup
+		wantErr    bool
+	}{
+		{
+			name:    "attribute not found",
+			args:    args{attributeKeys: []string{"quux"}},
+			wantErr: true,
+		},
+		{
+			name: "exact addresses",
+			args: args{attributeKeys: []string{"first_name", "gender"}},
+			wantGroups: []duplicateGroup{
+				{
+					Address: "dhenry2@hubpages.com",
+					Rows: []duplicateRow{
+						{Line: 4, Address: "dhenry2@hubpages.com", Attributes: []string{"Dennis", "Male"}},
+						{Line: 8, Address: "dhenry2@hubpages.com", Attributes: []string{"Dennis", "Male"}},
+					},
+				},
+				{
+					Address: "mhernandez0@github.io",
+					Rows: []duplicateRow{
+						{Line: 2, Address: "mhernandez0@github.io", Attributes: []string{"Mildred", "Female"}},
+						{Line: 5, Address: "mhernandez0@github.io", Attributes: []string{"Mildred", "Male"}},
+						{Line: 9, Address: "mhernandez0@github.io", Attributes: []string{"Millie", "Female"}},
+					},
+					DifferingColumns: []string{"first_name", "gender"},
+				},
+			},
+		},
+		{
+			name: "canonicalized addresses",
+			args: args{canonicalize: true, attributeKeys: []string{"last_name"}},
+			wantGroups: []duplicateGroup{
+				{
+					Address: "bortiz1@gmail.com",
+					Rows: []duplicateRow{
+						{Line: 3, Address: "bortiz1@gmail.com", Attributes: []string{"Ortiz"}},
+						{Line: 6, Address: "b.ortiz1+news@gmail.com", Attributes: []string{"Ortiz"}},
+					},
+					DifferingColumns: []string{"email"},
+				},
+				{
+					Address: "dhenry2@hubpages.com",
+					Rows: []duplicateRow{
+						{Line: 4, Address: "dhenry2@hubpages.com", Attributes: []string{"Henry"}},
+						{Line: 8, Address: "dhenry2@hubpages.com", Attributes: []string{"Henry"}},
+					},
+				},
+				{
+					Address: "mhernandez0@github.io",
+					Rows: []duplicateRow{
+						{Line: 2, Address: "mhernandez0@github.io", Attributes: []string{"Hernandez"}},
+						{Line: 5, Address: "mhernandez0@github.io", Attributes: []string{"Hernandez"}},
+						{Line: 9, Address: "mhernandez0@github.io", Attributes: []string{"Hernandez"}},
+					},
+				},
+			},
+		},
+		{
+			name:    "spilled to disk",
+			args:    args{attributeKeys: []string{"first_name", "gender"}},
+			options: []ImporterOption{WithMemoryBudget(1)},
+			wantGroups: []duplicateGroup{
+				{
+					Address: "dhenry2@hubpages.com",
+					Rows: []duplicateRow{
+						{Line: 4, Address: "dhenry2@hubpages.com", Attributes: []string{"Dennis", "Male"}},
+						{Line: 8, Address: "dhenry2@hubpages.com", Attributes: []string{"Dennis", "Male"}},
+					},
+				},
+				{
+					Address: "mhernandez0@github.io",
+					Rows: []duplicateRow{
+						{Line: 2, Address: "mhernandez0@github.io", Attributes: []string{"Mildred", "Female"}},
+						{Line: 5, Address: "mhernandez0@github.io", Attributes: []string{"Mildred", "Male"}},
+						{Line: 9, Address: "mhernandez0@github.io", Attributes: []string{"Millie", "Female"}},
+					},
+					DifferingColumns: []string{"first_name", "gender"},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			imp, err := NewCsvCustomerImporter("../../test/data/importer/duplicates.csv", "email", tt.options...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			gotGroups, err := imp.DuplicateCustomers(tt.args.canonicalize, tt.args.attributeKeys...)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("DuplicateCustomers() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(gotGroups, tt.wantGroups) {
+				t.Errorf("DuplicateCustomers() = %#v, want %#v", gotGroups, tt.wantGroups)
+			}
+		})
+	}
+}
+
+func Test_canonicalAddress(t *testing.T) {
+	tests := []struct {
+		address      string
+		canonicalize bool
+		want         string
+	}{
+		{address: "Foo.Bar+Baz@Example.com", canonicalize: false, want: "foo.bar+baz@example.com"},
+		{address: "Foo.Bar+Baz@Example.com", canonicalize: true, want: "foo.bar@example.com"},
+		{address: "foo.bar+baz@googlemail.com", canonicalize: true, want: "foobar@gmail.com"},
+		{address: "+foo@example.com", canonicalize: true, want: "+foo@example.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.address, func(t *testing.T) {
+			if got := canonicalAddress(tt.address, tt.canonicalize); got != tt.want {
+				t.Errorf("canonicalAddress() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
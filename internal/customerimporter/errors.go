@@ -27,3 +27,98 @@ type MissingEmailKey struct{}
 func (_ MissingEmailKey) Error() string {
 	return "you need to specify an email key"
 }
+
+type MissingColumnKey struct{}
+
+func (_ MissingColumnKey) Error() string {
+	return "you need to specify a column key"
+}
+
+type InvalidPrefixLengthError struct {
+	length int
+	family string
+}
+
+func (e InvalidPrefixLengthError) Error() string {
+	return fmt.Sprintf("%d is not a valid %s prefix length", e.length, e.family)
+}
+
+type InvalidMemoryBudgetError struct {
+	bytes int64
+}
+
+func (e InvalidMemoryBudgetError) Error() string {
+	return fmt.Sprintf("%d bytes is not a valid memory budget", e.bytes)
+}
+
+type InvalidClassificationRuleError struct {
+	line int
+	rule string
+}
+
+func (e InvalidClassificationRuleError) Error() string {
+	return fmt.Sprintf("invalid classification rule on line %d: \"%s\"", e.line, e.rule)
+}
+
+type InvalidEpsilonError struct {
+	epsilon float64
+}
+
+func (e InvalidEpsilonError) Error() string {
+	return fmt.Sprintf("%v is not a valid privacy budget, epsilon must be a positive number", e.epsilon)
+}
+
+type MissingHmacKey struct{}
+
+func (_ MissingHmacKey) Error() string {
+	return "you need to specify an HMAC key"
+}
+
+type InvalidOrderingError struct {
+	name string
+}
+
+func (e InvalidOrderingError) Error() string {
+	return fmt.Sprintf("\"%s\" is not a valid ordering, use domain, count, reversed or tld", e.name)
+}
+
+type InvalidWorkerCountError struct {
+	workers int
+}
+
+func (e InvalidWorkerCountError) Error() string {
+	return fmt.Sprintf("%d is not a valid number of workers, at least one is needed", e.workers)
+}
+
+type InvalidDialectError struct {
+	dialect Dialect
+}
+
+func (e InvalidDialectError) Error() string {
+	return fmt.Sprintf("delimiter %q and comment %q are not a valid csv dialect, use different ASCII characters other than quotes and line breaks", e.dialect.delimiter(), e.dialect.Comment)
+}
+
+type InvalidFormatError struct {
+	name string
+}
+
+func (e InvalidFormatError) Error() string {
+	return fmt.Sprintf("\"%s\" is not a valid format, use text, json, csv, ndjson, table or markdown", e.name)
+}
+
+type InvalidResultLineError struct {
+	line int
+	text string
+}
+
+func (e InvalidResultLineError) Error() string {
+	return fmt.Sprintf("line %d doesn't hold a domain and its customer count: \"%s\"", e.line, e.text)
+}
+
+type SourceConsumedError struct {
+	name string
+}
+
+func (e SourceConsumedError) Error() string {
+	return fmt.Sprintf("%s was already read, an importer reading from a reader can only run one analysis", e.name)
+}
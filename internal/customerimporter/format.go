@@ -0,0 +1,412 @@
+package customerimporter
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// Format is the format WriteDomains renders domains in
+type Format string
+
+const (
+	// FormatText writes a domain(count) line per domain, followed by any optional columns
+	FormatText Format = "text"
+	// FormatJson writes an array with an object per domain
+	FormatJson Format = "json"
+	// FormatCsv writes a headers row and a row per domain
+	FormatCsv Format = "csv"
+	// FormatNdjson writes an object per domain and line, so results can be processed line by line
+	FormatNdjson Format = "ndjson"
+	// FormatTable writes an aligned text table with a headers row
+	FormatTable Format = "table"
+	// FormatMarkdown writes a Markdown table, counts and percentages aligned to the right
+	FormatMarkdown Format = "markdown"
+	// FormatHtml writes a self-contained HTML report with charts and a sortable table of the domains
+	FormatHtml Format = "html"
+)
+
+// ParseFormat validates the name of a Format
+func ParseFormat(name string) (Format, error) {
+	switch format := Format(name); format {
+	case FormatText, FormatJson, FormatCsv, FormatNdjson, FormatTable, FormatMarkdown, FormatHtml:
+		return format, nil
+	}
+	return "", InvalidFormatError{name: name}
+}
+
+// FormatOptions picks the optional columns WriteDomains writes after the domain and its customer count
+type FormatOptions struct {
+	// Percentage adds the share of all customers each domain has
+	Percentage bool
+	// Cumulative adds the share of all customers held by each domain and the ones before it, meant for domains
+	// ordered by count
+	Cumulative bool
+	Category   bool
+	// Deliverability is only meaningful for domains checked with a deliverabilityChecker
+	Deliverability bool
+	// Total is the number of customers percentages are relative to, the customers of the domains written when zero,
+	// so the top domains can be written with their share of all customers
+	Total int
+	// Sources names the importers of domains counted by CombineCustomerCounts, adding a column with the customers of
+	// each domain in every one of them. JSON objects hold them in a sources object keyed by name
+	Sources []string
+	// Stats adds the rows read and rejected to HTML reports, and the whole of them to JSON results with JsonStats.
+	// Other formats ignore them
+	Stats *RunStats
+	// JsonStats makes JSON results an object holding the domains and Stats, instead of just the array of domains
+	JsonStats bool
+}
+
+// jsonResult is what JSON results are made of with FormatOptions.JsonStats
+type jsonResult struct {
+	Domains []formattedDomain `json:"domains"`
+	Stats   *RunStats         `json:"stats,omitempty"`
+}
+
+// formattedDomain is a domain along with its optional columns, as written by WriteDomains. Percentages are pointers
+// so they are left out of JSON objects unless requested
+type formattedDomain struct {
+	Domain               string         `json:"domain"`
+	Customers            int            `json:"customers"`
+	Percentage           *float64       `json:"percentage,omitempty"`
+	CumulativePercentage *float64       `json:"cumulative_percentage,omitempty"`
+	Category             string         `json:"category,omitempty"`
+	Deliverability       string         `json:"deliverability,omitempty"`
+	Sources              map[string]int `json:"sources,omitempty"`
+	// bySource holds the same counts as Sources in the order of FormatOptions.Sources, for the other formats
+	bySource []int
+}
+
+// WriteDomains renders `domains` to `w` in `format`, in the order they are given, along with the columns requested
+// in `options`
+func WriteDomains(w io.Writer, domains []EmailDomain, format Format, options FormatOptions) error {
+	formatted := formatDomains(domains, options)
+	var err error
+	switch format {
+	case FormatText:
+		err = writeDomainsText(w, formatted, options)
+	case FormatJson:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if options.JsonStats {
+			err = encoder.Encode(jsonResult{Domains: formatted, Stats: options.Stats})
+		} else {
+			err = encoder.Encode(formatted)
+		}
+	case FormatNdjson:
+		encoder := json.NewEncoder(w)
+		for _, domain := range formatted {
+			if err = encoder.Encode(domain); err != nil {
+				break
+			}
+		}
+	case FormatCsv:
+		csvWriter := csv.NewWriter(w)
+		if err = csvWriter.Write(domainColumns(options)); err == nil {
+			for _, domain := range formatted {
+				if err = csvWriter.Write(domain.record(options)); err != nil {
+					break
+				}
+			}
+		}
+		if err == nil {
+			csvWriter.Flush()
+			err = csvWriter.Error()
+		}
+	case FormatTable:
+		err = writeDomainsTable(w, formatted, options)
+	case FormatMarkdown:
+		err = writeDomainsMarkdown(w, formatted, options)
+	case FormatHtml:
+		err = writeDomainsHtml(w, domains, formatted, options)
+	default:
+		return InvalidFormatError{name: string(format)}
+	}
+	if err != nil {
+		return fmt.Errorf("couldn't write domains as %s: %w", format, err)
+	}
+	return nil
+}
+
+// formatDomains works out the optional columns of every domain, percentages are rounded to two decimals
+func formatDomains(domains []EmailDomain, options FormatOptions) []formattedDomain {
+	total := options.Total
+	if total == 0 {
+		for _, domain := range domains {
+			total += domain.CustomerCount
+		}
+	}
+	formatted := make([]formattedDomain, len(domains))
+	cumulative := 0
+	for i, domain := range domains {
+		formatted[i] = formattedDomain{Domain: domain.Domain, Customers: domain.CustomerCount}
+		cumulative += domain.CustomerCount
+		if options.Percentage {
+			formatted[i].Percentage = percentage(domain.CustomerCount, total)
+		}
+		if options.Cumulative {
+			formatted[i].CumulativePercentage = percentage(cumulative, total)
+		}
+		if options.Category {
+			formatted[i].Category = domain.Category
+		}
+		if options.Deliverability {
+			formatted[i].Deliverability = domain.Deliverability
+		}
+		if len(options.Sources) > 0 {
+			formatted[i].bySource = make([]int, len(options.Sources))
+			copy(formatted[i].bySource, domain.BySource)
+			formatted[i].Sources = make(map[string]int, len(options.Sources))
+			for source, name := range options.Sources {
+				formatted[i].Sources[name] = formatted[i].bySource[source]
+			}
+		}
+	}
+	return formatted
+}
+
+func percentage(count, total int) *float64 {
+	var share float64
+	if total != 0 {
+		share = math.Round(float64(count)/float64(total)*10000) / 100
+	}
+	return &share
+}
+
+// domainColumns returns the names of the columns written for the given options
+func domainColumns(options FormatOptions) []string {
+	columns := []string{"domain", "customers"}
+	if options.Percentage {
+		columns = append(columns, "percentage")
+	}
+	if options.Cumulative {
+		columns = append(columns, "cumulative_percentage")
+	}
+	if options.Category {
+		columns = append(columns, "category")
+	}
+	if options.Deliverability {
+		columns = append(columns, "deliverability")
+	}
+	return append(columns, options.Sources...)
+}
+
+// record returns the values of the columns written for the given options, in the order of domainColumns
+func (d formattedDomain) record(options FormatOptions) []string {
+	record := []string{d.Domain, strconv.Itoa(d.Customers)}
+	if options.Percentage {
+		record = append(record, strconv.FormatFloat(*d.Percentage, 'f', 2, 64))
+	}
+	if options.Cumulative {
+		record = append(record, strconv.FormatFloat(*d.CumulativePercentage, 'f', 2, 64))
+	}
+	if options.Category {
+		record = append(record, d.Category)
+	}
+	if options.Deliverability {
+		record = append(record, d.Deliverability)
+	}
+	for _, count := range d.bySource {
+		record = append(record, strconv.Itoa(count))
+	}
+	return record
+}
+
+func writeDomainsText(w io.Writer, domains []formattedDomain, options FormatOptions) error {
+	for _, domain := range domains {
+		line := fmt.Sprintf("%s(%d)", domain.Domain, domain.Customers)
+		if options.Percentage {
+			line += fmt.Sprintf(" %.2f%%", *domain.Percentage)
+		}
+		if options.Cumulative {
+			line += fmt.Sprintf(" %.2f%%", *domain.CumulativePercentage)
+		}
+		if options.Category {
+			line += " " + domain.Category
+		}
+		if options.Deliverability {
+			line += " " + domain.Deliverability
+		}
+		for source, count := range domain.bySource {
+			line += fmt.Sprintf(" %s=%d", options.Sources[source], count)
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeDomainsTable(w io.Writer, domains []formattedDomain, options FormatOptions) error {
+	tabWriter := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	if _, err := fmt.Fprintln(tabWriter, strings.Join(domainColumns(options), "\t")); err != nil {
+		return err
+	}
+	for _, domain := range domains {
+		if _, err := fmt.Fprintln(tabWriter, strings.Join(domain.record(options), "\t")); err != nil {
+			return err
+		}
+	}
+	return tabWriter.Flush()
+}
+
+func writeDomainsMarkdown(w io.Writer, domains []formattedDomain, options FormatOptions) error {
+	columns := domainColumns(options)
+	alignments := make([]string, len(columns))
+	firstSource := len(columns) - len(options.Sources)
+	for i, column := range columns {
+		alignments[i] = "---"
+		if i == 1 || i >= firstSource || strings.HasSuffix(column, "percentage") {
+			alignments[i] = "---:"
+		}
+	}
+	rows := [][]string{columns, alignments}
+	for _, domain := range domains {
+		rows = append(rows, domain.record(options))
+	}
+	for _, row := range rows {
+		for i, value := range row {
+			row[i] = strings.ReplaceAll(value, "|", "\\|")
+		}
+		if _, err := fmt.Fprintf(w, "| %s |\n", strings.Join(row, " | ")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadDomains reads domains written by WriteDomains in the text, JSON, CSV or NDJSON format, along with any optional
+// columns written with them. Text results end at the first empty or blank line, so category totals are ignored, and
+// the stats of JSON results written with FormatOptions.JsonStats are skipped
+func ReadDomains(r io.Reader, format Format) (domains []EmailDomain, err error) {
+	switch format {
+	case FormatText:
+		domains, err = readDomainsText(r)
+	case FormatJson:
+		var formatted []formattedDomain
+		if formatted, err = readDomainsJson(r); err == nil {
+			for _, domain := range formatted {
+				domains = append(domains, domain.emailDomain())
+			}
+		}
+	case FormatNdjson:
+		decoder := json.NewDecoder(r)
+		for {
+			var domain formattedDomain
+			if err = decoder.Decode(&domain); err == io.EOF {
+				err = nil
+				break
+			} else if err != nil {
+				break
+			}
+			domains = append(domains, domain.emailDomain())
+		}
+	case FormatCsv:
+		domains, err = readDomainsCsv(r)
+	default:
+		return nil, InvalidFormatError{name: string(format)}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read domains as %s: %w", format, err)
+	}
+	return domains, nil
+}
+
+// readDomainsJson reads an array of domains, or the object holding them written with FormatOptions.JsonStats
+func readDomainsJson(r io.Reader) ([]formattedDomain, error) {
+	var raw json.RawMessage
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return nil, err
+	}
+	if trimmed := bytes.TrimSpace(raw); len(trimmed) > 0 && trimmed[0] == '{' {
+		var result map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &result); err != nil {
+			return nil, err
+		}
+		domains, found := result["domains"]
+		if !found {
+			return nil, errors.New("JSON object without domains")
+		}
+		raw = domains
+	}
+	var formatted []formattedDomain
+	err := json.Unmarshal(raw, &formatted)
+	return formatted, err
+}
+
+func (d formattedDomain) emailDomain() EmailDomain {
+	return EmailDomain{Domain: d.Domain, CustomerCount: d.Customers, Category: d.Category, Deliverability: d.Deliverability}
+}
+
+func readDomainsText(r io.Reader) (domains []EmailDomain, err error) {
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			break
+		}
+		open := strings.LastIndexByte(fields[0], '(')
+		if open < 1 || !strings.HasSuffix(fields[0], ")") {
+			return nil, InvalidResultLineError{line: line, text: text}
+		}
+		count, err := strconv.Atoi(fields[0][open+1 : len(fields[0])-1])
+		if err != nil {
+			return nil, InvalidResultLineError{line: line, text: text}
+		}
+		domains = append(domains, EmailDomain{Domain: fields[0][:open], CustomerCount: count})
+	}
+	return domains, scanner.Err()
+}
+
+func readDomainsCsv(r io.Reader) (domains []EmailDomain, err error) {
+	csvReader := csv.NewReader(r)
+	headers, err := csvReader.Read()
+	if err != nil {
+		return nil, err
+	}
+	columns := map[string]int{"domain": -1, "customers": -1, "category": -1, "deliverability": -1}
+	for i, header := range headers {
+		if _, known := columns[header]; known {
+			columns[header] = i
+		}
+	}
+	for _, required := range []string{"domain", "customers"} {
+		if columns[required] == -1 {
+			return nil, KeyNotFoundError{key: required, slice: headers}
+		}
+	}
+	valueOf := func(record []string, column string) string {
+		if columns[column] == -1 {
+			return ""
+		}
+		return record[columns[column]]
+	}
+	for line := 2; ; line++ {
+		record, err := csvReader.Read()
+		if err == io.EOF {
+			return domains, nil
+		} else if err != nil {
+			return nil, err
+		}
+		count, err := strconv.Atoi(valueOf(record, "customers"))
+		if err != nil {
+			return nil, InvalidResultLineError{line: line, text: strings.Join(record, ",")}
+		}
+		domains = append(domains, EmailDomain{
+			Domain:         valueOf(record, "domain"),
+			CustomerCount:  count,
+			Category:       valueOf(record, "category"),
+			Deliverability: valueOf(record, "deliverability"),
+		})
+	}
+}
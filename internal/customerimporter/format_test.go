@@ -0,0 +1,227 @@
+package customerimporter
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWriteDomains(t *testing.T) {
+	domains := []EmailDomain{
+		{Domain: "github.io", CustomerCount: 4, Category: CategoryCorporate, BySource: []int{3, 1}},
+		{Domain: "cyberchimps.com", CustomerCount: 2, Category: CategoryCorporate, BySource: []int{0, 2}},
+		{Domain: "360.cn", CustomerCount: 1, Category: CategoryCorporate, BySource: []int{1, 0}},
+		{Domain: "pipe|domain.com", CustomerCount: 1, Category: CategoryCorporate, BySource: []int{1, 0}},
+	}
+	sources := FormatOptions{Sources: []string{"a.csv", "b.csv.gz"}}
+	percentages := FormatOptions{Percentage: true, Cumulative: true}
+	tests := []struct {
+		name    string
+		format  Format
+		options FormatOptions
+		want    string
+	}{
+		{
+			name:   "text",
+			format: FormatText,
+			want:   "github.io(4)\ncyberchimps.com(2)\n360.cn(1)\npipe|domain.com(1)\n",
+		},
+		{
+			name:    "text with percentages and categories",
+			format:  FormatText,
+			options: FormatOptions{Percentage: true, Cumulative: true, Category: true},
+			want: "github.io(4) 50.00% 50.00% corporate\ncyberchimps.com(2) 25.00% 75.00% corporate\n" +
+				"360.cn(1) 12.50% 87.50% corporate\npipe|domain.com(1) 12.50% 100.00% corporate\n",
+		},
+		{
+			name:    "text with percentages of a total",
+			format:  FormatText,
+			options: FormatOptions{Percentage: true, Total: 16},
+			want:    "github.io(4) 25.00%\ncyberchimps.com(2) 12.50%\n360.cn(1) 6.25%\npipe|domain.com(1) 6.25%\n",
+		},
+		{
+			name:    "json",
+			format:  FormatJson,
+			options: FormatOptions{Percentage: true},
+			want: "[\n" +
+				"  {\n    \"domain\": \"github.io\",\n    \"customers\": 4,\n    \"percentage\": 50\n  },\n" +
+				"  {\n    \"domain\": \"cyberchimps.com\",\n    \"customers\": 2,\n    \"percentage\": 25\n  },\n" +
+				"  {\n    \"domain\": \"360.cn\",\n    \"customers\": 1,\n    \"percentage\": 12.5\n  },\n" +
+				"  {\n    \"domain\": \"pipe|domain.com\",\n    \"customers\": 1,\n    \"percentage\": 12.5\n  }\n" +
+				"]\n",
+		},
+		{
+			name:    "ndjson",
+			format:  FormatNdjson,
+			options: FormatOptions{Cumulative: true, Category: true},
+			want: `{"domain":"github.io","customers":4,"cumulative_percentage":50,"category":"corporate"}` + "\n" +
+				`{"domain":"cyberchimps.com","customers":2,"cumulative_percentage":75,"category":"corporate"}` + "\n" +
+				`{"domain":"360.cn","customers":1,"cumulative_percentage":87.5,"category":"corporate"}` + "\n" +
+				`{"domain":"pipe|domain.com","customers":1,"cumulative_percentage":100,"category":"corporate"}` + "\n",
+		},
+		{
+			name:    "csv",
+			format:  FormatCsv,
+			options: percentages,
+			want: "domain,customers,percentage,cumulative_percentage\n" +
+				"github.io,4,50.00,50.00\ncyberchimps.com,2,25.00,75.00\n360.cn,1,12.50,87.50\npipe|domain.com,1,12.50,100.00\n",
+		},
+		{
+			name:    "table",
+			format:  FormatTable,
+			options: percentages,
+			want: "domain           customers  percentage  cumulative_percentage\n" +
+				"github.io        4          50.00       50.00\n" +
+				"cyberchimps.com  2          25.00       75.00\n" +
+				"360.cn           1          12.50       87.50\n" +
+				"pipe|domain.com  1          12.50       100.00\n",
+		},
+		{
+			name:    "markdown",
+			format:  FormatMarkdown,
+			options: FormatOptions{Percentage: true, Category: true},
+			want: "| domain | customers | percentage | category |\n" +
+				"| --- | ---: | ---: | --- |\n" +
+				"| github.io | 4 | 50.00 | corporate |\n" +
+				"| cyberchimps.com | 2 | 25.00 | corporate |\n" +
+				"| 360.cn | 1 | 12.50 | corporate |\n" +
+				"| pipe\\|domain.com | 1 | 12.50 | corporate |\n",
+		},
+		{
+			name:    "text with sources",
+			format:  FormatText,
+			options: sources,
+			want: "github.io(4) a.csv=3 b.csv.gz=1\ncyberchimps.com(2) a.csv=0 b.csv.gz=2\n" +
+				"360.cn(1) a.csv=1 b.csv.gz=0\npipe|domain.com(1) a.csv=1 b.csv.gz=0\n",
+		},
+		{
+			name:    "ndjson with sources",
+			format:  FormatNdjson,
+			options: sources,
+			want: `{"domain":"github.io","customers":4,"sources":{"a.csv":3,"b.csv.gz":1}}` + "\n" +
+				`{"domain":"cyberchimps.com","customers":2,"sources":{"a.csv":0,"b.csv.gz":2}}` + "\n" +
+				`{"domain":"360.cn","customers":1,"sources":{"a.csv":1,"b.csv.gz":0}}` + "\n" +
+				`{"domain":"pipe|domain.com","customers":1,"sources":{"a.csv":1,"b.csv.gz":0}}` + "\n",
+		},
+		{
+			name:    "csv with sources",
+			format:  FormatCsv,
+			options: FormatOptions{Category: true, Sources: sources.Sources},
+			want: "domain,customers,category,a.csv,b.csv.gz\n" +
+				"github.io,4,corporate,3,1\ncyberchimps.com,2,corporate,0,2\n360.cn,1,corporate,1,0\npipe|domain.com,1,corporate,1,0\n",
+		},
+		{
+			name:    "markdown with sources",
+			format:  FormatMarkdown,
+			options: sources,
+			want: "| domain | customers | a.csv | b.csv.gz |\n" +
+				"| --- | ---: | ---: | ---: |\n" +
+				"| github.io | 4 | 3 | 1 |\n" +
+				"| cyberchimps.com | 2 | 0 | 2 |\n" +
+				"| 360.cn | 1 | 1 | 0 |\n" +
+				"| pipe\\|domain.com | 1 | 1 | 0 |\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got bytes.Buffer
+			if err := WriteDomains(&got, domains, tt.format, tt.options); err != nil {
+				t.Fatal(err)
+			}
+			if got.String() != tt.want {
+				t.Errorf("WriteDomains() = %q, want %q", got.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteDomains_empty(t *testing.T) {
+	var got bytes.Buffer
+	if err := WriteDomains(&got, nil, FormatJson, FormatOptions{Percentage: true}); err != nil {
+		t.Fatal(err)
+	}
+	if got.String() != "[]\n" {
+		t.Errorf("WriteDomains() = %q, want an empty array", got.String())
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	for _, name := range []string{"text", "json", "csv", "ndjson", "table", "markdown", "html"} {
+		if format, err := ParseFormat(name); err != nil || string(format) != name {
+			t.Errorf("ParseFormat(%q) = %q, %v", name, format, err)
+		}
+	}
+	var invalidFormat InvalidFormatError
+	if _, err := ParseFormat("xml"); !errors.As(err, &invalidFormat) {
+		t.Errorf("ParseFormat(\"xml\") error = %v, want an InvalidFormatError", err)
+	}
+}
+
+func TestReadDomains(t *testing.T) {
+	domains := []EmailDomain{
+		{Domain: "github.io", CustomerCount: 4, Category: CategoryCorporate},
+		{Domain: "360.cn", CustomerCount: 1, Category: CategoryCorporate},
+	}
+	for _, format := range []Format{FormatText, FormatJson, FormatCsv, FormatNdjson, "json with stats"} {
+		t.Run(string(format), func(t *testing.T) {
+			var written bytes.Buffer
+			options := FormatOptions{Percentage: true, Category: format != FormatText}
+			if format == "json with stats" {
+				format, options.JsonStats, options.Stats = FormatJson, true, &RunStats{RowsRead: 5}
+			}
+			if err := WriteDomains(&written, domains, format, options); err != nil {
+				t.Fatal(err)
+			}
+			if format == FormatText {
+				// Category totals follow an empty line
+				written.WriteString("\ncorporate: 2 domains, 5 customers\n")
+			}
+			got, err := ReadDomains(&written, format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := domains
+			if format == FormatText {
+				want = []EmailDomain{{Domain: "github.io", CustomerCount: 4}, {Domain: "360.cn", CustomerCount: 1}}
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("ReadDomains() = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestReadDomains_blankLine(t *testing.T) {
+	// A line of spaces ends the results like an empty one
+	got, err := ReadDomains(strings.NewReader("a.com(3)\n   \nb.com(2)\n"), FormatText)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []EmailDomain{{Domain: "a.com", CustomerCount: 3}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadDomains() = %v, want %v", got, want)
+	}
+}
+
+func TestReadDomains_invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  Format
+		content string
+	}{
+		{name: "text without count", format: FormatText, content: "github.io(4)\n360.cn\n"},
+		{name: "text with invalid count", format: FormatText, content: "github.io(four)\n"},
+		{name: "csv without customers", format: FormatCsv, content: "domain,count\ngithub.io,4\n"},
+		{name: "csv with invalid count", format: FormatCsv, content: "domain,customers\ngithub.io,\n"},
+		{name: "json object", format: FormatJson, content: "{\"domain\": \"github.io\"}"},
+		{name: "table", format: FormatTable, content: "domain  customers\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ReadDomains(strings.NewReader(tt.content), tt.format); err == nil {
+				t.Errorf("ReadDomains() error = nil, want an error")
+			}
+		})
+	}
+}
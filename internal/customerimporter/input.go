@@ -0,0 +1,204 @@
+package customerimporter
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"os"
+)
+
+const (
+	// ReadPathMmap is used when the csv file is memory mapped and scanned in place
+	ReadPathMmap = "mmap"
+	// ReadPathBuffered is used when the csv file goes through regular reads
+	ReadPathBuffered = "buffered"
+	// ReadPathMixed is used by CombineCustomerCounts when its importers didn't all read their input the same way
+	ReadPathMixed = "mixed"
+)
+
+// chunkSize is roughly how many bytes of the csv file are handed to a worker at once, tests make it smaller
+var chunkSize = 1 << 20
+
+// csvInput is the csv file of an importer opened for reading, memory mapped when possible and asked for
+type csvInput struct {
+	reader io.ReadCloser
+	// mapped holds the whole file when it's memory mapped, nil otherwise
+	mapped   []byte
+	readPath string
+	// counted counts the bytes going through reader when the file isn't mapped
+	counted *countingReader
+}
+
+// countingReader counts the bytes read through it, it's only read from one goroutine at a time
+type countingReader struct {
+	io.ReadCloser
+	read int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	read, err := r.ReadCloser.Read(p)
+	r.read += int64(read)
+	return read, err
+}
+
+// openInput opens the csv file, mapping it into memory if the importer was created WithMemoryMap.
+// Anything but non empty regular files, like pipes, readers and gzip files, falls back to regular reads, as does any
+// failure to map the file
+func (imp *csvCustomerImporter) openInput() (*csvInput, error) {
+	reader, err := imp.openSource()
+	if err != nil {
+		return nil, err
+	}
+	counted := &countingReader{ReadCloser: reader}
+	input := &csvInput{reader: counted, readPath: ReadPathBuffered, counted: counted}
+	file, isFile := reader.(*os.File)
+	if !imp.memoryMap || !isFile {
+		return input, nil
+	}
+	info, err := file.Stat()
+	if err != nil || !info.Mode().IsRegular() || info.Size() == 0 {
+		return input, nil
+	}
+	mapped, err := mapFile(file, info.Size())
+	if err != nil {
+		log.Printf("couldn't map %s into memory, falling back to regular reads: %s", imp.csvPath, err)
+		return input, nil
+	}
+	input.reader, input.counted = file, nil
+	input.mapped = mapped
+	input.readPath = ReadPathMmap
+	return input, nil
+}
+
+// scanner returns a recordScanner reading the whole input
+func (in *csvInput) scanner(dialect Dialect) *recordScanner {
+	if in.mapped != nil {
+		return newBytesRecordScanner(in.mapped, dialect)
+	}
+	return newRecordScanner(in.reader, dialect)
+}
+
+// bytesRead returns how many bytes of the input have been read, all of them for mapped files
+func (in *csvInput) bytesRead() int64 {
+	if in.counted != nil {
+		return in.counted.read
+	}
+	return int64(len(in.mapped))
+}
+
+func (in *csvInput) Close() error {
+	if in.mapped != nil {
+		if err := unmapFile(in.mapped); err != nil {
+			_ = in.reader.Close()
+			return err
+		}
+		in.mapped = nil
+	}
+	return in.reader.Close()
+}
+
+// csvChunk is a piece of a csv file holding whole records
+type csvChunk struct {
+	data []byte
+	// line is the number of lines before the chunk
+	line int
+}
+
+// chunkReader cuts a csvInput into chunks of about chunkSize bytes ending at a record boundary. Mapped inputs are
+// sliced in place, any other input is read into a new buffer for each chunk
+type chunkReader struct {
+	reader  io.Reader
+	comment byte
+	// pending holds the bytes not handed in a chunk yet
+	pending []byte
+	eof     bool
+	line    int
+}
+
+func newChunkReader(input *csvInput, dialect Dialect) *chunkReader {
+	if input.mapped != nil {
+		return &chunkReader{pending: input.mapped, eof: true, comment: dialect.Comment}
+	}
+	return &chunkReader{reader: input.reader, comment: dialect.Comment}
+}
+
+// next returns the following chunk, failing with io.EOF once the whole input has been handed
+func (c *chunkReader) next() (csvChunk, error) {
+	size := chunkSize
+	for {
+		if len(c.pending) < size && !c.eof {
+			if err := c.fill(size); err != nil {
+				return csvChunk{}, err
+			}
+		}
+		if len(c.pending) == 0 {
+			return csvChunk{}, io.EOF
+		}
+		end := len(c.pending)
+		if end > size || !c.eof {
+			if end > size {
+				end = size
+			}
+			end = lastRecordBoundary(c.pending[:end], c.comment)
+		}
+		if end == -1 {
+			// A single record is bigger than the chunk
+			size *= 2
+			continue
+		}
+		chunk := csvChunk{data: c.pending[:end], line: c.line}
+		c.pending = c.pending[end:]
+		c.line += bytes.Count(chunk.data, []byte{'\n'})
+		return chunk, nil
+	}
+}
+
+// fill reads until at least `size` bytes are pending or the end of the input, into a new buffer so chunks already
+// handed aren't overwritten
+func (c *chunkReader) fill(size int) error {
+	buffer := make([]byte, size)
+	copied := copy(buffer, c.pending)
+	read, err := io.ReadFull(c.reader, buffer[copied:])
+	c.pending = buffer[:copied+read]
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		c.eof = true
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("couldn't read CSV file: %w", err)
+	}
+	return nil
+}
+
+// lastRecordBoundary returns the position after the last line break of `data` which isn't inside a quoted field, -1
+// if there's none. `data` must start at the beginning of a record, lines starting with `comment` are skipped whole
+func lastRecordBoundary(data []byte, comment byte) int {
+	if bytes.IndexByte(data, '"') == -1 {
+		if newline := bytes.LastIndexByte(data, '\n'); newline != -1 {
+			return newline + 1
+		}
+		return -1
+	}
+	boundary, quoted, lineStart := -1, false, true
+	for i := 0; i < len(data); i++ {
+		if lineStart && comment != 0 && data[i] == comment {
+			newline := bytes.IndexByte(data[i:], '\n')
+			if newline == -1 {
+				break
+			}
+			i += newline
+			boundary = i + 1
+			continue
+		}
+		lineStart = false
+		switch {
+		case data[i] == '"':
+			quoted = !quoted
+		case data[i] == '\n' && !quoted:
+			boundary = i + 1
+			lineStart = true
+		}
+	}
+	return boundary
+}
@@ -0,0 +1,37 @@
+package customerimporter
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func Test_csvCustomerImporter_openInput_pipe(t *testing.T) {
+	pipePath := filepath.Join(t.TempDir(), "pipe.csv")
+	if err := syscall.Mkfifo(pipePath, 0600); err != nil {
+		t.Fatal(err)
+	}
+	csvFile, err := ioutil.ReadFile("../../test/data/importer/customers-small.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		_ = ioutil.WriteFile(pipePath, csvFile, 0600)
+	}()
+
+	imp, err := NewCsvCustomerImporter(pipePath, "email", WithMemoryMap())
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, stats, err := imp.CustomerCountByDomainWithStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.ReadPath != ReadPathBuffered {
+		t.Errorf("CustomerCountByDomainWithStats() read path = %s, want %s", stats.ReadPath, ReadPathBuffered)
+	}
+	if len(got) != 3 {
+		t.Errorf("CustomerCountByDomainWithStats() = %v, want the 3 domains of the file", got)
+	}
+}
@@ -0,0 +1,183 @@
+package customerimporter
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func Test_csvCustomerImporter_openInput(t *testing.T) {
+	emptyPath := filepath.Join(t.TempDir(), "empty.csv")
+	if err := ioutil.WriteFile(emptyPath, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	mmapPath := ReadPathMmap
+	if runtime.GOOS != "linux" {
+		mmapPath = ReadPathBuffered
+	}
+	tests := []struct {
+		name         string
+		csvPath      string
+		options      []ImporterOption
+		wantReadPath string
+	}{
+		{
+			name:         "regular reads by default",
+			csvPath:      "../../test/data/importer/customers-small.csv",
+			wantReadPath: ReadPathBuffered,
+		},
+		{
+			name:         "memory mapped",
+			csvPath:      "../../test/data/importer/customers-small.csv",
+			options:      []ImporterOption{WithMemoryMap()},
+			wantReadPath: mmapPath,
+		},
+		{
+			name:         "empty files can't be mapped",
+			csvPath:      emptyPath,
+			options:      []ImporterOption{WithMemoryMap()},
+			wantReadPath: ReadPathBuffered,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			imp, err := NewCsvCustomerImporter(tt.csvPath, "email", tt.options...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			input, err := imp.openInput()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer input.Close()
+			if input.readPath != tt.wantReadPath {
+				t.Errorf("openInput() read path = %s, want %s", input.readPath, tt.wantReadPath)
+			}
+			want, err := ioutil.ReadFile(tt.csvPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []byte
+			if input.mapped != nil {
+				got = input.mapped
+			} else if got, err = ioutil.ReadAll(input.reader); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("openInput() read %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func Test_chunkReader_next(t *testing.T) {
+	defer func(size int) { chunkSize = size }(chunkSize)
+	chunkSize = 16
+
+	data := "a,b\n1,2\n\"multi\nline\",3\n\"quote \"\" and\n\",4\n5,6\n\"a record longer than a chunk\",7\n8,9"
+	tests := []struct {
+		name   string
+		reader *chunkReader
+	}{
+		{
+			name:   "mapped",
+			reader: &chunkReader{pending: []byte(data), eof: true},
+		},
+		{
+			name:   "read",
+			reader: &chunkReader{reader: strings.NewReader(data)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			lines := 0
+			for {
+				chunk, err := tt.reader.next()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				if chunk.line != lines {
+					t.Errorf("next() line = %d, want %d", chunk.line, lines)
+				}
+				lines += bytes.Count(chunk.data, []byte{'\n'})
+				got = append(got, string(chunk.data))
+			}
+			want := []string{
+				"a,b\n1,2\n",
+				"\"multi\nline\",3\n",
+				// Records longer than a chunk make it grow
+				"\"quote \"\" and\n\",4\n5,6\n",
+				"\"a record longer than a chunk\",7\n8,9",
+			}
+			if strings.Join(got, "|") != strings.Join(want, "|") {
+				t.Errorf("next() = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func Test_lastRecordBoundary(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		comment byte
+		want    int
+	}{
+		{name: "no line breaks", data: "a,b", want: -1},
+		{name: "unquoted", data: "a,b\n1,2\n3", want: 8},
+		{name: "line break in quoted field", data: "a,b\n\"1\n2\",3", want: 4},
+		{name: "closed quoted field", data: "\"1\n2\",3\n4", want: 8},
+		{name: "escaped quotes", data: "\"say \"\"hi\"\"\n\",1\n", want: 16},
+		{name: "quote in comment", data: "#say \"hi\n1,2\n3", comment: '#', want: 13},
+		{name: "quote in last comment", data: "1,2\n#say \"hi", comment: '#', want: 4},
+		{name: "comment character in field", data: "\"1\n#2\",3\n", comment: '#', want: 9},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := lastRecordBoundary([]byte(tt.data), tt.comment); got != tt.want {
+				t.Errorf("lastRecordBoundary() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_csvCustomerImporter_CustomerCountByDomainWithStats(t *testing.T) {
+	defer func(size int) { chunkSize = size }(chunkSize)
+	chunkSize = 4096
+
+	sequentialImp, err := NewCsvCustomerImporter("../../test/data/importer/customers.csv", "email")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := sequentialImp.CustomerCountByDomain()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, workers := range []int{1, 4} {
+		for _, options := range [][]ImporterOption{nil, {WithMemoryMap()}} {
+			imp, err := NewCsvCustomerImporter("../../test/data/importer/customers.csv", "email", append(options, WithWorkers(workers))...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, stats, err := imp.CustomerCountByDomainWithStats()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("CustomerCountByDomainWithStats() with %d workers and %s reads differs from CustomerCountByDomain()", workers, stats.ReadPath)
+			}
+			if (len(options) > 0) != (stats.ReadPath == ReadPathMmap) && runtime.GOOS == "linux" {
+				t.Errorf("CustomerCountByDomainWithStats() read path = %s", stats.ReadPath)
+			}
+		}
+	}
+}
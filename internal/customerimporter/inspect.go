@@ -0,0 +1,279 @@
+package customerimporter
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+)
+
+const (
+	ValueEmail  = "email"
+	ValueNumber = "number"
+	ValueIp     = "ip"
+	ValueText   = "text"
+	// ValueEmpty is the type of columns without any value
+	ValueEmpty = "empty"
+)
+
+// sniffedDelimiters are the delimiters SniffDialect chooses from, in order of preference on ties
+var sniffedDelimiters = []byte{',', ';', '\t', '|'}
+
+const (
+	// sniffSize is the amount of bytes SniffDialect reads from the start of the file
+	sniffSize = 64 << 10
+	// maxSamples is the number of distinct values kept as samples for every column
+	maxSamples = 3
+	// maxDistinctValues caps the distinct values counted for every column, so inspecting doesn't take too much memory
+	maxDistinctValues = 10000
+)
+
+// csvInspection describes the columns of a csv file from a sample of its rows
+type csvInspection struct {
+	Dialect Dialect `json:"-"`
+	// Delimiter and Comment are the characters of Dialect, Comment is empty if there's none
+	Delimiter string `json:"delimiter"`
+	Comment   string `json:"comment"`
+	// HasHeaders tells whether the first row looks like a headers row rather than data
+	HasHeaders    bool          `json:"has_headers"`
+	RowsSampled   int           `json:"rows_sampled"`
+	MalformedRows int           `json:"malformed_rows"`
+	Columns       []columnStats `json:"columns"`
+	// EmailColumn is the name of the column with the most email addresses, empty if none has any
+	EmailColumn string `json:"email_column"`
+}
+
+// columnStats describes the values sampled from a column
+type columnStats struct {
+	// Name is the header of the column, or "column N" if the file has no headers row
+	Name string `json:"name"`
+	// Type is the type of at least 90% of the values which aren't empty, ValueText otherwise
+	Type  string `json:"type"`
+	Empty int    `json:"empty"`
+	// Distinct counts different values up to maxDistinctValues
+	Distinct  int      `json:"distinct"`
+	MaxLength int      `json:"max_length"`
+	Emails    int      `json:"emails"`
+	Numbers   int      `json:"numbers"`
+	Ips       int      `json:"ips"`
+	Samples   []string `json:"samples"`
+	// filled counts the values which aren't empty
+	filled int
+	values map[string]bool
+}
+
+// SniffDialect guesses the dialect of a csv file from its first lines: the delimiter splitting most of them into the
+// same number of fields, more than one, and '#' as comment character if a line starts with it. Files with a single
+// column get the default dialect
+func SniffDialect(csvPath string) (Dialect, error) {
+	file, err := os.Open(csvPath)
+	if err != nil {
+		return Dialect{}, err
+	}
+	defer file.Close()
+	sample := make([]byte, sniffSize)
+	n, err := io.ReadFull(file, sample)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return Dialect{}, fmt.Errorf("couldn't read CSV file: %w", err)
+	}
+	sample = sample[:n]
+	if n == sniffSize {
+		// The last line is likely cut, it's left out
+		if newline := bytes.LastIndexByte(sample, '\n'); newline != -1 {
+			sample = sample[:newline+1]
+		}
+	}
+
+	var dialect Dialect
+	for _, line := range bytes.Split(sample, []byte{'\n'}) {
+		if len(line) > 0 && line[0] == '#' {
+			dialect.Comment = '#'
+			break
+		}
+	}
+	bestMatches, bestFields := 0, 0
+	for _, delimiter := range sniffedDelimiters {
+		scanner := newBytesRecordScanner(sample, Dialect{Delimiter: delimiter, Comment: dialect.Comment})
+		fields, matches := 0, 0
+		for records := 0; records < 20; records++ {
+			err := scanner.Scan()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				continue
+			}
+			if records == 0 {
+				fields = scanner.Len()
+			}
+			if scanner.Len() == fields && fields > 1 {
+				matches++
+			}
+		}
+		if matches > bestMatches || matches == bestMatches && fields > bestFields {
+			dialect.Delimiter, bestMatches, bestFields = delimiter, matches, fields
+		}
+	}
+	if bestMatches == 0 {
+		dialect.Delimiter = ','
+	}
+	return dialect, nil
+}
+
+// InspectCsv reads up to `sampleRows` rows of a csv file in the given dialect, working out whether its first row is a
+// headers row, the type of the values of every column and which one holds email addresses
+func InspectCsv(csvPath string, dialect Dialect, sampleRows int) (*csvInspection, error) {
+	if err := dialect.validate(); err != nil {
+		return nil, err
+	}
+	file, err := os.Open(csvPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	inspection := &csvInspection{
+		Dialect:   dialect,
+		Delimiter: string(dialect.delimiter()),
+	}
+	if dialect.Comment != 0 {
+		inspection.Comment = string(dialect.Comment)
+	}
+	scanner := newRecordScanner(file, dialect)
+	if err := scanner.Scan(); err != nil {
+		return nil, fmt.Errorf("couldn't read first row on CSV file: %w", err)
+	}
+	first := scanner.Strings()
+	inspection.Columns = make([]columnStats, len(first))
+	for i := range inspection.Columns {
+		inspection.Columns[i] = columnStats{Name: "column " + strconv.Itoa(i+1), values: make(map[string]bool)}
+	}
+	for inspection.RowsSampled < sampleRows {
+		err := scanner.Scan()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var parseError *csv.ParseError
+			if !errors.As(err, &parseError) {
+				return nil, fmt.Errorf("couldn't read CSV file: %w", err)
+			}
+			inspection.MalformedRows++
+			continue
+		}
+		inspection.RowsSampled++
+		for i := range inspection.Columns {
+			inspection.Columns[i].add(string(scanner.Field(i)))
+		}
+	}
+	for i := range inspection.Columns {
+		inspection.Columns[i].classify()
+	}
+
+	inspection.HasHeaders = looksLikeHeaders(first, inspection.Columns)
+	if inspection.HasHeaders {
+		for i := range inspection.Columns {
+			inspection.Columns[i].Name = first[i]
+		}
+	} else {
+		inspection.RowsSampled++
+		for i := range inspection.Columns {
+			inspection.Columns[i].add(first[i])
+			inspection.Columns[i].classify()
+		}
+	}
+	mostEmails := 0
+	for _, column := range inspection.Columns {
+		if column.Emails > mostEmails {
+			inspection.EmailColumn, mostEmails = column.Name, column.Emails
+		}
+	}
+	return inspection, nil
+}
+
+// valueType returns the type of a value which isn't empty
+func valueType(value string) string {
+	if emailRegexp.MatchString(value) {
+		return ValueEmail
+	}
+	if _, err := strconv.ParseFloat(value, 64); err == nil {
+		return ValueNumber
+	}
+	if net.ParseIP(value) != nil {
+		return ValueIp
+	}
+	return ValueText
+}
+
+func (c *columnStats) add(value string) {
+	if value == "" {
+		c.Empty++
+		return
+	}
+	c.filled++
+	if len(value) > c.MaxLength {
+		c.MaxLength = len(value)
+	}
+	switch valueType(value) {
+	case ValueEmail:
+		c.Emails++
+	case ValueNumber:
+		c.Numbers++
+	case ValueIp:
+		c.Ips++
+	}
+	if !c.values[value] && len(c.values) < maxDistinctValues {
+		c.values[value] = true
+		c.Distinct++
+		if len(c.Samples) < maxSamples {
+			c.Samples = append(c.Samples, value)
+		}
+	}
+}
+
+// classify works out the type of the column from the values added so far
+func (c *columnStats) classify() {
+	if c.filled == 0 {
+		c.Type = ValueEmpty
+		return
+	}
+	c.Type = ValueText
+	for valueType, count := range map[string]int{ValueEmail: c.Emails, ValueNumber: c.Numbers, ValueIp: c.Ips} {
+		if float64(count) >= 0.9*float64(c.filled) {
+			c.Type = valueType
+		}
+	}
+}
+
+// looksLikeHeaders tells whether the `first` row is a headers row, given the stats of the rest of the rows. Every
+// column whose values have a type other than text votes for headers if its first value has a different type, and
+// against them otherwise. Without any votes, the first row is taken as headers when its values are all different and
+// none of them shows up again in its column
+func looksLikeHeaders(first []string, columns []columnStats) bool {
+	votes := 0
+	for i, column := range columns {
+		if column.Type == ValueText || column.Type == ValueEmpty {
+			continue
+		}
+		if first[i] == "" || valueType(first[i]) != column.Type {
+			votes++
+		} else {
+			votes--
+		}
+	}
+	if votes != 0 {
+		return votes > 0
+	}
+	seen := make(map[string]bool)
+	for i, value := range first {
+		if value == "" || seen[value] || columns[i].values[value] {
+			return false
+		}
+		seen[value] = true
+	}
+	return true
+}
@@ -0,0 +1,92 @@
+package customerimporter
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSniffDialect(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    Dialect
+	}{
+		{name: "commas", content: "a,b\n1,2\n", want: Dialect{Delimiter: ','}},
+		{name: "semicolons with commas in values", content: "name;address\n\"Doe, John\";a@b.com\nx;y\n", want: Dialect{Delimiter: ';'}},
+		{name: "tabs and comments", content: "# exported\na\tb\tc\n1\t2\t3\n", want: Dialect{Delimiter: '\t', Comment: '#'}},
+		{name: "pipes", content: "a|b\n1|2\n", want: Dialect{Delimiter: '|'}},
+		{name: "single column", content: "email\na@b.com\n", want: Dialect{Delimiter: ','}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			csvPath := filepath.Join(t.TempDir(), "sniffed.csv")
+			if err := ioutil.WriteFile(csvPath, []byte(tt.content), 0600); err != nil {
+				t.Fatal(err)
+			}
+			got, err := SniffDialect(csvPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("SniffDialect() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInspectCsv(t *testing.T) {
+	inspection, err := InspectCsv("../../test/data/importer/customers-small.csv", Dialect{}, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !inspection.HasHeaders || inspection.RowsSampled != 8 || inspection.EmailColumn != "email" {
+		t.Errorf("InspectCsv() = headers %v, %d rows sampled, email column %q, want headers, 8 rows and email",
+			inspection.HasHeaders, inspection.RowsSampled, inspection.EmailColumn)
+	}
+	var types []string
+	for _, column := range inspection.Columns {
+		types = append(types, column.Name+":"+column.Type)
+	}
+	wantTypes := []string{"first_name:text", "last_name:text", "email:text", "gender:text", "ip_address:ip"}
+	if !reflect.DeepEqual(types, wantTypes) {
+		t.Errorf("InspectCsv() column types = %v, want %v", types, wantTypes)
+	}
+	email := inspection.Columns[2]
+	if email.Emails != 7 || email.Distinct != 7 || !reflect.DeepEqual(email.Samples, []string{"mhernandez0@github.io", "bortiz1@cyberchimps.com", "dhenry2@github.io"}) {
+		t.Errorf("InspectCsv() email column = %+v", email)
+	}
+}
+
+func TestInspectCsv_headers(t *testing.T) {
+	tests := []struct {
+		name        string
+		content     string
+		wantHeaders bool
+	}{
+		{name: "typed columns", content: "mail,age\na@b.com,31\nc@d.com,42\n", wantHeaders: true},
+		{name: "typed columns without headers", content: "a@b.com,31\nc@d.com,42\n", wantHeaders: false},
+		{name: "text columns", content: "name,city\nAnna,Paris\nOscar,Lima\n", wantHeaders: true},
+		{name: "repeated text values", content: "Anna,Paris\nOscar,Paris\nAnna,Lima\n", wantHeaders: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			csvPath := filepath.Join(t.TempDir(), "inspected.csv")
+			if err := ioutil.WriteFile(csvPath, []byte(tt.content), 0600); err != nil {
+				t.Fatal(err)
+			}
+			inspection, err := InspectCsv(csvPath, Dialect{}, 100)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if inspection.HasHeaders != tt.wantHeaders {
+				t.Errorf("InspectCsv() headers = %v, want %v", inspection.HasHeaders, tt.wantHeaders)
+			}
+			if !tt.wantHeaders && (inspection.Columns[0].Name != "column 1" || inspection.RowsSampled != strings.Count(tt.content, "\n")) {
+				t.Errorf("InspectCsv() without headers = %+v", inspection)
+			}
+		})
+	}
+}
@@ -0,0 +1,292 @@
+package customerimporter
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+)
+
+const (
+	// liveTrackedDomains bounds the domains live counts keep track of for their snapshots, see liveTracker
+	liveTrackedDomains = 4096
+	// liveBatchRows is how many customers are added up by each reader of a live count before handing them over to
+	// the liveTracker, so workers don't wait on each other for every row
+	liveBatchRows = 256
+)
+
+// DomainSnapshot holds the domain counts of an import at some point while it's running
+type DomainSnapshot struct {
+	// Domains holds the domains with the most customers so far, ordered by customer count
+	Domains []EmailDomain
+	// CustomersCounted is the number of customers counted so far
+	CustomersCounted int
+	// Complete is only set on the last snapshot, taken once the whole file has been read
+	Complete bool
+}
+
+// CustomerCountByDomainLive works like CustomerCountByDomainWithStats, but it also calls `fn` with a snapshot of the
+// `top` domains with the most customers every `everyRows` customers counted and every `every` duration, a zero value
+// disables either of them. A last snapshot with Complete set is sent before returning unless counting fails, and `fn`
+// is never called concurrently.
+// The result is counted within the memory budget and with the workers of the importer. Snapshots are taken from the
+// liveTrackedDomains domains with the most customers, so their counts are exact while there aren't more domains than
+// that, and may be overestimated for domains which first show up once there are, see liveTracker
+func (imp *csvCustomerImporter) CustomerCountByDomainLive(everyRows int, every time.Duration, top int, fn func(snapshot DomainSnapshot)) (sortedDomains []EmailDomain, stats RunStats, err error) {
+	live := newLiveTracker(everyRows, top, imp.classifier, fn)
+	var stop, stopped chan struct{}
+	if every > 0 {
+		stop, stopped = make(chan struct{}), make(chan struct{})
+		go func() {
+			defer close(stopped)
+			ticker := time.NewTicker(every)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					live.snapshot()
+				case <-stop:
+					return
+				}
+			}
+		}()
+	}
+	defer func() {
+		if stop != nil {
+			// Stopping first keeps the complete snapshot the last one
+			close(stop)
+			<-stopped
+		}
+		if err == nil {
+			live.complete(sortedDomains)
+		}
+	}()
+	return imp.countByDomain(live)
+}
+
+// liveTracker keeps the customers of the domains with the most of them while a live count runs, taking snapshots of
+// them. It follows the Space-Saving algorithm: once it tracks liveTrackedDomains domains, a new one replaces the domain
+// with the fewest customers, inheriting its count as an upper bound of its own. Domains with more customers than the
+// replaced ones are always tracked, so the top of the snapshots is accurate unless domains are evenly spread.
+// Customers are handed over in batches, see liveBatch. A nil *liveTracker tracks nothing
+type liveTracker struct {
+	mutex            sync.Mutex
+	domains          map[string]*trackedDomain
+	fewest           trackedDomains
+	customersCounted int
+	everyRows        int
+	nextSnapshot     int
+	batchRows        int
+	top              int
+	classifier       *domainClassifier
+	fn               func(snapshot DomainSnapshot)
+}
+
+type trackedDomain struct {
+	domain    string
+	customers int
+	// index is the position of the domain in liveTracker.fewest
+	index int
+}
+
+func newLiveTracker(everyRows int, top int, classifier *domainClassifier, fn func(snapshot DomainSnapshot)) *liveTracker {
+	tracker := &liveTracker{
+		domains:      make(map[string]*trackedDomain),
+		everyRows:    everyRows,
+		nextSnapshot: everyRows,
+		batchRows:    liveBatchRows,
+		top:          top,
+		classifier:   classifier,
+		fn:           fn,
+	}
+	if everyRows > 0 && everyRows < liveBatchRows {
+		// Batches are handed over often enough for snapshots to be taken every `everyRows` customers
+		tracker.batchRows = everyRows
+	}
+	return tracker
+}
+
+// add adds the customers of a batch, taking a snapshot when another `everyRows` customers have been counted
+func (l *liveTracker) add(batch *liveBatch) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	for domain, customers := range batch.domains {
+		l.addDomain(domain, *customers)
+	}
+	l.customersCounted += batch.customers
+	if l.everyRows > 0 && l.customersCounted >= l.nextSnapshot {
+		l.nextSnapshot = (l.customersCounted/l.everyRows + 1) * l.everyRows
+		l.fn(l.takeSnapshot())
+	}
+}
+
+func (l *liveTracker) addDomain(domain string, customers int) {
+	if tracked, found := l.domains[domain]; found {
+		tracked.customers += customers
+		heap.Fix(&l.fewest, tracked.index)
+		return
+	}
+	if len(l.fewest) < liveTrackedDomains {
+		tracked := &trackedDomain{domain: domain, customers: customers}
+		l.domains[domain] = tracked
+		heap.Push(&l.fewest, tracked)
+		return
+	}
+	replaced := l.fewest[0]
+	delete(l.domains, replaced.domain)
+	replaced.domain = domain
+	replaced.customers += customers
+	l.domains[domain] = replaced
+	heap.Fix(&l.fewest, 0)
+}
+
+// snapshot sends a snapshot of the domains tracked so far
+func (l *liveTracker) snapshot() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.fn(l.takeSnapshot())
+}
+
+func (l *liveTracker) takeSnapshot() DomainSnapshot {
+	top := newTopDomains(l.top)
+	for _, tracked := range l.fewest {
+		top.offer(EmailDomain{Domain: tracked.domain, CustomerCount: tracked.customers})
+	}
+	domains := top.sorted()
+	for i := range domains {
+		domains[i].Category = l.classifier.Classify(domains[i].Domain)
+	}
+	return DomainSnapshot{Domains: domains, CustomersCounted: l.customersCounted}
+}
+
+// complete sends the last snapshot, taken from the result of the count
+func (l *liveTracker) complete(domains []EmailDomain) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	top := newTopDomains(l.top)
+	customers := 0
+	for _, domain := range domains {
+		top.offer(domain)
+		customers += domain.CustomerCount
+	}
+	l.fn(DomainSnapshot{Domains: top.sorted(), CustomersCounted: customers, Complete: true})
+}
+
+// liveBatch adds up the customers read by a single reader of a live count, handing them over to its liveTracker every
+// liveTracker.batchRows customers. A nil *liveBatch adds up nothing
+type liveBatch struct {
+	tracker *liveTracker
+	// domains holds pointers so counting an already seen domain doesn't need to allocate a string for it
+	domains   map[string]*int
+	customers int
+}
+
+func (l *liveTracker) newBatch() *liveBatch {
+	if l == nil {
+		return nil
+	}
+	return &liveBatch{tracker: l, domains: make(map[string]*int)}
+}
+
+// add adds a customer of `domain`
+func (b *liveBatch) add(domain []byte) {
+	if b == nil {
+		return
+	}
+	if customers, found := b.domains[string(domain)]; found {
+		*customers++
+	} else {
+		customers := 1
+		b.domains[string(domain)] = &customers
+	}
+	b.customers++
+	if b.customers >= b.tracker.batchRows {
+		b.flush()
+	}
+}
+
+// flush hands the customers added so far over to the tracker
+func (b *liveBatch) flush() {
+	if b == nil || b.customers == 0 {
+		return
+	}
+	b.tracker.add(b)
+	for domain := range b.domains {
+		delete(b.domains, domain)
+	}
+	b.customers = 0
+}
+
+// trackedDomains is a min-heap of domains by customers
+type trackedDomains []*trackedDomain
+
+func (h trackedDomains) Len() int           { return len(h) }
+func (h trackedDomains) Less(i, j int) bool { return h[i].customers < h[j].customers }
+func (h trackedDomains) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *trackedDomains) Push(x interface{}) {
+	tracked := x.(*trackedDomain)
+	tracked.index = len(*h)
+	*h = append(*h, tracked)
+}
+
+func (h *trackedDomains) Pop() interface{} {
+	old := *h
+	tracked := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return tracked
+}
+
+// topDomains keeps the `limit` domains with the most customers offered to it, in a min-heap so each offer takes
+// O(log limit). A limit under 1 keeps every domain
+type topDomains struct {
+	limit   int
+	domains []EmailDomain
+}
+
+func newTopDomains(limit int) *topDomains {
+	return &topDomains{limit: limit}
+}
+
+func (t *topDomains) offer(domain EmailDomain) {
+	if t.limit < 1 || len(t.domains) < t.limit {
+		heap.Push(t, domain)
+	} else if ranksBefore(domain, t.domains[0]) {
+		t.domains[0] = domain
+		heap.Fix(t, 0)
+	}
+}
+
+// sorted returns the domains kept by customer count, emptying the heap
+func (t *topDomains) sorted() []EmailDomain {
+	sorted := make([]EmailDomain, len(t.domains))
+	for i := len(sorted) - 1; i >= 0; i-- {
+		sorted[i] = heap.Pop(t).(EmailDomain)
+	}
+	return sorted
+}
+
+// ranksBefore tells whether `a` goes before `b` when ordering by customer count, ties being broken by domain
+func ranksBefore(a, b EmailDomain) bool {
+	if a.CustomerCount != b.CustomerCount {
+		return a.CustomerCount > b.CustomerCount
+	}
+	return a.Domain < b.Domain
+}
+
+func (t *topDomains) Len() int           { return len(t.domains) }
+func (t *topDomains) Less(i, j int) bool { return ranksBefore(t.domains[j], t.domains[i]) }
+func (t *topDomains) Swap(i, j int)      { t.domains[i], t.domains[j] = t.domains[j], t.domains[i] }
+
+func (t *topDomains) Push(x interface{}) {
+	t.domains = append(t.domains, x.(EmailDomain))
+}
+
+func (t *topDomains) Pop() interface{} {
+	last := t.domains[len(t.domains)-1]
+	t.domains = t.domains[:len(t.domains)-1]
+	return last
+}
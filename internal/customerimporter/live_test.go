@@ -0,0 +1,127 @@
+package customerimporter
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"reflect"
+	"testing"
+)
+
+func Test_csvCustomerImporter_CustomerCountByDomainLive(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	imp, err := NewCsvCustomerImporter("../../test/data/importer/customers-small.csv", "email")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := imp.CustomerCountByDomain()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var snapshots []DomainSnapshot
+	got, stats, err := imp.CustomerCountByDomainLive(3, 0, 2, func(snapshot DomainSnapshot) {
+		snapshots = append(snapshots, snapshot)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("CustomerCountByDomainLive() = %v, want %v", got, want)
+	}
+	if stats.RowsRead != 8 || stats.RowsRejected != 1 {
+		t.Errorf("CustomerCountByDomainLive() read %d rows and rejected %d, want 8 and 1", stats.RowsRead, stats.RowsRejected)
+	}
+
+	// 7 valid rows make for snapshots at 3 and 6 customers, then the complete one
+	if len(snapshots) != 3 {
+		t.Fatalf("CustomerCountByDomainLive() sent %d snapshots, want 3", len(snapshots))
+	}
+	wantFirst := []EmailDomain{
+		{Domain: "github.io", CustomerCount: 2, Category: CategoryCorporate},
+		{Domain: "cyberchimps.com", CustomerCount: 1, Category: CategoryCorporate},
+	}
+	if !reflect.DeepEqual(snapshots[0].Domains, wantFirst) || snapshots[0].CustomersCounted != 3 || snapshots[0].Complete {
+		t.Errorf("first snapshot = %+v, want %v after 3 customers", snapshots[0], wantFirst)
+	}
+	if snapshots[1].CustomersCounted != 6 || snapshots[1].Complete {
+		t.Errorf("second snapshot = %+v, want 6 customers", snapshots[1])
+	}
+	// Snapshots only hold the top 2 domains
+	wantLast := []EmailDomain{
+		{Domain: "github.io", CustomerCount: 4, Category: CategoryCorporate},
+		{Domain: "cyberchimps.com", CustomerCount: 2, Category: CategoryCorporate},
+	}
+	if last := snapshots[2]; !last.Complete || last.CustomersCounted != 7 || !reflect.DeepEqual(last.Domains, wantLast) {
+		t.Errorf("last snapshot = %+v, want the top of the complete result", last)
+	}
+}
+
+func Test_csvCustomerImporter_CustomerCountByDomainLive_workers(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	options := []ImporterOption{WithWorkers(3), WithMemoryBudget(4096)}
+	imp, err := NewCsvCustomerImporter("../../test/data/importer/customers.csv", "email", options...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := imp.CustomerCountByDomain()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var snapshots []DomainSnapshot
+	got, stats, err := imp.CustomerCountByDomainLive(500, 0, 5, func(snapshot DomainSnapshot) {
+		snapshots = append(snapshots, snapshot)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("CustomerCountByDomainLive() with a spilling budget and workers differs from CustomerCountByDomain()")
+	}
+	if stats.RowsRead != 3002 || stats.RowsRejected != 2 {
+		t.Errorf("CustomerCountByDomainLive() read %d rows and rejected %d, want 3002 and 2", stats.RowsRead, stats.RowsRejected)
+	}
+	if len(snapshots) < 2 {
+		t.Fatalf("CustomerCountByDomainLive() sent %d snapshots, want some before the complete one", len(snapshots))
+	}
+	for i, snapshot := range snapshots[1:] {
+		if snapshot.CustomersCounted < snapshots[i].CustomersCounted {
+			t.Errorf("snapshot %d counted %d customers, fewer than the one before", i+1, snapshot.CustomersCounted)
+		}
+	}
+	wantTop := SortDomains(append([]EmailDomain(nil), want...), OrderByCount)[:5]
+	if last := snapshots[len(snapshots)-1]; !last.Complete || last.CustomersCounted != 3000 || !reflect.DeepEqual(last.Domains, wantTop) {
+		t.Errorf("last snapshot = %+v, want the top 5 of %d customers", last, 3000)
+	}
+}
+
+func Test_liveTracker_bounded(t *testing.T) {
+	var snapshots []DomainSnapshot
+	tracker := newLiveTracker(0, 3, NewDomainClassifier(), func(snapshot DomainSnapshot) {
+		snapshots = append(snapshots, snapshot)
+	})
+	batch := tracker.newBatch()
+	for i := 0; i < 100; i++ {
+		batch.add([]byte("heavy.com"))
+	}
+	for i := 0; i < liveTrackedDomains+1000; i++ {
+		batch.add([]byte(fmt.Sprintf("domain%d.com", i)))
+	}
+	batch.flush()
+	if len(tracker.domains) != liveTrackedDomains || len(tracker.fewest) != liveTrackedDomains {
+		t.Errorf("liveTracker tracks %d domains, want at most %d", len(tracker.domains), liveTrackedDomains)
+	}
+
+	tracker.snapshot()
+	if len(snapshots) != 1 {
+		t.Fatalf("snapshot() sent %d snapshots, want 1", len(snapshots))
+	}
+	got := snapshots[0]
+	if len(got.Domains) != 3 || got.Domains[0].Domain != "heavy.com" || got.Domains[0].CustomerCount != 100 {
+		t.Errorf("snapshot() = %+v, want heavy.com with its 100 customers first of 3 domains", got.Domains)
+	}
+	if got.CustomersCounted != 100+liveTrackedDomains+1000 {
+		t.Errorf("snapshot() counted %d customers, want %d", got.CustomersCounted, 100+liveTrackedDomains+1000)
+	}
+}
@@ -0,0 +1,84 @@
+package customerimporter
+
+import (
+	"github.com/IllicLanthresh/TeamworkGoTests/pkg/metrics"
+	"time"
+)
+
+// importerMetrics records how imports go in a metrics.Registry, see WithMetrics. A nil *importerMetrics records
+// nothing
+type importerMetrics struct {
+	imports       *metrics.Counter
+	rowsRead      *metrics.Counter
+	rowsRejected  *metrics.Counter
+	bytesRead     *metrics.Counter
+	uniqueDomains *metrics.Histogram
+	counting      *metrics.Histogram
+	sorting       *metrics.Histogram
+}
+
+// NewImporterMetrics registers the metrics of imports in `registry`, to be shared by every importer created
+// WithMetrics
+func NewImporterMetrics(registry *metrics.Registry) *importerMetrics {
+	durations := metrics.ExponentialBuckets(0.001, 4, 10)
+	return &importerMetrics{
+		imports: registry.NewCounter("customer_imports_total",
+			"Imports counting customers by email domain, by result.", "result"),
+		rowsRead: registry.NewCounter("customer_import_rows_read_total",
+			"Rows read after the headers one, rejected ones included."),
+		rowsRejected: registry.NewCounter("customer_import_rows_rejected_total",
+			"Rows rejected, by reason.", "reason"),
+		bytesRead: registry.NewCounter("customer_import_bytes_read_total",
+			"Bytes of csv read, after decompressing them."),
+		uniqueDomains: registry.NewHistogram("customer_import_unique_domains",
+			"Unique email domains found by each import.", metrics.ExponentialBuckets(10, 10, 7)),
+		counting: registry.NewHistogram("customer_import_counting_seconds",
+			"Time each import spent reading, validating and counting addresses, before sorting its domains.", durations),
+		sorting: registry.NewHistogram("customer_import_sort_seconds",
+			"Time each import spent sorting its domains, by order.", durations, "order"),
+	}
+}
+
+// observeImport records a finished import, successful unless `err` is set
+func (m *importerMetrics) observeImport(stats RunStats, err error) {
+	if m == nil {
+		return
+	}
+	result := "success"
+	if err != nil {
+		result = "error"
+	}
+	m.imports.Add(1, result)
+	m.rowsRead.Add(float64(stats.RowsRead))
+	for _, reason := range stats.Rejected.byReason() {
+		if reason.Rows > 0 {
+			m.rowsRejected.Add(float64(reason.Rows), reason.Reason)
+		}
+	}
+	m.bytesRead.Add(float64(stats.BytesRead))
+}
+
+// observeCounting records how long an import took to count its addresses, and how many domains it found
+func (m *importerMetrics) observeCounting(duration time.Duration, uniqueDomains int) {
+	if m == nil {
+		return
+	}
+	m.counting.Observe(duration.Seconds())
+	m.uniqueDomains.Observe(float64(uniqueDomains))
+}
+
+// SortDomains works like the SortDomains function, recording how long it took
+func (m *importerMetrics) SortDomains(domains []EmailDomain, ordering Ordering) []EmailDomain {
+	started := time.Now()
+	sorted := SortDomains(domains, ordering)
+	m.observeSort(time.Since(started), ordering)
+	return sorted
+}
+
+// observeSort records how long sorting domains following `ordering` took
+func (m *importerMetrics) observeSort(duration time.Duration, ordering Ordering) {
+	if m == nil {
+		return
+	}
+	m.sorting.Observe(duration.Seconds(), string(ordering))
+}
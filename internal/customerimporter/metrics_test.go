@@ -0,0 +1,107 @@
+package customerimporter
+
+import (
+	"bytes"
+	"github.com/IllicLanthresh/TeamworkGoTests/pkg/metrics"
+	"io/ioutil"
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestWithMetrics(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	registry := metrics.NewRegistry()
+	importerMetrics := NewImporterMetrics(registry)
+	content := "email,name\njohn@360.cn,John\njane@example.com,Jane\nnot an address,Bob\nma\"ry@example.com,Mary\n"
+	for _, workers := range []int{1, 2} {
+		importer, err := NewReaderCustomerImporter("customers", strings.NewReader(content), "email",
+			WithMetrics(importerMetrics), WithWorkers(workers), WithOrdering(OrderByCount))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := importer.CustomerCountByDomainWithStats(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	importer, err := NewReaderCustomerImporter("empty", strings.NewReader(""), "email", WithMetrics(importerMetrics))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := importer.CustomerCountByDomain(); err == nil {
+		t.Fatal("CustomerCountByDomain() of an empty input error = nil, want an error")
+	}
+
+	tests := []struct {
+		name   string
+		metric *metrics.Counter
+		labels []string
+		want   float64
+	}{
+		{name: "successful imports", metric: importerMetrics.imports, labels: []string{"success"}, want: 2},
+		{name: "failed imports", metric: importerMetrics.imports, labels: []string{"error"}, want: 1},
+		{name: "rows read", metric: importerMetrics.rowsRead, want: 8},
+		{name: "malformed rows", metric: importerMetrics.rowsRejected, labels: []string{"malformed"}, want: 2},
+		{name: "invalid emails", metric: importerMetrics.rowsRejected, labels: []string{"invalid_email"}, want: 2},
+		{name: "bytes read", metric: importerMetrics.bytesRead, want: float64(2 * len(content))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.metric.Value(tt.labels...); got != tt.want {
+				t.Errorf("Value(%v) = %v, want %v", tt.labels, got, tt.want)
+			}
+		})
+	}
+	if got := importerMetrics.sorting.Count(string(OrderByCount)); got != 2 {
+		t.Errorf("sorts observed = %d, want 2", got)
+	}
+	if got := importerMetrics.counting.Count(); got != 2 {
+		t.Errorf("countings observed = %d, want 2", got)
+	}
+
+	var written bytes.Buffer
+	if err := registry.WriteText(&written); err != nil {
+		t.Fatal(err)
+	}
+	if want := "customer_import_unique_domains_bucket{le=\"10\"} 2\n"; !strings.Contains(written.String(), want) {
+		t.Errorf("WriteText() = %q, want it to contain %q", written.String(), want)
+	}
+}
+
+func TestWithMetrics_combine(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	importerMetrics := NewImporterMetrics(metrics.NewRegistry())
+	var importers []*csvCustomerImporter
+	for _, content := range []string{"email\njohn@360.cn\n", "email\njane@example.com\n"} {
+		importer, err := NewReaderCustomerImporter("customers", strings.NewReader(content), "email",
+			WithMetrics(importerMetrics), WithOrdering(OrderByCount))
+		if err != nil {
+			t.Fatal(err)
+		}
+		importers = append(importers, importer)
+	}
+	if _, _, err := CombineCustomerCounts(importers, 2); err != nil {
+		t.Fatal(err)
+	}
+	// Each importer sorts its own domains, and then the combined ones are sorted once more
+	if got := importerMetrics.sorting.Count(string(OrderByCount)); got != 3 {
+		t.Errorf("sorts observed = %d, want 3", got)
+	}
+}
+
+func TestWithMetrics_defaultOrdering(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	importerMetrics := NewImporterMetrics(metrics.NewRegistry())
+	importer, err := NewReaderCustomerImporter("customers", strings.NewReader("email\njohn@360.cn\n"), "email",
+		WithMetrics(importerMetrics))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := importer.CustomerCountByDomain(); err != nil {
+		t.Fatal(err)
+	}
+	// The counter sorts the domains by domain itself, which is still timed
+	if got := importerMetrics.sorting.Count(string(OrderByDomain)); got != 1 {
+		t.Errorf("sorts observed = %d, want 1", got)
+	}
+}
@@ -0,0 +1,15 @@
+package customerimporter
+
+import (
+	"os"
+	"syscall"
+)
+
+// mapFile maps the first `size` bytes of `file` into memory as read only
+func mapFile(file *os.File, size int64) ([]byte, error) {
+	return syscall.Mmap(int(file.Fd()), 0, int(size), syscall.PROT_READ, syscall.MAP_SHARED)
+}
+
+func unmapFile(data []byte) error {
+	return syscall.Munmap(data)
+}
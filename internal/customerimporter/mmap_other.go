@@ -0,0 +1,18 @@
+//go:build !linux
+// +build !linux
+
+package customerimporter
+
+import (
+	"errors"
+	"os"
+)
+
+// mapFile is only implemented on Linux, files are always read through regular reads elsewhere
+func mapFile(_ *os.File, _ int64) ([]byte, error) {
+	return nil, errors.New("memory mapped files are only supported on Linux")
+}
+
+func unmapFile(_ []byte) error {
+	return nil
+}
@@ -0,0 +1,65 @@
+package customerimporter
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// NdjsonToCsv converts newline delimited JSON, an object per customer like the ones written by the customers
+// generator, into csv with a headers row so it can be read by NewReaderCustomerImporter. The columns are the keys of
+// the first object, sorted, values missing from an object are left empty and values other than strings are written as
+// JSON. The conversion happens as the csv is read, malformed JSON failing the read, and closing the reader stops it
+func NdjsonToCsv(ndjson io.Reader) io.ReadCloser {
+	reader, writer := io.Pipe()
+	go func() {
+		_ = writer.CloseWithError(writeNdjsonAsCsv(ndjson, writer))
+	}()
+	return reader
+}
+
+func writeNdjsonAsCsv(ndjson io.Reader, w io.Writer) error {
+	decoder := json.NewDecoder(ndjson)
+	decoder.UseNumber()
+	csvWriter := csv.NewWriter(w)
+	var columns []string
+	for objects := 1; ; objects++ {
+		var object map[string]interface{}
+		if err := decoder.Decode(&object); err == io.EOF {
+			break
+		} else if err != nil {
+			return fmt.Errorf("couldn't decode JSON object %d: %w", objects, err)
+		}
+		if columns == nil {
+			columns = make([]string, 0, len(object))
+			for column := range object {
+				columns = append(columns, column)
+			}
+			sort.Strings(columns)
+			if err := csvWriter.Write(columns); err != nil {
+				return err
+			}
+		}
+		record := make([]string, len(columns))
+		for i, column := range columns {
+			switch value := object[column].(type) {
+			case nil:
+			case string:
+				record[i] = value
+			default:
+				encoded, err := json.Marshal(value)
+				if err != nil {
+					return err
+				}
+				record[i] = string(encoded)
+			}
+		}
+		if err := csvWriter.Write(record); err != nil {
+			return err
+		}
+	}
+	csvWriter.Flush()
+	return csvWriter.Error()
+}
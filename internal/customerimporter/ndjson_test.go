@@ -0,0 +1,47 @@
+package customerimporter
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestNdjsonToCsv(t *testing.T) {
+	tests := []struct {
+		name    string
+		ndjson  string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "customers",
+			ndjson: `{"first_name":"Mildred","email":"mhernandez0@github.io","age":32}` + "\n" +
+				`{"email":"bortiz1@cyberchimps.com","first_name":"Bonnie, \"Bo\"","vip":true}` + "\n",
+			want: "age,email,first_name\n32,mhernandez0@github.io,Mildred\n,bortiz1@cyberchimps.com,\"Bonnie, \"\"Bo\"\"\"\n",
+		},
+		{name: "empty", ndjson: "", want: ""},
+		{name: "malformed", ndjson: `{"email":"mhernandez0@github.io"}` + "\n{\"email\":}\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader := NdjsonToCsv(strings.NewReader(tt.ndjson))
+			defer reader.Close()
+			got, err := ioutil.ReadAll(reader)
+			if tt.wantErr {
+				var syntaxError *json.SyntaxError
+				if !errors.As(err, &syntaxError) {
+					t.Errorf("NdjsonToCsv() error = %v, want a *json.SyntaxError", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("NdjsonToCsv() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
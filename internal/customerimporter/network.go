@@ -0,0 +1,64 @@
+package customerimporter
+
+import (
+	"errors"
+	"fmt"
+	"github.com/IllicLanthresh/TeamworkGoTests/pkg/prefixTrie"
+	"log"
+	"net"
+	"strings"
+)
+
+type networkCount struct {
+	Network       *net.IPNet
+	CustomerCount int
+}
+
+// CustomerCountByNetwork outputs the count of customers for each network found in the `ipKey` column, IPv4 addresses
+// are grouped by their first `ipv4PrefixLength` bits and IPv6 ones by their first `ipv6PrefixLength` bits.
+// Networks are sorted numerically, IPv4 ones first, and rows without a valid IP address are ignored
+func (imp *csvCustomerImporter) CustomerCountByNetwork(ipKey string, ipv4PrefixLength int, ipv6PrefixLength int) (sortedNetworks []networkCount, err error) {
+	if ipKey == "" {
+		return nil, MissingColumnKey{}
+	}
+	if ipv4PrefixLength < 0 || ipv4PrefixLength > net.IPv4len*8 {
+		return nil, InvalidPrefixLengthError{length: ipv4PrefixLength, family: "IPv4"}
+	}
+	if ipv6PrefixLength < 0 || ipv6PrefixLength > net.IPv6len*8 {
+		return nil, InvalidPrefixLengthError{length: ipv6PrefixLength, family: "IPv6"}
+	}
+
+	ipAddresses, err := imp.recordsGenerator(ipKey, func(ip string) error {
+		if parseIP(ip) == nil {
+			return errors.New("invalid IP address")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("couldn't create IP address generator: %w", err)
+	}
+
+	ipv4Trie := prefixTrie.NewIPv4PrefixTrie()
+	ipv6Trie := prefixTrie.NewIPv6PrefixTrie()
+	for address := range ipAddresses {
+		if address.Err != nil {
+			log.Printf("couldn't process row: %s", address.Err)
+			continue
+		}
+		ip := parseIP(address.Value)
+		if !ipv4Trie.Add(ip, ipv4PrefixLength) {
+			ipv6Trie.Add(ip, ipv6PrefixLength)
+		}
+	}
+
+	collect := func(network *net.IPNet, count int) {
+		sortedNetworks = append(sortedNetworks, networkCount{Network: network, CustomerCount: count})
+	}
+	ipv4Trie.Walk(ipv4PrefixLength, collect)
+	ipv6Trie.Walk(ipv6PrefixLength, collect)
+	return
+}
+
+func parseIP(ip string) net.IP {
+	return net.ParseIP(strings.TrimSpace(ip))
+}
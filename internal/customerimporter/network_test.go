@@ -0,0 +1,80 @@
+package customerimporter
+
+import (
+	"io/ioutil"
+	"log"
+	"reflect"
+	"testing"
+)
+
+func Test_csvCustomerImporter_CustomerCountByNetwork(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	type args struct {
+		ipKey            string
+		ipv4PrefixLength int
+		ipv6PrefixLength int
+	}
+	tests := []struct {
+		name         string
+		args         args
+		wantNetworks []string
+		wantCounts   []int
+		wantErr      bool
+	}{
+		{
+			name:    "no ip key",
+			args:    args{ipKey: "", ipv4PrefixLength: 24, ipv6PrefixLength: 48},
+			wantErr: true,
+		},
+		{
+			name:    "ip key not found",
+			args:    args{ipKey: "quux", ipv4PrefixLength: 24, ipv6PrefixLength: 48},
+			wantErr: true,
+		},
+		{
+			name:    "invalid prefix length",
+			args:    args{ipKey: "ip_address", ipv4PrefixLength: 33, ipv6PrefixLength: 48},
+			wantErr: true,
+		},
+		{
+			name: "/16 and /48",
+			args: args{ipKey: "ip_address", ipv4PrefixLength: 16, ipv6PrefixLength: 48},
+			wantNetworks: []string{
+				"38.194.0.0/16",
+				"57.171.0.0/16",
+				"197.54.0.0/16",
+				"243.219.0.0/16",
+				"2001:db8:85a3::/48",
+			},
+			wantCounts: []int{3, 1, 1, 1, 2},
+		},
+		{
+			name:         "/8 and /16",
+			args:         args{ipKey: "ip_address", ipv4PrefixLength: 8, ipv6PrefixLength: 16},
+			wantNetworks: []string{"38.0.0.0/8", "57.0.0.0/8", "197.0.0.0/8", "243.0.0.0/8", "2001::/16"},
+			wantCounts:   []int{3, 1, 1, 1, 2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			imp, err := NewCsvCustomerImporter("../../test/data/importer/customers-small.csv", "email")
+			if err != nil {
+				t.Fatal(err)
+			}
+			gotNetworks, err := imp.CustomerCountByNetwork(tt.args.ipKey, tt.args.ipv4PrefixLength, tt.args.ipv6PrefixLength)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CustomerCountByNetwork() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			var networks []string
+			var counts []int
+			for _, network := range gotNetworks {
+				networks = append(networks, network.Network.String())
+				counts = append(counts, network.CustomerCount)
+			}
+			if !reflect.DeepEqual(networks, tt.wantNetworks) || !reflect.DeepEqual(counts, tt.wantCounts) {
+				t.Errorf("CustomerCountByNetwork() = %v %v, want %v %v", networks, counts, tt.wantNetworks, tt.wantCounts)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package customerimporter
+
+import (
+	"github.com/IllicLanthresh/TeamworkGoTests/pkg/rowFilter"
+)
+
+// ImporterOption tweaks the behaviour of a csvCustomerImporter, see NewCsvCustomerImporter
+type ImporterOption func(imp *csvCustomerImporter) error
+
+// WithFilter makes the importer ignore any rows not matching `expression`, see package rowFilter for its syntax.
+// Syntax errors are returned by the constructor as a *rowFilter.ParseError
+func WithFilter(expression string) ImporterOption {
+	return func(imp *csvCustomerImporter) error {
+		filter, err := rowFilter.Parse(expression)
+		if err != nil {
+			return err
+		}
+		imp.filter = filter
+		return nil
+	}
+}
+
+// WithMemoryBudget sets roughly how many bytes analyses able to spill to disk keep in memory before writing their
+// state to temporary files, defaults to 64 MiB
+func WithMemoryBudget(bytes int64) ImporterOption {
+	return func(imp *csvCustomerImporter) error {
+		if bytes <= 0 {
+			return InvalidMemoryBudgetError{bytes: bytes}
+		}
+		imp.memoryBudget = bytes
+		return nil
+	}
+}
+
+// WithDomainClassifier sets the classifier used to categorize the domains, NewDomainClassifier is used by default
+func WithDomainClassifier(classifier *domainClassifier) ImporterOption {
+	return func(imp *csvCustomerImporter) error {
+		imp.classifier = classifier
+		return nil
+	}
+}
+
+// WithOrdering sets the order CustomerCountByDomain outputs domains in, OrderByDomain by default
+func WithOrdering(ordering Ordering) ImporterOption {
+	return func(imp *csvCustomerImporter) error {
+		if _, err := ParseOrdering(string(ordering)); err != nil {
+			return err
+		}
+		imp.ordering = ordering
+		return nil
+	}
+}
+
+// WithWorkers sets how many goroutines validate and count addresses in CustomerCountByDomain, 1 by default which
+// keeps the sequential path
+func WithWorkers(workers int) ImporterOption {
+	return func(imp *csvCustomerImporter) error {
+		if workers < 1 {
+			return InvalidWorkerCountError{workers: workers}
+		}
+		imp.workers = workers
+		return nil
+	}
+}
+
+// WithMemoryMap makes CustomerCountByDomain map the csv file into memory and scan it in place, instead of reading it
+// through a buffer. Only regular files on Linux are mapped, anything else falls back to regular reads
+func WithMemoryMap() ImporterOption {
+	return func(imp *csvCustomerImporter) error {
+		imp.memoryMap = true
+		return nil
+	}
+}
+
+// WithDialect sets the delimiter and comment character of the csv file, a comma and none by default
+func WithDialect(dialect Dialect) ImporterOption {
+	return func(imp *csvCustomerImporter) error {
+		if err := dialect.validate(); err != nil {
+			return err
+		}
+		imp.dialect = dialect
+		return nil
+	}
+}
+
+// WithMetrics makes CustomerCountByDomain record rows, bytes, domains and timings of every import in `metrics`, see
+// NewImporterMetrics
+func WithMetrics(metrics *importerMetrics) ImporterOption {
+	return func(imp *csvCustomerImporter) error {
+		imp.metrics = metrics
+		return nil
+	}
+}
@@ -0,0 +1,66 @@
+package customerimporter
+
+import (
+	"errors"
+	"github.com/IllicLanthresh/TeamworkGoTests/pkg/rowFilter"
+	"io/ioutil"
+	"log"
+	"reflect"
+	"testing"
+)
+
+func TestWithFilter(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	tests := []struct {
+		name        string
+		expression  string
+		wantDomains []EmailDomain
+		wantErr     bool
+	}{
+		{
+			name:       "syntax error",
+			expression: "gender ==",
+			wantErr:    true,
+		},
+		{
+			name:       "unknown column",
+			expression: "quux == \"foo\"",
+			wantErr:    true,
+		},
+		{
+			name:       "by gender",
+			expression: "gender == \"Female\"",
+			wantDomains: []EmailDomain{
+				{Domain: "cyberchimps.com", CustomerCount: 1, Category: CategoryCorporate},
+				{Domain: "github.io", CustomerCount: 2, Category: CategoryCorporate},
+			},
+		},
+		{
+			name:       "by network",
+			expression: "ip_address within \"38.194.0.0/16\" and not first_name in (\"Norma\")",
+			wantDomains: []EmailDomain{
+				{Domain: "github.io", CustomerCount: 2, Category: CategoryCorporate},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			imp, err := NewCsvCustomerImporter("../../test/data/importer/customers-small.csv", "email", WithFilter(tt.expression))
+			if err != nil {
+				var parseError *rowFilter.ParseError
+				if !tt.wantErr || !errors.As(err, &parseError) {
+					t.Errorf("NewCsvCustomerImporter() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				return
+			}
+			gotDomains, err := imp.CustomerCountByDomain()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CustomerCountByDomain() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(gotDomains, tt.wantDomains) {
+				t.Errorf("CustomerCountByDomain() = %#v, want %#v", gotDomains, tt.wantDomains)
+			}
+		})
+	}
+}
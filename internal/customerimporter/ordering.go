@@ -0,0 +1,106 @@
+package customerimporter
+
+import (
+	"github.com/IllicLanthresh/TeamworkGoTests/pkg/radixSorter"
+	"strings"
+)
+
+// Ordering is the order CustomerCountByDomain outputs domains in
+type Ordering string
+
+const (
+	// OrderByDomain sorts alphabetically by domain, the default
+	OrderByDomain Ordering = "domain"
+	// OrderByCount sorts by customer count, most customers first, alphabetically by domain on ties
+	OrderByCount Ordering = "count"
+	// OrderByReversedLabels sorts by the labels of the domain in reverse (com.acme.mail), so subdomains of the same
+	// organization end up together
+	OrderByReversedLabels Ordering = "reversed"
+	// OrderByTld sorts by top level domain, alphabetically by domain within the same one
+	OrderByTld Ordering = "tld"
+)
+
+// ParseOrdering validates the name of an Ordering
+func ParseOrdering(name string) (Ordering, error) {
+	switch ordering := Ordering(name); ordering {
+	case OrderByDomain, OrderByCount, OrderByReversedLabels, OrderByTld:
+		return ordering, nil
+	}
+	return "", InvalidOrderingError{name: name}
+}
+
+// SortDomains returns a copy of `domains` sorted following `ordering`, without any comparison sort involved so it
+// scales linearly with the number of domains
+func SortDomains(domains []EmailDomain, ordering Ordering) []EmailDomain {
+	var order []int
+	switch ordering {
+	case OrderByCount:
+		// Descending order comes from complementing the counts, and ties stay alphabetical since the sort is stable
+		order = alphabeticalOrder(domains, func(domain string) string { return domain })
+		keys := make([]uint64, len(order))
+		for i, index := range order {
+			keys[i] = ^uint64(domains[index].CustomerCount)
+		}
+		order = reorder(order, radixSorter.StableOrder(keys))
+	case OrderByReversedLabels:
+		order = alphabeticalOrder(domains, reverseLabels)
+	case OrderByTld:
+		order = alphabeticalOrder(domains, func(domain string) string { return domain })
+		tlds := make([]string, len(order))
+		for i, index := range order {
+			tlds[i] = tldOf(domains[index].Domain)
+		}
+		sorter := radixSorter.NewRadixSorter()
+		sorter.Add(tlds...)
+		rankOf := make(map[string]uint64)
+		for rank, tld := range sorter.Sort() {
+			rankOf[tld] = uint64(rank)
+		}
+		keys := make([]uint64, len(tlds))
+		for i, tld := range tlds {
+			keys[i] = rankOf[strings.ToLower(tld)]
+		}
+		order = reorder(order, radixSorter.StableOrder(keys))
+	default:
+		order = alphabeticalOrder(domains, func(domain string) string { return domain })
+	}
+
+	sorted := make([]EmailDomain, len(order))
+	for i, index := range order {
+		sorted[i] = domains[index]
+	}
+	return sorted
+}
+
+// alphabeticalOrder returns the indexes of `domains` sorted by the radix sorter on the key `keyOf` gives each domain,
+// domains sharing a key are kept in their original order
+func alphabeticalOrder(domains []EmailDomain, keyOf func(domain string) string) []int {
+	keys := make([]string, len(domains))
+	for i, domain := range domains {
+		keys[i] = keyOf(domain.Domain)
+	}
+	return radixSorter.StringOrder(keys)
+}
+
+// reorder applies the permutation `order` to `indexes`
+func reorder(indexes []int, order []int) []int {
+	reordered := make([]int, len(order))
+	for i, position := range order {
+		reordered[i] = indexes[position]
+	}
+	return reordered
+}
+
+// reverseLabels turns mail.acme.com into a sort key equivalent to com.acme.mail, labels are joined with a byte lower
+// than any valid domain character so acme.com subdomains go right after it, before acme-corp.com
+func reverseLabels(domain string) string {
+	labels := strings.Split(domain, ".")
+	for i, j := 0, len(labels)-1; i < j; i, j = i+1, j-1 {
+		labels[i], labels[j] = labels[j], labels[i]
+	}
+	return strings.Join(labels, "\x01")
+}
+
+func tldOf(domain string) string {
+	return domain[strings.LastIndexByte(domain, '.')+1:]
+}
@@ -0,0 +1,100 @@
+package customerimporter
+
+import (
+	"io/ioutil"
+	"log"
+	"reflect"
+	"testing"
+)
+
+func TestSortDomains(t *testing.T) {
+	domains := []EmailDomain{
+		{Domain: "acme-corp.com", CustomerCount: 2},
+		{Domain: "acme.com", CustomerCount: 5},
+		{Domain: "acme.org", CustomerCount: 5},
+		{Domain: "eu.mail.acme.com", CustomerCount: 1},
+		{Domain: "github.io", CustomerCount: 300},
+		{Domain: "mail.acme.com", CustomerCount: 2},
+	}
+	tests := []struct {
+		ordering Ordering
+		want     []string
+	}{
+		{
+			ordering: OrderByDomain,
+			want:     []string{"acme-corp.com", "acme.com", "acme.org", "eu.mail.acme.com", "github.io", "mail.acme.com"},
+		},
+		{
+			ordering: OrderByCount,
+			want:     []string{"github.io", "acme.com", "acme.org", "acme-corp.com", "mail.acme.com", "eu.mail.acme.com"},
+		},
+		{
+			ordering: OrderByReversedLabels,
+			want:     []string{"acme.com", "mail.acme.com", "eu.mail.acme.com", "acme-corp.com", "github.io", "acme.org"},
+		},
+		{
+			ordering: OrderByTld,
+			want:     []string{"acme-corp.com", "acme.com", "eu.mail.acme.com", "mail.acme.com", "github.io", "acme.org"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.ordering), func(t *testing.T) {
+			// Shuffled input makes sure the ordering doesn't rely on the input being alphabetical
+			shuffled := []EmailDomain{domains[4], domains[1], domains[5], domains[0], domains[3], domains[2]}
+			var got []string
+			for _, domain := range SortDomains(shuffled, tt.ordering) {
+				got = append(got, domain.Domain)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SortDomains() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseOrdering(t *testing.T) {
+	if _, err := ParseOrdering("quux"); err == nil {
+		t.Errorf("ParseOrdering() expected an error for an unknown ordering")
+	}
+	if ordering, err := ParseOrdering("count"); err != nil || ordering != OrderByCount {
+		t.Errorf("ParseOrdering() = %v, %v, want %v", ordering, err, OrderByCount)
+	}
+}
+
+func TestWithOrdering(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	imp, err := NewCsvCustomerImporter("../../test/data/importer/customers-small.csv", "email", WithOrdering(OrderByCount))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gotDomains, err := imp.CustomerCountByDomain()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, domain := range gotDomains {
+		got = append(got, domain.Domain)
+	}
+	if want := []string{"github.io", "cyberchimps.com", "360.cn"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("CustomerCountByDomain() = %v, want %v", got, want)
+	}
+}
+
+func BenchmarkSortDomains(b *testing.B) {
+	log.SetOutput(ioutil.Discard)
+	imp, err := NewCsvCustomerImporter("../../test/data/importer/customers.csv", "email")
+	if err != nil {
+		b.Fatal(err)
+	}
+	domains, err := imp.CustomerCountByDomain()
+	if err != nil {
+		b.Fatal(err)
+	}
+	for _, ordering := range []Ordering{OrderByCount, OrderByReversedLabels, OrderByTld} {
+		b.Run(string(ordering), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				SortDomains(domains, ordering)
+			}
+		})
+	}
+}
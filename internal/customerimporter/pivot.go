@@ -0,0 +1,135 @@
+package customerimporter
+
+import (
+	"encoding/csv"
+	"fmt"
+	"github.com/IllicLanthresh/TeamworkGoTests/pkg/radixSorter"
+	"io"
+	"log"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+)
+
+// domainPivot is a matrix of customer counts, one row per email domain and one column per distinct value found
+// in the group-by column, along with the totals of every row and column
+type domainPivot struct {
+	ColumnKey    string
+	ColumnValues []string
+	Rows         []domainPivotRow
+	ColumnTotals []int
+	Total        int
+}
+
+// domainPivotRow holds the customer count of a domain for each value in domainPivot.ColumnValues
+type domainPivotRow struct {
+	Domain        string
+	Counts        []int
+	CustomerCount int
+}
+
+// CustomerCountByDomainGroupedBy works like CustomerCountByDomain but splits the count of each domain by the values
+// of the `columnKey` column, rows are sorted by domain and columns by value
+func (imp *csvCustomerImporter) CustomerCountByDomainGroupedBy(columnKey string) (pivot *domainPivot, err error) {
+	if columnKey == "" {
+		return nil, MissingColumnKey{}
+	}
+	emailAddresses, err := imp.emailAddressesGenerator(columnKey)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't create address generator: %w", err)
+	}
+
+	countByDomainAndValue := make(map[string]map[string]int)
+	valueTotals := make(map[string]int)
+	sorter := radixSorter.NewRadixSorter()
+
+	for address := range emailAddresses {
+		if address.Err != nil {
+			log.Printf("couldn't process row: %s", address.Err)
+			continue
+		}
+		domain := domainOf(address.Value)
+		value := address.Columns[0]
+
+		countByValue, exists := countByDomainAndValue[domain]
+		if !exists {
+			countByValue = make(map[string]int)
+			countByDomainAndValue[domain] = countByValue
+			sorter.Add(domain)
+		}
+		countByValue[value] += 1
+		valueTotals[value] += 1
+	}
+
+	// Column values keep their original casing, so they can't go through the radix sorter, there are usually
+	// just a handful of them anyway
+	pivot = &domainPivot{ColumnKey: columnKey}
+	for value := range valueTotals {
+		pivot.ColumnValues = append(pivot.ColumnValues, value)
+	}
+	sort.Strings(pivot.ColumnValues)
+	for _, value := range pivot.ColumnValues {
+		pivot.ColumnTotals = append(pivot.ColumnTotals, valueTotals[value])
+		pivot.Total += valueTotals[value]
+	}
+
+	for _, domain := range sorter.Sort() {
+		row := domainPivotRow{
+			Domain: domain,
+			Counts: make([]int, len(pivot.ColumnValues)),
+		}
+		for i, value := range pivot.ColumnValues {
+			row.Counts[i] = countByDomainAndValue[domain][value]
+			row.CustomerCount += row.Counts[i]
+		}
+		pivot.Rows = append(pivot.Rows, row)
+	}
+	return
+}
+
+// records flattens the pivot into a headers row, a row per domain and a final row of totals
+func (p *domainPivot) records() [][]string {
+	headers := []string{"domain"}
+	headers = append(headers, p.ColumnValues...)
+	headers = append(headers, "total")
+	records := [][]string{headers}
+
+	for _, row := range p.Rows {
+		record := []string{row.Domain}
+		for _, count := range row.Counts {
+			record = append(record, strconv.Itoa(count))
+		}
+		records = append(records, append(record, strconv.Itoa(row.CustomerCount)))
+	}
+
+	totals := []string{"total"}
+	for _, count := range p.ColumnTotals {
+		totals = append(totals, strconv.Itoa(count))
+	}
+	return append(records, append(totals, strconv.Itoa(p.Total)))
+}
+
+// WriteCsv renders the pivot as a wide CSV, with a column for each value and a final row of totals
+func (p *domainPivot) WriteCsv(w io.Writer) error {
+	csvWriter := csv.NewWriter(w)
+	if err := csvWriter.WriteAll(p.records()); err != nil {
+		return fmt.Errorf("couldn't write pivot as CSV: %w", err)
+	}
+	return nil
+}
+
+// WriteTable renders the pivot as an aligned text table
+func (p *domainPivot) WriteTable(w io.Writer) error {
+	tabWriter := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	for _, record := range p.records() {
+		for _, field := range record {
+			if _, err := fmt.Fprintf(tabWriter, "%s\t", field); err != nil {
+				return fmt.Errorf("couldn't write pivot as table: %w", err)
+			}
+		}
+		if _, err := fmt.Fprintln(tabWriter); err != nil {
+			return fmt.Errorf("couldn't write pivot as table: %w", err)
+		}
+	}
+	return tabWriter.Flush()
+}
@@ -0,0 +1,87 @@
+package customerimporter
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"reflect"
+	"testing"
+)
+
+func Test_csvCustomerImporter_CustomerCountByDomainGroupedBy(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	tests := []struct {
+		name      string
+		columnKey string
+		wantPivot *domainPivot
+		wantErr   bool
+	}{
+		{
+			name:      "no column key",
+			columnKey: "",
+			wantErr:   true,
+		},
+		{
+			name:      "column not found",
+			columnKey: "quux",
+			wantErr:   true,
+		},
+		{
+			name:      "by gender",
+			columnKey: "gender",
+			wantPivot: &domainPivot{
+				ColumnKey:    "gender",
+				ColumnValues: []string{"Female", "Male"},
+				Rows: []domainPivotRow{
+					{Domain: "360.cn", Counts: []int{0, 1}, CustomerCount: 1},
+					{Domain: "cyberchimps.com", Counts: []int{1, 1}, CustomerCount: 2},
+					{Domain: "github.io", Counts: []int{2, 2}, CustomerCount: 4},
+				},
+				ColumnTotals: []int{3, 4},
+				Total:        7,
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			imp, err := NewCsvCustomerImporter("../../test/data/importer/customers-small.csv", "email")
+			if err != nil {
+				t.Fatal(err)
+			}
+			gotPivot, err := imp.CustomerCountByDomainGroupedBy(tt.columnKey)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CustomerCountByDomainGroupedBy() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(gotPivot, tt.wantPivot) {
+				t.Errorf("CustomerCountByDomainGroupedBy() = %#v, want %#v", gotPivot, tt.wantPivot)
+			}
+		})
+	}
+}
+
+func Test_domainPivot_WriteCsv(t *testing.T) {
+	pivot := &domainPivot{
+		ColumnKey:    "gender",
+		ColumnValues: []string{"Female", "Male"},
+		Rows: []domainPivotRow{
+			{Domain: "360.cn", Counts: []int{0, 1}, CustomerCount: 1},
+			{Domain: "github.io", Counts: []int{2, 2}, CustomerCount: 4},
+		},
+		ColumnTotals: []int{2, 3},
+		Total:        5,
+	}
+	want := "domain,Female,Male,total\n" +
+		"360.cn,0,1,1\n" +
+		"github.io,2,2,4\n" +
+		"total,2,3,5\n"
+
+	var got bytes.Buffer
+	if err := pivot.WriteCsv(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.String() != want {
+		t.Errorf("WriteCsv() = %q, want %q", got.String(), want)
+	}
+}
@@ -0,0 +1,104 @@
+package customerimporter
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"fmt"
+	"github.com/IllicLanthresh/TeamworkGoTests/pkg/helperTypes"
+	"io"
+	"log"
+	"math"
+	"math/rand"
+)
+
+// OtherDomain is the name of the domain small domains are bucketed into by SuppressSmallDomains
+const OtherDomain = "other"
+
+// SuppressSmallDomains makes `domains` k-anonymous, removing any domain with fewer than `k` customers.
+// If `bucket` is set their customers are added to a final OtherDomain entry instead, which is itself removed if it
+// still has fewer than `k` customers
+func SuppressSmallDomains(domains []EmailDomain, k int, bucket bool) (suppressed []EmailDomain) {
+	other := EmailDomain{Domain: OtherDomain}
+	for _, domain := range domains {
+		if domain.CustomerCount >= k {
+			suppressed = append(suppressed, domain)
+		} else {
+			other.CustomerCount += domain.CustomerCount
+		}
+	}
+	if bucket && other.CustomerCount > 0 && other.CustomerCount >= k {
+		suppressed = append(suppressed, other)
+	}
+	return
+}
+
+// AddLaplaceNoise returns a copy of `domains` with noise drawn from a Laplace distribution of scale 1/`epsilon` added
+// to every count, which makes them epsilon-differentially private since a customer only counts towards one domain.
+// Noisy counts are rounded and never negative. Noise should be added before suppressing small domains, otherwise the
+// suppression itself leaks the real counts
+func AddLaplaceNoise(domains []EmailDomain, epsilon float64, random *rand.Rand) ([]EmailDomain, error) {
+	if epsilon <= 0 || math.IsInf(epsilon, 0) || math.IsNaN(epsilon) {
+		return nil, InvalidEpsilonError{epsilon: epsilon}
+	}
+	scale := 1 / epsilon
+	noisy := make([]EmailDomain, len(domains))
+	for i, domain := range domains {
+		count := math.Round(float64(domain.CustomerCount) + laplace(random, scale))
+		domain.CustomerCount = int(math.Max(count, 0))
+		noisy[i] = domain
+	}
+	return noisy, nil
+}
+
+// laplace samples a Laplace distribution centered at 0 by inverting its cumulative distribution function
+func laplace(random *rand.Rand, scale float64) float64 {
+	u := random.Float64() - 0.5
+	if u < 0 {
+		return scale * math.Log(1+2*u)
+	}
+	return -scale * math.Log(1-2*u)
+}
+
+// ExportHashedRows writes every row with a valid email address to `w` as CSV, headers included, replacing the
+// address with the hex encoded HMAC-SHA256 of its lowercase form keyed with `key`.
+// Rows keep being joinable by address among exports sharing the same key, without disclosing the address
+func (imp *csvCustomerImporter) ExportHashedRows(w io.Writer, key []byte) error {
+	if len(key) == 0 {
+		return MissingHmacKey{}
+	}
+	headers, err := imp.Headers()
+	if err != nil {
+		return err
+	}
+	emailAddresses, err := imp.emailAddressesGenerator(headers...)
+	if err != nil {
+		return fmt.Errorf("couldn't create address generator: %w", err)
+	}
+	defer drain(emailAddresses)
+	emailIndex := helperTypes.StringSlice(headers).IndexOf(imp.emailKey)
+
+	csvWriter := csv.NewWriter(w)
+	if err := csvWriter.Write(headers); err != nil {
+		return fmt.Errorf("couldn't write hashed rows: %w", err)
+	}
+	mac := hmac.New(sha256.New, key)
+	for address := range emailAddresses {
+		if address.Err != nil {
+			log.Printf("couldn't process row: %s", address.Err)
+			continue
+		}
+		mac.Reset()
+		_, _ = mac.Write([]byte(canonicalAddress(address.Value, false)))
+		address.Columns[emailIndex] = hex.EncodeToString(mac.Sum(nil))
+		if err := csvWriter.Write(address.Columns); err != nil {
+			return fmt.Errorf("couldn't write hashed rows: %w", err)
+		}
+	}
+	csvWriter.Flush()
+	if err := csvWriter.Error(); err != nil {
+		return fmt.Errorf("couldn't write hashed rows: %w", err)
+	}
+	return nil
+}
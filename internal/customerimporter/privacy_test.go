@@ -0,0 +1,122 @@
+package customerimporter
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSuppressSmallDomains(t *testing.T) {
+	domains := []EmailDomain{
+		{Domain: "acme.com", CustomerCount: 1},
+		{Domain: "gmail.com", CustomerCount: 10},
+		{Domain: "smith-family.net", CustomerCount: 2},
+		{Domain: "yahoo.com", CustomerCount: 5},
+	}
+	tests := []struct {
+		name   string
+		k      int
+		bucket bool
+		want   []EmailDomain
+	}{
+		{
+			name: "drop",
+			k:    5,
+			want: []EmailDomain{{Domain: "gmail.com", CustomerCount: 10}, {Domain: "yahoo.com", CustomerCount: 5}},
+		},
+		{
+			name:   "bucket",
+			k:      3,
+			bucket: true,
+			want: []EmailDomain{
+				{Domain: "gmail.com", CustomerCount: 10},
+				{Domain: "yahoo.com", CustomerCount: 5},
+				{Domain: OtherDomain, CustomerCount: 3},
+			},
+		},
+		{
+			name:   "bucket still too small",
+			k:      4,
+			bucket: true,
+			want:   []EmailDomain{{Domain: "gmail.com", CustomerCount: 10}, {Domain: "yahoo.com", CustomerCount: 5}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SuppressSmallDomains(domains, tt.k, tt.bucket); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SuppressSmallDomains() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAddLaplaceNoise(t *testing.T) {
+	domains := make([]EmailDomain, 2000)
+	for i := range domains {
+		domains[i] = EmailDomain{Domain: "acme.com", CustomerCount: 100}
+	}
+
+	if _, err := AddLaplaceNoise(domains, 0, rand.New(rand.NewSource(1))); err == nil {
+		t.Errorf("AddLaplaceNoise() expected an error for epsilon 0")
+	}
+
+	noisy, err := AddLaplaceNoise(domains, 0.5, rand.New(rand.NewSource(1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, _ := AddLaplaceNoise(domains, 0.5, rand.New(rand.NewSource(1)))
+	if !reflect.DeepEqual(noisy, again) {
+		t.Errorf("AddLaplaceNoise() is not reproducible with the same seed")
+	}
+
+	// The mean absolute deviation of a Laplace distribution is its scale, 2 for an epsilon of 0.5
+	var deviation float64
+	for _, domain := range noisy {
+		difference := float64(domain.CustomerCount - 100)
+		if difference < 0 {
+			difference = -difference
+		}
+		deviation += difference
+	}
+	if deviation /= float64(len(noisy)); deviation < 1.7 || deviation > 2.3 {
+		t.Errorf("AddLaplaceNoise() mean absolute deviation = %v, want about 2", deviation)
+	}
+	if domains[0].CustomerCount != 100 {
+		t.Errorf("AddLaplaceNoise() modified its input")
+	}
+}
+
+func Test_csvCustomerImporter_ExportHashedRows(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	imp, err := NewCsvCustomerImporter("../../test/data/importer/customers-small.csv", "email")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := imp.ExportHashedRows(&bytes.Buffer{}, nil); err == nil {
+		t.Errorf("ExportHashedRows() expected an error without key")
+	}
+
+	var exported bytes.Buffer
+	if err := imp.ExportHashedRows(&exported, []byte("secret")); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(exported.String()), "\n")
+	if len(lines) != 8 {
+		t.Fatalf("ExportHashedRows() wrote %d lines, want headers and 7 valid rows", len(lines))
+	}
+	if lines[0] != "first_name,last_name,email,gender,ip_address" {
+		t.Errorf("ExportHashedRows() headers = %s", lines[0])
+	}
+	// HMAC-SHA256 of "mhernandez0@github.io" keyed with "secret"
+	want := "Mildred,Hernandez,08b6481882449a9313f62729337f9009f53d08a295e9935fb18d2533b6704ac4,Female,38.194.51.128"
+	if lines[1] != want {
+		t.Errorf("ExportHashedRows() row = %s, want %s", lines[1], want)
+	}
+	if strings.Split(lines[7], ",")[2] != strings.Split(want, ",")[2] {
+		t.Errorf("ExportHashedRows() hashed the same address differently: %s", lines[7])
+	}
+}
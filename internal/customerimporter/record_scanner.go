@@ -0,0 +1,226 @@
+package customerimporter
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"io"
+)
+
+// recordScanner parses csv records as byte slices, reusing its buffers so scanning a record doesn't allocate.
+// It follows the same rules as a csv.Reader with its default settings, including checking that every record has as many
+// fields as the first one, and fails with the same errors
+type recordScanner struct {
+	reader *bufio.Reader
+	// data is the input when it's already in memory, lines are sliced from it instead of going through reader
+	data   []byte
+	offset int
+	// lineBuffer holds lines longer than the bufio.Reader buffer, or normalized copies of the lines of data
+	lineBuffer []byte
+	// record holds the unquoted fields of the current record one after the other, fieldEnds being where each one ends
+	record    []byte
+	fieldEnds []int
+	line      int
+	// fieldsPerRecord is the number of fields of the first record
+	fieldsPerRecord int
+	delimiter       byte
+	comment         byte
+}
+
+func newRecordScanner(reader io.Reader, dialect Dialect) *recordScanner {
+	return &recordScanner{reader: bufio.NewReader(reader), delimiter: dialect.delimiter(), comment: dialect.Comment}
+}
+
+// newBytesRecordScanner returns a recordScanner reading `data` in place, which must start at the beginning of a record
+func newBytesRecordScanner(data []byte, dialect Dialect) *recordScanner {
+	return &recordScanner{data: data, delimiter: dialect.delimiter(), comment: dialect.Comment}
+}
+
+// Scan parses the next record, failing with io.EOF at the end of the input. When a record fails with a
+// *csv.ParseError the following records can still be scanned
+func (s *recordScanner) Scan() error {
+	line, errRead := s.readLine()
+	for errRead == nil && (len(line) == lengthNL(line) || s.comment != 0 && line[0] == s.comment) {
+		// Empty and comment lines are skipped
+		line, errRead = s.readLine()
+	}
+	if errRead == io.EOF {
+		return io.EOF
+	}
+
+	startLine := s.line
+	s.record = s.record[:0]
+	s.fieldEnds = s.fieldEnds[:0]
+	// fieldLine and column are where parsing is at, used to report errors as csv.Reader does
+	fieldLine, column := s.line, 1
+parseField:
+	for {
+		if len(line) == 0 || line[0] != '"' {
+			i := bytes.IndexByte(line, s.delimiter)
+			field := line
+			if i >= 0 {
+				field = field[:i]
+			} else {
+				field = field[:len(field)-lengthNL(field)]
+			}
+			if j := bytes.IndexByte(field, '"'); j >= 0 {
+				return &csv.ParseError{StartLine: startLine, Line: s.line, Column: column + j, Err: csv.ErrBareQuote}
+			}
+			s.record = append(s.record, field...)
+			s.fieldEnds = append(s.fieldEnds, len(s.record))
+			if i < 0 {
+				break parseField
+			}
+			line = line[i+1:]
+			column += i + 1
+			continue parseField
+		}
+
+		// Quoted fields go on until the closing quote, which may be some lines below
+		line = line[1:]
+		column++
+		for {
+			i := bytes.IndexByte(line, '"')
+			switch {
+			case i >= 0:
+				s.record = append(s.record, line[:i]...)
+				line = line[i+1:]
+				column += i + 1
+				switch {
+				case len(line) > 0 && line[0] == '"':
+					// An escaped quote
+					s.record = append(s.record, '"')
+					line = line[1:]
+					column++
+				case len(line) > 0 && line[0] == s.delimiter:
+					line = line[1:]
+					column++
+					s.fieldEnds = append(s.fieldEnds, len(s.record))
+					continue parseField
+				case lengthNL(line) == len(line):
+					s.fieldEnds = append(s.fieldEnds, len(s.record))
+					break parseField
+				default:
+					return &csv.ParseError{StartLine: startLine, Line: s.line, Column: column - 1, Err: csv.ErrQuote}
+				}
+			case len(line) > 0:
+				s.record = append(s.record, line...)
+				if errRead != nil {
+					break parseField
+				}
+				column += len(line)
+				line, errRead = s.readLine()
+				if len(line) > 0 {
+					fieldLine++
+					column = 1
+				}
+				if errRead == io.EOF {
+					errRead = nil
+				}
+			default:
+				if errRead == nil {
+					return &csv.ParseError{StartLine: startLine, Line: fieldLine, Column: column, Err: csv.ErrQuote}
+				}
+				s.fieldEnds = append(s.fieldEnds, len(s.record))
+				break parseField
+			}
+		}
+	}
+	if errRead != nil {
+		return errRead
+	}
+
+	if s.fieldsPerRecord == 0 {
+		s.fieldsPerRecord = len(s.fieldEnds)
+	} else if len(s.fieldEnds) != s.fieldsPerRecord {
+		return &csv.ParseError{StartLine: startLine, Line: startLine, Column: 1, Err: csv.ErrFieldCount}
+	}
+	return nil
+}
+
+// readLine reads the next line, normalizing its line ending to \n
+func (s *recordScanner) readLine() (line []byte, err error) {
+	if s.reader == nil {
+		line, err = s.sliceLine()
+	} else {
+		line, err = s.reader.ReadSlice('\n')
+		if err == bufio.ErrBufferFull {
+			s.lineBuffer = append(s.lineBuffer[:0], line...)
+			for err == bufio.ErrBufferFull {
+				line, err = s.reader.ReadSlice('\n')
+				s.lineBuffer = append(s.lineBuffer, line...)
+			}
+			line = s.lineBuffer
+		}
+	}
+	if len(line) > 0 && err == io.EOF {
+		err = nil
+		// csv.Reader drops a trailing \r before the end of the file
+		if line[len(line)-1] == '\r' {
+			line = line[:len(line)-1]
+		}
+	}
+	s.line++
+	if n := len(line); n >= 2 && line[n-2] == '\r' && line[n-1] == '\n' {
+		if s.reader == nil {
+			// data may be read only memory
+			s.lineBuffer = append(append(s.lineBuffer[:0], line[:n-2]...), '\n')
+			line = s.lineBuffer
+		} else {
+			line[n-2] = '\n'
+			line = line[:n-1]
+		}
+	}
+	return line, err
+}
+
+// sliceLine works like bufio.Reader.ReadSlice on data
+func (s *recordScanner) sliceLine() ([]byte, error) {
+	rest := s.data[s.offset:]
+	end := bytes.IndexByte(rest, '\n')
+	if end == -1 {
+		s.offset = len(s.data)
+		return rest, io.EOF
+	}
+	s.offset += end + 1
+	return rest[:end+1], nil
+}
+
+// Offset returns how many bytes of the data given to newBytesRecordScanner have been scanned
+func (s *recordScanner) Offset() int {
+	return s.offset
+}
+
+// Len returns the number of fields of the current record
+func (s *recordScanner) Len() int {
+	return len(s.fieldEnds)
+}
+
+// Field returns the unquoted bytes of field `i` of the current record, they are only valid until the next Scan
+func (s *recordScanner) Field(i int) []byte {
+	start := 0
+	if i > 0 {
+		start = s.fieldEnds[i-1]
+	}
+	return s.record[start:s.fieldEnds[i]]
+}
+
+// Strings returns a copy of the current record as csv.Reader would have read it
+func (s *recordScanner) Strings() []string {
+	record := string(s.record)
+	fields := make([]string, len(s.fieldEnds))
+	start := 0
+	for i, end := range s.fieldEnds {
+		fields[i] = record[start:end]
+		start = end
+	}
+	return fields
+}
+
+// lengthNL reports the number of bytes of the trailing \n of `line`
+func lengthNL(line []byte) int {
+	if len(line) > 0 && line[len(line)-1] == '\n' {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,146 @@
+package customerimporter
+
+import (
+	"bytes"
+	"encoding/csv"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_recordScanner_Scan(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		dialect Dialect
+	}{
+		{name: "plain", input: "a,b,c\n1,2,3\n4,5,6\n"},
+		{name: "no trailing newline", input: "a,b\n1,2"},
+		{name: "crlf", input: "a,b\r\n1,2\r\n3,4\r"},
+		{name: "empty lines", input: "a,b\n\n1,2\n\n\n3,4\n"},
+		{name: "empty fields", input: "a,b,c\n,,\n1,,\n"},
+		{name: "quoted", input: "a,b\n\"1,5\",\"say \"\"hi\"\"\"\n\"\",x\n"},
+		{name: "quoted newlines", input: "a,b\n\"line 1\nline 2\r\nline 3\",x\n1,2\n"},
+		{name: "bare quote", input: "a,b\n1,2\"\n3,4\n"},
+		{name: "invalid quote", input: "a,b\n\"1\"x,2\n3,4\n"},
+		{name: "unterminated quote", input: "a,b\n1,2\n\"3,4\n"},
+		{name: "field count", input: "a,b\n1,2,3\n4\n5,6\n"},
+		{name: "semicolons", input: "a;b\n\"1;5\";2,3\n", dialect: Dialect{Delimiter: ';'}},
+		{name: "tabs", input: "a\tb\n1\t\"2\t3\"\n", dialect: Dialect{Delimiter: '\t'}},
+		{name: "comments", input: "# exported\na,b\n#1,2\n3,4\n\"#5\",6\n#", dialect: Dialect{Comment: '#'}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.dialect.validate(); err != nil {
+				t.Fatal(err)
+			}
+			reader := csv.NewReader(strings.NewReader(tt.input))
+			reader.Comma, reader.Comment = rune(tt.dialect.delimiter()), rune(tt.dialect.Comment)
+			want, wantErrs := readAll(reader.Read)
+			scanners := map[string]*recordScanner{
+				"reader": newRecordScanner(strings.NewReader(tt.input), tt.dialect),
+				"bytes":  newBytesRecordScanner([]byte(tt.input), tt.dialect),
+			}
+			for source, scanner := range scanners {
+				got, gotErrs := readAll(func() ([]string, error) {
+					err := scanner.Scan()
+					if err != nil {
+						return nil, err
+					}
+					return scanner.Strings(), nil
+				})
+				if !reflect.DeepEqual(got, want) {
+					t.Errorf("Scan() from %s records = %q, want %q", source, got, want)
+				}
+				if !reflect.DeepEqual(gotErrs, wantErrs) {
+					t.Errorf("Scan() from %s errors = %v, want %v", source, gotErrs, wantErrs)
+				}
+			}
+		})
+	}
+}
+
+// readAll reads every record until io.EOF, keeping apart the ones read without errors and the errors of the rest
+func readAll(read func() ([]string, error)) (records [][]string, errs []string) {
+	for {
+		record, err := read()
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		records = append(records, record)
+	}
+}
+
+func Test_recordScanner_Field(t *testing.T) {
+	scanner := newRecordScanner(strings.NewReader("a,\"b\"\"c\",,d\n"), Dialect{})
+	if err := scanner.Scan(); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"a", "b\"c", "", "d"}
+	if scanner.Len() != len(want) {
+		t.Fatalf("Len() = %d, want %d", scanner.Len(), len(want))
+	}
+	for i, field := range want {
+		if got := string(scanner.Field(i)); got != field {
+			t.Errorf("Field(%d) = %q, want %q", i, got, field)
+		}
+	}
+}
+
+func Test_appendDomain(t *testing.T) {
+	tests := []struct {
+		address string
+		want    string
+	}{
+		{address: "mildred@GitHub.io", want: "github.io"},
+		{address: "\"a@b\"@cyberchimps.com", want: "cyberchimps.com"},
+		{address: "not-an-email", want: "not-an-email"},
+		{address: "josé@BÜCHER.de", want: "bücher.de"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.address, func(t *testing.T) {
+			buffer := []byte("leftovers")
+			got := appendDomain(buffer[:0], []byte(tt.address))
+			if string(got) != tt.want || string(got) != domainOf(tt.address) {
+				t.Errorf("appendDomain() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_recordScanner_allocations(t *testing.T) {
+	csvFile, err := ioutil.ReadFile("../../test/data/importer/customers.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	counter := newDomainCounter(defaultMemoryBudget)
+	var domain []byte
+	countAll := func() {
+		scanner := newRecordScanner(bytes.NewReader(csvFile), Dialect{})
+		for {
+			err := scanner.Scan()
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				continue
+			}
+			domain = appendDomain(domain[:0], scanner.Field(2))
+			if err := counter.addBytes(domain); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	countAll()
+
+	// Once every domain has been seen, only the scanner and its buffers allocate, no matter the number of rows
+	if allocations := testing.AllocsPerRun(5, countAll); allocations > 20 {
+		t.Errorf("counting every row allocated %v times, want at most 20", allocations)
+	}
+}
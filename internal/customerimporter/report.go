@@ -0,0 +1,194 @@
+package customerimporter
+
+import (
+	_ "embed"
+	"fmt"
+	"html/template"
+	"io"
+	"math"
+	"sort"
+)
+
+const (
+	// reportBars is the number of domains in the bar chart of HTML reports
+	reportBars = 20
+	// reportTlds is the number of top level domains in the pie chart of HTML reports, the rest are added up as other
+	reportTlds = 8
+)
+
+// reportColors are the colors of the pie slices, the last one is for the other top level domains
+var reportColors = [reportTlds + 1]string{
+	"#4e79a7", "#f28e2b", "#e15759", "#76b7b2", "#59a14f", "#edc948", "#b07aa1", "#ff9da7", "#bab0ac",
+}
+
+//go:embed report.html
+var reportHtml string
+
+var reportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"percent": func(share float64) string { return fmt.Sprintf("%.2f%%", share*100) },
+}).Parse(reportHtml))
+
+// htmlReport is what the template of HTML reports is rendered with, charts are laid out beforehand so the template
+// only has to place them
+type htmlReport struct {
+	Customers   int
+	DomainCount int
+	Bars        []reportBar
+	BarsHeight  int
+	Slices      []reportSlice
+	Categories  []reportCategory
+	Stats       *reportStats
+	Columns     []reportColumn
+	Rows        [][]string
+}
+
+type reportBar struct {
+	Domain    string
+	Customers int
+	Y         int
+	Width     float64
+}
+
+type reportSlice struct {
+	Tld       string
+	Customers int
+	Share     float64
+	Path      string
+	Color     string
+}
+
+type reportCategory struct {
+	Category  string
+	Domains   int
+	Customers int
+	Share     float64
+	// Width is the share in pixels of the bar next to it
+	Width float64
+}
+
+type reportStats struct {
+	RowsRead      int64
+	RowsRejected  int64
+	ValidRows     int64
+	RejectedShare float64
+	Reasons       []rejectReason
+}
+
+type reportColumn struct {
+	Name    string
+	Numeric bool
+}
+
+// writeDomainsHtml writes a single HTML page with no external assets: a bar chart of the domains with the most
+// customers, a pie chart of their top level domains, the customers of each category, the rows rejected when
+// FormatOptions.Stats is set and a table of every domain which can be sorted by any column
+func writeDomainsHtml(w io.Writer, domains []EmailDomain, formatted []formattedDomain, options FormatOptions) error {
+	report := htmlReport{DomainCount: len(domains)}
+	for _, domain := range domains {
+		report.Customers += domain.CustomerCount
+	}
+	report.Bars = reportBarsOf(domains)
+	report.BarsHeight = len(report.Bars)*24 + 8
+	report.Slices = reportSlicesOf(domains, report.Customers)
+	for _, total := range CategoryTotals(domains) {
+		category := reportCategory{Category: total.Category, Domains: total.DomainCount, Customers: total.CustomerCount}
+		if category.Category == "" {
+			category.Category = "unknown"
+		}
+		if report.Customers > 0 {
+			category.Share = float64(total.CustomerCount) / float64(report.Customers)
+		}
+		category.Width = category.Share * 200
+		report.Categories = append(report.Categories, category)
+	}
+	if stats := options.Stats; stats != nil {
+		report.Stats = &reportStats{
+			RowsRead:     stats.RowsRead,
+			RowsRejected: stats.RowsRejected,
+			ValidRows:    stats.RowsRead - stats.RowsRejected,
+		}
+		if stats.RowsRead > 0 {
+			report.Stats.RejectedShare = float64(stats.RowsRejected) / float64(stats.RowsRead)
+		}
+		report.Stats.Reasons = stats.Rejected.byReason()
+	}
+	firstSource := len(domainColumns(options)) - len(options.Sources)
+	for i, column := range domainColumns(options) {
+		numeric := i == 1 || i >= firstSource || column == "percentage" || column == "cumulative_percentage"
+		report.Columns = append(report.Columns, reportColumn{Name: column, Numeric: numeric})
+	}
+	for _, domain := range formatted {
+		report.Rows = append(report.Rows, domain.record(options))
+	}
+	return reportTemplate.Execute(w, report)
+}
+
+// reportBarsOf lays out the bars of the domains with the most customers, the longest bar being 400 pixels wide
+func reportBarsOf(domains []EmailDomain) []reportBar {
+	top := SortDomains(append([]EmailDomain(nil), domains...), OrderByCount)
+	if len(top) > reportBars {
+		top = top[:reportBars]
+	}
+	bars := make([]reportBar, len(top))
+	for i, domain := range top {
+		bars[i] = reportBar{Domain: domain.Domain, Customers: domain.CustomerCount, Y: i*24 + 4}
+		if top[0].CustomerCount > 0 {
+			bars[i].Width = math.Round(float64(domain.CustomerCount)/float64(top[0].CustomerCount)*4000) / 10
+		}
+	}
+	return bars
+}
+
+// reportSlicesOf lays out the slices of the pie chart of top level domains, on a circle of radius 90 centered at
+// (100, 100) starting at the top and going clockwise
+func reportSlicesOf(domains []EmailDomain, customers int) []reportSlice {
+	customersOf := make(map[string]int)
+	for _, domain := range domains {
+		customersOf[tldOf(domain.Domain)] += domain.CustomerCount
+	}
+	var slices []reportSlice
+	for tld, count := range customersOf {
+		slices = append(slices, reportSlice{Tld: "." + tld, Customers: count})
+	}
+	sort.Slice(slices, func(i, j int) bool {
+		if slices[i].Customers != slices[j].Customers {
+			return slices[i].Customers > slices[j].Customers
+		}
+		return slices[i].Tld < slices[j].Tld
+	})
+	if len(slices) > reportTlds {
+		other := reportSlice{Tld: "other"}
+		for _, slice := range slices[reportTlds:] {
+			other.Customers += slice.Customers
+		}
+		slices = append(slices[:reportTlds], other)
+	}
+	if customers == 0 {
+		return nil
+	}
+
+	point := func(share float64) (float64, float64) {
+		angle := 2 * math.Pi * share
+		return 100 + 90*math.Sin(angle), 100 - 90*math.Cos(angle)
+	}
+	start := 0.0
+	for i := range slices {
+		slices[i].Share = float64(slices[i].Customers) / float64(customers)
+		slices[i].Color = reportColors[i]
+		end := start + slices[i].Share
+		if slices[i].Share >= 1 {
+			// An arc can't go all the way round, so the whole circle takes two
+			slices[i].Path = "M100,10 A90,90 0 1,1 100,190 A90,90 0 1,1 100,10 Z"
+		} else {
+			largeArc := 0
+			if slices[i].Share > 0.5 {
+				largeArc = 1
+			}
+			x1, y1 := point(start)
+			x2, y2 := point(end)
+			slices[i].Path = fmt.Sprintf("M100,100 L%.2f,%.2f A90,90 0 %d,1 %.2f,%.2f Z", x1, y1, largeArc, x2, y2)
+		}
+		start = end
+	}
+	return slices
+}
@@ -0,0 +1,72 @@
+package customerimporter
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWriteDomains_html(t *testing.T) {
+	domains := []EmailDomain{
+		{Domain: "github.io", CustomerCount: 4, Category: CategoryCorporate},
+		{Domain: "gmail.com", CustomerCount: 2, Category: CategoryFreemail},
+		{Domain: "<script>.com", CustomerCount: 1},
+	}
+	stats := &RunStats{RowsRead: 9, RowsRejected: 2, Rejected: RejectedRows{Malformed: 1, InvalidEmail: 1}}
+	tests := []struct {
+		name        string
+		domains     []EmailDomain
+		options     FormatOptions
+		wantParts   []string
+		unwantParts []string
+	}{
+		{
+			name:    "report",
+			domains: domains,
+			options: FormatOptions{Percentage: true, Stats: stats},
+			wantParts: []string{
+				"7 customers in 3 domains.",
+				`<rect x="180" y="4" width="400" height="18" fill="#4e79a7"><title>github.io: 4</title></rect>`,
+				`<rect x="180" y="28" width="200" height="18" fill="#4e79a7"><title>gmail.com: 2</title></rect>`,
+				`<title>.com: 3 (42.86%)</title>`,
+				"<td>freemail</td><td class=\"numeric\">1</td><td class=\"numeric\">2</td><td class=\"numeric\">28.57%</td>",
+				"<td>unknown</td>",
+				"<tr><td>rows rejected</td><td class=\"numeric\">2 (22.22%)</td></tr>",
+				"<tr><td>&nbsp;&nbsp;invalid_email</td><td class=\"numeric\">1</td></tr>",
+				`<th class="numeric" data-numeric>percentage</th>`,
+				`<tr><td>github.io</td><td class="numeric">4</td><td class="numeric">57.14</td></tr>`,
+				"&lt;script&gt;.com",
+			},
+			unwantParts: []string{"<script>.com", "http://", "https://"},
+		},
+		{
+			name:      "single top level domain",
+			domains:   domains[:1],
+			wantParts: []string{`<path d="M100,10 A90,90 0 1,1 100,190 A90,90 0 1,1 100,10 Z"`},
+			// Stats are only reported when given
+			unwantParts: []string{"Rejected rows"},
+		},
+		{
+			name:      "no domains",
+			wantParts: []string{"0 customers in 0 domains.", "<p>No domains.</p>", "<p>No customers.</p>"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got bytes.Buffer
+			if err := WriteDomains(&got, tt.domains, FormatHtml, tt.options); err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range tt.wantParts {
+				if !strings.Contains(got.String(), want) {
+					t.Errorf("WriteDomains() = %q, want it to contain %q", got.String(), want)
+				}
+			}
+			for _, unwanted := range tt.unwantParts {
+				if strings.Contains(got.String(), unwanted) {
+					t.Errorf("WriteDomains() = %q, want it not to contain %q", got.String(), unwanted)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package customerimporter
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// StdinPath is the path standing for standard input in NewCustomerImporters
+const StdinPath = "-"
+
+// NewCustomerImporters creates an importer for each of `paths` sharing the same `emailKey` and `options`, so they can
+// be counted together with CombineCustomerCounts. The StdinPath path reads from `stdin`, any other from a csv file,
+// see NewCsvCustomerImporter
+func NewCustomerImporters(paths []string, stdin io.Reader, emailKey string, options ...ImporterOption) ([]*csvCustomerImporter, error) {
+	importers := make([]*csvCustomerImporter, len(paths))
+	for i, path := range paths {
+		var err error
+		if path == StdinPath {
+			importers[i], err = NewReaderCustomerImporter(path, stdin, emailKey, options...)
+		} else {
+			importers[i], err = NewCsvCustomerImporter(path, emailKey, options...)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return importers, nil
+}
+
+// ExpandCsvPaths turns `patterns` into the paths of the csv files they stand for. Directories stand for the .csv and
+// .csv.gz files anywhere in them, and glob patterns, see filepath.Match, for the csv files and directories matching
+// them. Any other pattern, like StdinPath, is kept as is.
+// Globs and directories without any csv file fail with an *os.PathError
+func ExpandCsvPaths(patterns []string) (paths []string, err error) {
+	for _, pattern := range patterns {
+		matches := []string{pattern}
+		isGlob := strings.ContainsAny(pattern, "*?[")
+		if isGlob {
+			if matches, err = filepath.Glob(pattern); err != nil {
+				return nil, fmt.Errorf("invalid pattern %s: %w", pattern, err)
+			}
+		}
+		found := len(paths)
+		for _, match := range matches {
+			info, err := os.Stat(match)
+			switch {
+			case err == nil && info.IsDir():
+				err = filepath.Walk(match, func(path string, info os.FileInfo, err error) error {
+					if err == nil && !info.IsDir() && isCsvPath(path) {
+						paths = append(paths, path)
+					}
+					return err
+				})
+				if err != nil {
+					return nil, err
+				}
+			case !isGlob || isCsvPath(match):
+				paths = append(paths, match)
+			}
+		}
+		if len(paths) == found {
+			return nil, &os.PathError{Op: "expand", Path: pattern, Err: os.ErrNotExist}
+		}
+	}
+	return paths, nil
+}
+
+// isCsvPath tells whether `path` names a csv file, gzip compressed or not
+func isCsvPath(path string) bool {
+	return strings.HasSuffix(path, ".csv") || strings.HasSuffix(path, ".csv.gz")
+}
+
+// Source returns the path of the csv file of the importer, or the name of its reader
+func (imp *csvCustomerImporter) Source() string {
+	return imp.csvPath
+}
+
+// openSource opens the input of the importer, decompressing gzip files. The reader of importers created with
+// NewReaderCustomerImporter is only handed once, since it can't be read again
+func (imp *csvCustomerImporter) openSource() (io.ReadCloser, error) {
+	if imp.reader != nil {
+		reader := imp.reader
+		imp.reader, imp.readerConsumed = nil, true
+		return ioutil.NopCloser(reader), nil
+	}
+	if imp.readerConsumed {
+		return nil, SourceConsumedError{name: imp.csvPath}
+	}
+	file, err := os.Open(imp.csvPath)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't open file %s: %w", imp.csvPath, err)
+	}
+	if !strings.HasSuffix(imp.csvPath, ".gz") {
+		return file, nil
+	}
+	decompressor, err := gzip.NewReader(file)
+	if err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("couldn't decompress file %s: %w", imp.csvPath, err)
+	}
+	return gzipFile{Reader: decompressor, file: file}, nil
+}
+
+// gzipFile decompresses a gzip file as it's read, closing the file along with the decompressor
+type gzipFile struct {
+	*gzip.Reader
+	file *os.File
+}
+
+func (f gzipFile) Close() error {
+	err := f.Reader.Close()
+	if closeErr := f.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,80 @@
+package customerimporter
+
+import (
+	"errors"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNewReaderCustomerImporter(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	imp, err := NewReaderCustomerImporter("-", strings.NewReader("email\njohn@360.cn\n"), "email")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := imp.CustomerCountByDomain()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Domain != "360.cn" || got[0].CustomerCount != 1 {
+		t.Errorf("CustomerCountByDomain() = %v, want 360.cn(1)", got)
+	}
+	var consumed SourceConsumedError
+	if _, err := imp.CustomerCountByDomain(); !errors.As(err, &consumed) {
+		t.Errorf("CustomerCountByDomain() a second time error = %v, want a SourceConsumedError", err)
+	}
+}
+
+func TestExpandCsvPaths(t *testing.T) {
+	dir := t.TempDir()
+	for _, path := range []string{"a.csv", "b.csv.gz", "notes.txt", "nested/c.csv", "empty/notes.txt"} {
+		path = filepath.Join(dir, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, nil, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		name     string
+		patterns []string
+		want     []string
+		wantErr  bool
+	}{
+		{name: "files", patterns: []string{StdinPath, "missing.csv"}, want: []string{StdinPath, "missing.csv"}},
+		{name: "directory", patterns: []string{dir}, want: []string{"a.csv", "b.csv.gz", "nested/c.csv"}},
+		{name: "glob", patterns: []string{filepath.Join(dir, "*")}, want: []string{"a.csv", "b.csv.gz", "nested/c.csv"}},
+		{name: "glob of files", patterns: []string{filepath.Join(dir, "*.csv*")}, want: []string{"a.csv", "b.csv.gz"}},
+		{name: "glob without matches", patterns: []string{filepath.Join(dir, "*.tsv")}, wantErr: true},
+		{name: "directory without csv files", patterns: []string{filepath.Join(dir, "empty")}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ExpandCsvPaths(tt.patterns)
+			if tt.wantErr {
+				var pathError *os.PathError
+				if !errors.As(err, &pathError) {
+					t.Errorf("ExpandCsvPaths() error = %v, want an *os.PathError", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, path := range got {
+				if relative, err := filepath.Rel(dir, path); err == nil && !strings.HasPrefix(relative, "..") {
+					got[i] = filepath.ToSlash(relative)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ExpandCsvPaths() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package customerimporter
+
+import (
+	"container/heap"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// defaultMemoryBudget is the amount of memory analyses able to spill to disk use when no budget is configured
+const defaultMemoryBudget = 64 << 20
+
+// spillRuns is a set of temporary files, each holding csv records sorted by their first field, which can be merged
+// back into a single sorted stream. It allows sorting more records than fit in memory.
+type spillRuns struct {
+	dir   string
+	paths []string
+}
+
+func newSpillRuns() (*spillRuns, error) {
+	dir, err := ioutil.TempDir("", "customerimporter-spill-")
+	if err != nil {
+		return nil, fmt.Errorf("couldn't create spill directory: %w", err)
+	}
+	return &spillRuns{dir: dir}, nil
+}
+
+// write stores a new run, `fill` must call `write` with the records of the run already sorted by their first field
+func (s *spillRuns) write(fill func(write func(record []string) error) error) (err error) {
+	path := filepath.Join(s.dir, fmt.Sprintf("run-%d.csv", len(s.paths)))
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("couldn't create spill run: %w", err)
+	}
+	defer func() {
+		if closeErr := file.Close(); closeErr != nil && err == nil {
+			err = fmt.Errorf("couldn't close spill run: %w", closeErr)
+		}
+	}()
+
+	csvWriter := csv.NewWriter(file)
+	if err = fill(csvWriter.Write); err != nil {
+		return fmt.Errorf("couldn't write spill run: %w", err)
+	}
+	csvWriter.Flush()
+	if err = csvWriter.Error(); err != nil {
+		return fmt.Errorf("couldn't write spill run: %w", err)
+	}
+	s.paths = append(s.paths, path)
+	return nil
+}
+
+// len returns the number of runs written so far
+func (s *spillRuns) len() int {
+	return len(s.paths)
+}
+
+// merge calls `fn` with the records of every run in ascending order of their first field, records with the same
+// first field are visited in the order their runs were written
+func (s *spillRuns) merge(fn func(record []string) error) error {
+	cursors := make(runCursors, 0, len(s.paths))
+	defer func() {
+		for _, cursor := range cursors {
+			_ = cursor.file.Close()
+		}
+	}()
+
+	for i, path := range s.paths {
+		file, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("couldn't open spill run: %w", err)
+		}
+		csvReader := csv.NewReader(file)
+		csvReader.FieldsPerRecord = -1
+		cursor := &runCursor{file: file, reader: csvReader, run: i}
+		cursors = append(cursors, cursor)
+	}
+
+	var active runCursors
+	for _, cursor := range cursors {
+		hasRecord, err := cursor.advance()
+		if err != nil {
+			return err
+		}
+		if hasRecord {
+			active = append(active, cursor)
+		}
+	}
+	heap.Init(&active)
+
+	for active.Len() > 0 {
+		cursor := active[0]
+		if err := fn(cursor.record); err != nil {
+			return err
+		}
+		hasRecord, err := cursor.advance()
+		if err != nil {
+			return err
+		}
+		if hasRecord {
+			heap.Fix(&active, 0)
+		} else {
+			heap.Pop(&active)
+		}
+	}
+	return nil
+}
+
+// cleanup removes every run from disk
+func (s *spillRuns) cleanup() {
+	_ = os.RemoveAll(s.dir)
+}
+
+type runCursor struct {
+	file   *os.File
+	reader *csv.Reader
+	run    int
+	record []string
+}
+
+func (c *runCursor) advance() (hasRecord bool, err error) {
+	c.record, err = c.reader.Read()
+	if err == io.EOF {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("couldn't read spill run: %w", err)
+	}
+	return true, nil
+}
+
+// runCursors is a min-heap of cursors ordered by their current record
+type runCursors []*runCursor
+
+func (c runCursors) Len() int {
+	return len(c)
+}
+
+func (c runCursors) Less(i, j int) bool {
+	if c[i].record[0] != c[j].record[0] {
+		return c[i].record[0] < c[j].record[0]
+	}
+	return c[i].run < c[j].run
+}
+
+func (c runCursors) Swap(i, j int) {
+	c[i], c[j] = c[j], c[i]
+}
+
+func (c *runCursors) Push(x interface{}) {
+	*c = append(*c, x.(*runCursor))
+}
+
+func (c *runCursors) Pop() interface{} {
+	old := *c
+	cursor := old[len(old)-1]
+	*c = old[:len(old)-1]
+	return cursor
+}
@@ -0,0 +1,152 @@
+package customerimporter
+
+import (
+	"github.com/IllicLanthresh/TeamworkGoTests/pkg/hyperLogLog"
+	"runtime"
+	"time"
+)
+
+const (
+	// addressesPrecision is the precision of the sketch estimating RunStats.UniqueAddresses, taking 16KiB per worker
+	addressesPrecision = 14
+	// memorySampleInterval is how often the heap is sampled for RunStats.PeakMemory
+	memorySampleInterval = 50 * time.Millisecond
+)
+
+// RunStats describes how an import went
+type RunStats struct {
+	// ReadPath tells whether the csv file was memory mapped, ReadPathMmap, or read through regular reads,
+	// ReadPathBuffered
+	ReadPath string `json:"read_path"`
+	// RowsRead counts the rows after the headers one, rejected ones included
+	RowsRead int64 `json:"rows_read"`
+	// RowsRejected counts the rows which couldn't be parsed or had an empty or invalid email address
+	RowsRejected int64 `json:"rows_rejected"`
+	// Rejected splits RowsRejected by reason
+	Rejected RejectedRows `json:"rejected"`
+	// UniqueDomains counts the domains found
+	UniqueDomains int `json:"unique_domains"`
+	// UniqueAddresses estimates how many different addresses were found, regardless of case, with a HyperLogLog
+	// sketch so it takes the same memory however big the input is. The estimate is usually within 1% of the actual
+	// number, and exact for a handful of addresses
+	UniqueAddresses int64 `json:"unique_addresses"`
+	// BytesRead counts the bytes of csv read, after decompressing them
+	BytesRead int64 `json:"bytes_read"`
+	// Duration is how long the import took, sorting included
+	Duration time.Duration `json:"duration_ns"`
+	// RowsPerSecond and BytesPerSecond are the throughput of the import over its whole duration
+	RowsPerSecond  float64 `json:"rows_per_second"`
+	BytesPerSecond float64 `json:"bytes_per_second"`
+	// PeakMemory is the biggest heap in use, in bytes, seen while the import ran. The heap of the whole process is
+	// sampled every memorySampleInterval, so it's an approximation including whatever else the process does
+	PeakMemory uint64 `json:"peak_memory_bytes"`
+	// addresses is the sketch UniqueAddresses is estimated from
+	addresses *hyperLogLog.Sketch
+}
+
+// RejectedRows splits the rows rejected by an import by reason
+type RejectedRows struct {
+	// Malformed rows couldn't be parsed as csv
+	Malformed int64 `json:"malformed"`
+	// EmptyEmail rows had nothing but spaces in their email column
+	EmptyEmail int64 `json:"empty_email"`
+	// InvalidEmail rows had something other than an email address in their email column
+	InvalidEmail int64 `json:"invalid_email"`
+}
+
+// rejectReason is the number of rows rejected for a reason
+type rejectReason struct {
+	Reason string
+	Rows   int64
+}
+
+// byReason lists the rows rejected for every reason, named like in metrics and JSON
+func (r RejectedRows) byReason() []rejectReason {
+	return []rejectReason{
+		{Reason: "malformed", Rows: r.Malformed},
+		{Reason: "empty_email", Rows: r.EmptyEmail},
+		{Reason: "invalid_email", Rows: r.InvalidEmail},
+	}
+}
+
+// addAddress adds an address to the ones UniqueAddresses is estimated from
+func (s *RunStats) addAddress(address []byte) {
+	if s.addresses == nil {
+		s.addresses = hyperLogLog.NewSketch(addressesPrecision)
+	}
+	s.addresses.Add(address)
+}
+
+// add adds the rows, addresses and bytes of `other` to the stats, keeping the biggest peak memory
+func (s *RunStats) add(other RunStats) {
+	s.RowsRead += other.RowsRead
+	s.RowsRejected += other.RowsRejected
+	s.Rejected.Malformed += other.Rejected.Malformed
+	s.Rejected.EmptyEmail += other.Rejected.EmptyEmail
+	s.Rejected.InvalidEmail += other.Rejected.InvalidEmail
+	if other.addresses != nil {
+		if s.addresses == nil {
+			s.addresses = hyperLogLog.NewSketch(addressesPrecision)
+		}
+		s.addresses.Merge(other.addresses)
+	}
+	s.BytesRead += other.BytesRead
+	if other.PeakMemory > s.PeakMemory {
+		s.PeakMemory = other.PeakMemory
+	}
+}
+
+// finish works out the figures which depend on the whole import, once it took `duration`
+func (s *RunStats) finish(duration time.Duration) {
+	s.Duration = duration
+	if s.addresses != nil {
+		s.UniqueAddresses = int64(s.addresses.Estimate())
+	}
+	if seconds := duration.Seconds(); seconds > 0 {
+		s.RowsPerSecond = float64(s.RowsRead) / seconds
+		s.BytesPerSecond = float64(s.BytesRead) / seconds
+	}
+}
+
+// memorySampler keeps the biggest heap in use seen from its creation until it's stopped
+type memorySampler struct {
+	peak uint64
+	stop chan struct{}
+	done chan struct{}
+}
+
+// sampleMemory starts sampling the heap every memorySampleInterval
+func sampleMemory() *memorySampler {
+	sampler := &memorySampler{stop: make(chan struct{}), done: make(chan struct{})}
+	sampler.sample()
+	go func() {
+		defer close(sampler.done)
+		ticker := time.NewTicker(memorySampleInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				sampler.sample()
+			case <-sampler.stop:
+				return
+			}
+		}
+	}()
+	return sampler
+}
+
+func (s *memorySampler) sample() {
+	var memStats runtime.MemStats
+	runtime.ReadMemStats(&memStats)
+	if memStats.HeapInuse > s.peak {
+		s.peak = memStats.HeapInuse
+	}
+}
+
+// stopSampling stops sampling after a last sample, returning the peak seen
+func (s *memorySampler) stopSampling() uint64 {
+	close(s.stop)
+	<-s.done
+	s.sample()
+	return s.peak
+}
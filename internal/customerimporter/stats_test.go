@@ -0,0 +1,40 @@
+package customerimporter
+
+import (
+	"io/ioutil"
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestRunStats(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	content := "email,name\njohn@360.cn,John\njohn@360.cn,Johnny\njane@example.com,Jane\n,Nobody\n  ,Blank\n" +
+		"not an address,Bob\nma\"ry@example.com,Mary\n"
+	for _, workers := range []int{1, 2} {
+		importer, err := NewReaderCustomerImporter("customers", strings.NewReader(content), "email", WithWorkers(workers))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, stats, err := importer.CustomerCountByDomainWithStats()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stats.Duration <= 0 || stats.PeakMemory == 0 || stats.RowsPerSecond <= 0 || stats.BytesPerSecond <= 0 {
+			t.Errorf("CustomerCountByDomainWithStats() with %d workers stats = %+v, want a duration, peak memory and throughput", workers, stats)
+		}
+		stats.Duration, stats.PeakMemory, stats.RowsPerSecond, stats.BytesPerSecond, stats.addresses = 0, 0, 0, 0, nil
+		want := RunStats{
+			ReadPath:        ReadPathBuffered,
+			RowsRead:        7,
+			RowsRejected:    4,
+			Rejected:        RejectedRows{Malformed: 1, EmptyEmail: 2, InvalidEmail: 1},
+			UniqueDomains:   2,
+			UniqueAddresses: 2,
+			BytesRead:       int64(len(content)),
+		}
+		if stats != want {
+			t.Errorf("CustomerCountByDomainWithStats() with %d workers stats = %+v, want %+v", workers, stats, want)
+		}
+	}
+}
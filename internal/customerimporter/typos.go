@@ -0,0 +1,119 @@
+package customerimporter
+
+import (
+	"github.com/IllicLanthresh/TeamworkGoTests/pkg/keyboardDistance"
+	"math"
+)
+
+// TypoOptions tunes how SuggestTypoCorrections tells typos apart from legitimate domains
+type TypoOptions struct {
+	// MaxDistance is the highest keyboard distance between a typo and its correction
+	MaxDistance float64
+	// MinCorrectionCount is the lowest customer count of a domain to be suggested as a correction, any domain below
+	// it is considered a possible typo
+	MinCorrectionCount int
+	// MinCountRatio is how many times more customers a correction must have than the typo
+	MinCountRatio float64
+}
+
+// DefaultTypoOptions returns the options used by the CLI, tuned for typos of the big free-mail providers
+func DefaultTypoOptions() TypoOptions {
+	return TypoOptions{
+		MaxDistance:        1.5,
+		MinCorrectionCount: 5,
+		MinCountRatio:      5,
+	}
+}
+
+// typoSuggestion proposes `Correction` as the domain customers meant when they wrote `Domain`
+type typoSuggestion struct {
+	Domain          string
+	CustomerCount   int
+	Correction      string
+	CorrectionCount int
+	Distance        float64
+	// Confidence goes from 0 to 1, growing the closer both domains are and the more popular the correction is
+	Confidence float64
+}
+
+// SuggestTypoCorrections pairs the low frequency domains in `domains` with the most likely high frequency domain they
+// are a typo of, using a keyboard aware edit distance. Suggestions follow the order of `domains`
+func SuggestTypoCorrections(domains []EmailDomain, options TypoOptions) (suggestions []typoSuggestion) {
+	var corrections []EmailDomain
+	for _, domain := range domains {
+		if domain.CustomerCount >= options.MinCorrectionCount {
+			corrections = append(corrections, domain)
+		}
+	}
+
+	for _, typo := range domains {
+		if typo.CustomerCount >= options.MinCorrectionCount {
+			continue
+		}
+		var best *typoSuggestion
+		for _, correction := range corrections {
+			if float64(correction.CustomerCount) < options.MinCountRatio*float64(typo.CustomerCount) {
+				continue
+			}
+			// Every inserted or deleted character costs 1, so there's no need to compute the distance
+			if math.Abs(float64(len(correction.Domain)-len(typo.Domain))) > options.MaxDistance {
+				continue
+			}
+			distance := keyboardDistance.Distance(typo.Domain, correction.Domain)
+			if distance > options.MaxDistance {
+				continue
+			}
+			confidence := typoConfidence(typo, correction, distance)
+			if best == nil || confidence > best.Confidence {
+				best = &typoSuggestion{
+					Domain:          typo.Domain,
+					CustomerCount:   typo.CustomerCount,
+					Correction:      correction.Domain,
+					CorrectionCount: correction.CustomerCount,
+					Distance:        distance,
+					Confidence:      confidence,
+				}
+			}
+		}
+		if best != nil {
+			suggestions = append(suggestions, *best)
+		}
+	}
+	return
+}
+
+// typoConfidence multiplies how similar both domains are by how much more popular the correction is
+func typoConfidence(typo EmailDomain, correction EmailDomain, distance float64) float64 {
+	longest := len(typo.Domain)
+	if len(correction.Domain) > longest {
+		longest = len(correction.Domain)
+	}
+	similarity := 1 - distance/float64(longest)
+	popularity := 1 - float64(typo.CustomerCount)/float64(correction.CustomerCount)
+	return math.Round(similarity*popularity*100) / 100
+}
+
+// ApplyTypoCorrections merges the customer count of every suggested typo with at least `minConfidence` into its
+// correction, removing the typo from the result. The order of `domains` is kept
+func ApplyTypoCorrections(domains []EmailDomain, suggestions []typoSuggestion, minConfidence float64) (corrected []EmailDomain) {
+	correctionOf := make(map[string]string)
+	for _, suggestion := range suggestions {
+		if suggestion.Confidence >= minConfidence {
+			correctionOf[suggestion.Domain] = suggestion.Correction
+		}
+	}
+	merged := make(map[string]int)
+	for _, domain := range domains {
+		if correction, isTypo := correctionOf[domain.Domain]; isTypo {
+			merged[correction] += domain.CustomerCount
+		}
+	}
+	for _, domain := range domains {
+		if _, isTypo := correctionOf[domain.Domain]; isTypo {
+			continue
+		}
+		domain.CustomerCount += merged[domain.Domain]
+		corrected = append(corrected, domain)
+	}
+	return
+}
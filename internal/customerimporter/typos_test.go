@@ -0,0 +1,54 @@
+package customerimporter
+
+import (
+	"reflect"
+	"testing"
+)
+
+var typoDomains = []EmailDomain{
+	{Domain: "acme.com", CustomerCount: 2, Category: CategoryCorporate},
+	{Domain: "acne.com", CustomerCount: 1, Category: CategoryCorporate},
+	{Domain: "gmail.com", CustomerCount: 100, Category: CategoryFreemail},
+	{Domain: "gmial.com", CustomerCount: 3, Category: CategoryCorporate},
+	{Domain: "hotmail.com", CustomerCount: 40, Category: CategoryFreemail},
+	{Domain: "hotmial.com", CustomerCount: 1, Category: CategoryCorporate},
+	{Domain: "yaho.com", CustomerCount: 2, Category: CategoryCorporate},
+	{Domain: "yahoo.com", CustomerCount: 8, Category: CategoryFreemail},
+}
+
+func TestSuggestTypoCorrections(t *testing.T) {
+	want := []typoSuggestion{
+		{Domain: "gmial.com", CustomerCount: 3, Correction: "gmail.com", CorrectionCount: 100, Distance: 0.5, Confidence: 0.92},
+		{Domain: "hotmial.com", CustomerCount: 1, Correction: "hotmail.com", CorrectionCount: 40, Distance: 0.5, Confidence: 0.93},
+	}
+	if got := SuggestTypoCorrections(typoDomains, DefaultTypoOptions()); !reflect.DeepEqual(got, want) {
+		t.Errorf("SuggestTypoCorrections() = %+v, want %+v", got, want)
+	}
+
+	// yahoo.com is not 5 times more popular than yaho.com, unless we lower the ratio
+	options := DefaultTypoOptions()
+	options.MinCountRatio = 4
+	got := SuggestTypoCorrections(typoDomains, options)
+	if len(got) != 3 || got[2].Domain != "yaho.com" || got[2].Correction != "yahoo.com" {
+		t.Errorf("SuggestTypoCorrections() = %+v, want a yaho.com correction", got)
+	}
+}
+
+func TestApplyTypoCorrections(t *testing.T) {
+	suggestions := []typoSuggestion{
+		{Domain: "gmial.com", CustomerCount: 3, Correction: "gmail.com", CorrectionCount: 100, Confidence: 0.92},
+		{Domain: "yaho.com", CustomerCount: 2, Correction: "yahoo.com", CorrectionCount: 8, Confidence: 0.6},
+	}
+	want := []EmailDomain{
+		{Domain: "acme.com", CustomerCount: 2, Category: CategoryCorporate},
+		{Domain: "acne.com", CustomerCount: 1, Category: CategoryCorporate},
+		{Domain: "gmail.com", CustomerCount: 103, Category: CategoryFreemail},
+		{Domain: "hotmail.com", CustomerCount: 40, Category: CategoryFreemail},
+		{Domain: "hotmial.com", CustomerCount: 1, Category: CategoryCorporate},
+		{Domain: "yaho.com", CustomerCount: 2, Category: CategoryCorporate},
+		{Domain: "yahoo.com", CustomerCount: 8, Category: CategoryFreemail},
+	}
+	if got := ApplyTypoCorrections(typoDomains, suggestions, 0.9); !reflect.DeepEqual(got, want) {
+		t.Errorf("ApplyTypoCorrections() = %+v, want %+v", got, want)
+	}
+}
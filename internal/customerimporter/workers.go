@@ -0,0 +1,129 @@
+package customerimporter
+
+import (
+	"fmt"
+	"io"
+	"sync"
+)
+
+// countDomainsConcurrently counts the customers of every domain with `imp.workers` goroutines: the input is cut into
+// chunks of whole records, which the workers scan, filter and validate, counting the addresses in a domainCounter of
+// their own. Worker counters are merged once the whole input has been read.
+// Chunks are cut at line breaks outside quoted fields, so malformed rows with stray quotes may be reported differently
+// than by the sequential path. Each worker gets an even share of the memory budget, merging may take up to twice the
+// budget
+func (imp *csvCustomerImporter) countDomainsConcurrently(input *csvInput, stats *RunStats, live *liveTracker) (*domainCounter, error) {
+	chunks := newChunkReader(input, imp.dialect)
+	first, err := chunks.next()
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read headers row on CSV file: %w", err)
+	}
+	headerScanner := newBytesRecordScanner(first.data, imp.dialect)
+	if err := headerScanner.Scan(); err != nil {
+		return nil, fmt.Errorf("couldn't read headers row on CSV file: %w", err)
+	}
+	headers := headerScanner.Strings()
+	emailIndex, _, filter, err := imp.bindColumns(headers, imp.emailKey)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't create address generator: %w", err)
+	}
+	first.data = first.data[headerScanner.Offset():]
+	first.line = headerScanner.line
+
+	workerBudget := imp.memoryBudget / int64(imp.workers)
+	if workerBudget < 1 {
+		workerBudget = 1
+	}
+	counters := make([]*domainCounter, imp.workers)
+	errs := make([]error, imp.workers)
+	workerStats := make([]RunStats, imp.workers)
+	work := make(chan csvChunk, imp.workers)
+	// failed is closed by the first worker failing, so the input isn't read any further
+	failed := make(chan struct{})
+	var failOnce sync.Once
+
+	var wg sync.WaitGroup
+	for i := range counters {
+		counters[i] = newDomainCounter(workerBudget)
+		wg.Add(1)
+		go func(counter *domainCounter, stats *RunStats, err *error) {
+			defer wg.Done()
+			for chunk := range work {
+				if *err != nil {
+					continue
+				}
+				scanner := newBytesRecordScanner(chunk.data, imp.dialect)
+				scanner.line = chunk.line
+				scanner.fieldsPerRecord = len(headers)
+				if *err = countRecords(scanner, emailIndex, filter, counter, stats, live); *err != nil {
+					failOnce.Do(func() { close(failed) })
+				}
+			}
+		}(counters[i], &workerStats[i], &errs[i])
+	}
+
+	readErr := sendChunks(first, chunks, work, failed)
+	close(work)
+	wg.Wait()
+	for _, workerStat := range workerStats {
+		stats.add(workerStat)
+	}
+
+	for _, err := range append(errs, readErr) {
+		if err != nil {
+			for _, counter := range counters {
+				counter.cleanup()
+			}
+			return nil, err
+		}
+	}
+	counter, err := mergeDomainCounters(counters, imp.memoryBudget)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't count domains: %w", err)
+	}
+	return counter, nil
+}
+
+// sendChunks hands `first` and every chunk following it to `work`, until the end of the input or until `stop` is closed
+func sendChunks(first csvChunk, chunks *chunkReader, work chan<- csvChunk, stop <-chan struct{}) error {
+	for chunk := first; ; {
+		select {
+		case work <- chunk:
+		case <-stop:
+			return nil
+		}
+		var err error
+		chunk, err = chunks.next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
+
+// mergeDomainCounters adds up the counts of `counters` into a single counter with the given memory budget, freeing
+// the merged counters
+func mergeDomainCounters(counters []*domainCounter, budget int64) (*domainCounter, error) {
+	merged := newDomainCounter(budget)
+	for i, counter := range counters {
+		var addErr error
+		err := counter.each(func(domain string, count int) {
+			if addErr == nil {
+				addErr = merged.add(domain, count)
+			}
+		})
+		if err == nil {
+			err = addErr
+		}
+		counters[i] = nil
+		if err != nil {
+			for _, counter := range counters[i+1:] {
+				counter.cleanup()
+			}
+			merged.cleanup()
+			return nil, err
+		}
+	}
+	return merged, nil
+}
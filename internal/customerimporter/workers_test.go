@@ -0,0 +1,102 @@
+package customerimporter
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func Test_csvCustomerImporter_countDomainsConcurrently(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	tests := []struct {
+		name    string
+		csvPath string
+		options []ImporterOption
+	}{
+		{
+			name:    "2 workers",
+			csvPath: "../../test/data/importer/customers.csv",
+			options: []ImporterOption{WithWorkers(2)},
+		},
+		{
+			name:    "more workers than batches",
+			csvPath: "../../test/data/importer/customers-small.csv",
+			options: []ImporterOption{WithWorkers(16)},
+		},
+		{
+			name:    "spilling workers",
+			csvPath: "../../test/data/importer/customers.csv",
+			options: []ImporterOption{WithWorkers(4), WithMemoryBudget(4096)},
+		},
+		{
+			name:    "filtered",
+			csvPath: "../../test/data/importer/customers.csv",
+			options: []ImporterOption{WithWorkers(3), WithFilter("gender == \"Female\"")},
+		},
+		{
+			name:    "ordered by count",
+			csvPath: "../../test/data/importer/customers.csv",
+			options: []ImporterOption{WithWorkers(3), WithOrdering(OrderByCount)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Everything but the number of workers is kept for the sequential result
+			sequentialImp, err := NewCsvCustomerImporter(tt.csvPath, "email", append(tt.options, WithWorkers(1))...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := sequentialImp.CustomerCountByDomain()
+			if err != nil {
+				t.Fatal(err)
+			}
+			imp, err := NewCsvCustomerImporter(tt.csvPath, "email", tt.options...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := imp.CustomerCountByDomain()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("CustomerCountByDomain() = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func Benchmark_csvCustomerImporter_CustomerCountByDomainWorkers(b *testing.B) {
+	log.SetOutput(ioutil.Discard)
+	datasets := []struct {
+		name    string
+		csvPath string
+	}{
+		{name: "3k", csvPath: "../../test/data/importer/customers.csv"},
+		{name: "1m", csvPath: "../../test/data/importer/emails-1m.csv"},
+	}
+	workerCounts := []int{1, 2, 4, runtime.NumCPU()}
+	for _, dataset := range datasets {
+		for _, workers := range workerCounts {
+			b.Run(fmt.Sprintf("%s %d workers", dataset.name, workers), func(b *testing.B) {
+				if _, err := os.Stat(dataset.csvPath); os.IsNotExist(err) {
+					b.Skipf("%s is missing, generate it with cmd/gencustomers", dataset.csvPath)
+				}
+				imp, err := NewCsvCustomerImporter(dataset.csvPath, "email", WithWorkers(workers))
+				if err != nil {
+					b.Fatal(err)
+				}
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					if _, err := imp.CustomerCountByDomain(); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
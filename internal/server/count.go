@@ -0,0 +1,301 @@
+package server
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/IllicLanthresh/TeamworkGoTests/internal/customerimporter"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// contentTypes maps the formats counts can be written in to their media type
+var contentTypes = map[customerimporter.Format]string{
+	customerimporter.FormatJson:   "application/json",
+	customerimporter.FormatCsv:    "text/csv; charset=utf-8",
+	customerimporter.FormatNdjson: "application/x-ndjson",
+	customerimporter.FormatHtml:   "text/html; charset=utf-8",
+}
+
+// countRequest is a count of the customers of each domain, as asked for by the query parameters of a request
+type countRequest struct {
+	emailColumn string
+	// ndjson is set for uploads of newline delimited JSON objects instead of csv
+	ndjson            bool
+	options           []customerimporter.ImporterOption
+	ordering          customerimporter.Ordering
+	top               int
+	onlyCategories    []string
+	excludeCategories []string
+	fixTypos          bool
+	typoConfidence    float64
+	kAnonymity        int
+	bucketSuppressed  bool
+	format            customerimporter.Format
+	formatOptions     customerimporter.FormatOptions
+	// live makes jobs send snapshots of the domains counted so far, see EventSnapshot
+	live bool
+	// sortDomains sorts the domains counted, the server swaps it for one recording metrics
+	sortDomains func(domains []customerimporter.EmailDomain, ordering customerimporter.Ordering) []customerimporter.EmailDomain
+}
+
+// requestError is returned for query parameters which can't be used, and answered with 400 Bad Request
+type requestError struct {
+	err error
+}
+
+func (e requestError) Error() string {
+	return e.err.Error()
+}
+
+func (e requestError) Unwrap() error {
+	return e.err
+}
+
+// parseCountRequest reads the options of a count from the query parameters of `r`, named like the flags of the count
+// command:
+//
+//	email-column        name of the column holding email addresses, email by default
+//	input               csv or ndjson, ndjson by default when the Content-Type mentions it and csv otherwise
+//	delimiter, comment  dialect of csv uploads, a single ASCII character or \t for tabs
+//	where               only count rows matching this filter expression
+//	order               domain, count, reversed or tld, count by default with top and domain otherwise
+//	top                 only answer with this many domains
+//	format              json, csv, ndjson or html, json by default
+//	percentage, cumulative, categories, only-categories, exclude-categories, fix-typos, typo-confidence,
+//	k-anonymity, bucket-suppressed
+//	live                jobs only, send snapshots of the domains with the most customers counted so far
+//
+// Boolean parameters are set by just giving them, like ?percentage, or with any value strconv.ParseBool accepts.
+// The importer `options` are applied before the ones coming from the parameters
+func parseCountRequest(r *http.Request, options []customerimporter.ImporterOption) (*countRequest, error) {
+	query, err := url.ParseQuery(r.URL.RawQuery)
+	if err != nil {
+		return nil, requestError{err: fmt.Errorf("invalid query: %w", err)}
+	}
+	params := queryParams{query: query}
+	request := &countRequest{
+		emailColumn:       params.string("email-column", "email"),
+		ndjson:            strings.Contains(r.Header.Get("Content-Type"), "ndjson"),
+		options:           append([]customerimporter.ImporterOption(nil), options...),
+		sortDomains:       customerimporter.SortDomains,
+		top:               params.int("top", 0),
+		onlyCategories:    params.list("only-categories"),
+		excludeCategories: params.list("exclude-categories"),
+		fixTypos:          params.bool("fix-typos"),
+		typoConfidence:    params.float("typo-confidence", 0.8),
+		kAnonymity:        params.int("k-anonymity", 0),
+		bucketSuppressed:  params.bool("bucket-suppressed"),
+		live:              params.bool("live"),
+		formatOptions: customerimporter.FormatOptions{
+			Percentage: params.bool("percentage"),
+			Cumulative: params.bool("cumulative"),
+			Category:   params.bool("categories"),
+		},
+	}
+	if params.err != nil {
+		return nil, params.err
+	}
+	switch input := query.Get("input"); input {
+	case "":
+	case "csv", "ndjson":
+		request.ndjson = input == "ndjson"
+	default:
+		return nil, requestError{err: fmt.Errorf("input %q isn't csv nor ndjson", input)}
+	}
+	if request.top < 0 {
+		return nil, requestError{err: fmt.Errorf("top must be a positive number, not %d", request.top)}
+	}
+
+	defaultOrder := customerimporter.OrderByDomain
+	if request.top > 0 {
+		defaultOrder = customerimporter.OrderByCount
+	}
+	if request.ordering, err = customerimporter.ParseOrdering(params.string("order", string(defaultOrder))); err != nil {
+		return nil, requestError{err: err}
+	}
+	if request.format, err = customerimporter.ParseFormat(params.string("format", "json")); err != nil {
+		return nil, requestError{err: err}
+	} else if _, served := contentTypes[request.format]; !served {
+		return nil, requestError{err: fmt.Errorf("format %q can't be served, use json, csv, ndjson or html", request.format)}
+	}
+
+	var dialect customerimporter.Dialect
+	if !request.ndjson {
+		if dialect.Delimiter, err = parseChar("delimiter", query.Get("delimiter")); err != nil {
+			return nil, err
+		}
+		if dialect.Comment, err = parseChar("comment", query.Get("comment")); err != nil {
+			return nil, err
+		}
+	}
+	request.options = append(request.options, customerimporter.WithDialect(dialect))
+	if where := query.Get("where"); where != "" {
+		request.options = append(request.options, customerimporter.WithFilter(where))
+	}
+	return request, nil
+}
+
+// validate checks the importer options of the request by building an importer which is never run, so a count
+// running later doesn't fail because of them
+func (c *countRequest) validate() error {
+	if _, err := customerimporter.NewReaderCustomerImporter("upload", strings.NewReader(""), c.emailColumn, c.options...); err != nil {
+		return requestError{err: err}
+	}
+	return nil
+}
+
+// countResult holds the domains of a count ready to be written, and how the import went unless it was a live count
+type countResult struct {
+	Domains []customerimporter.EmailDomain
+	Stats   *customerimporter.RunStats
+}
+
+// count counts the customers in `body` following the request, running the domains through the same steps as the
+// count command does. Live requests call `onSnapshot` every `every` while counting, when it's given
+func (c *countRequest) count(body io.Reader, every time.Duration, onSnapshot func(snapshot customerimporter.DomainSnapshot)) (*countResult, error) {
+	if c.ndjson {
+		converted := customerimporter.NdjsonToCsv(body)
+		defer converted.Close()
+		body = converted
+	}
+	importer, err := customerimporter.NewReaderCustomerImporter("upload", body, c.emailColumn, c.options...)
+	if err != nil {
+		return nil, requestError{err: err}
+	}
+	var domains []customerimporter.EmailDomain
+	var stats customerimporter.RunStats
+	if c.live && onSnapshot != nil {
+		domains, stats, err = importer.CustomerCountByDomainLive(0, every, maxSnapshotDomains, onSnapshot)
+	} else {
+		domains, stats, err = importer.CustomerCountByDomainWithStats()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if c.fixTypos {
+		suggestions := customerimporter.SuggestTypoCorrections(domains, customerimporter.DefaultTypoOptions())
+		domains = customerimporter.ApplyTypoCorrections(domains, suggestions, c.typoConfidence)
+	}
+	if c.kAnonymity > 0 {
+		domains = customerimporter.SuppressSmallDomains(domains, c.kAnonymity, c.bucketSuppressed)
+	}
+	domains = customerimporter.FilterByCategory(domains, c.onlyCategories, c.excludeCategories)
+	domains = c.sortDomains(domains, c.ordering)
+	c.formatOptions.Total = 0
+	for _, domain := range domains {
+		c.formatOptions.Total += domain.CustomerCount
+	}
+	if c.top > 0 && len(domains) > c.top {
+		domains = domains[:c.top]
+	}
+	return &countResult{Domains: domains, Stats: &stats}, nil
+}
+
+func (c *countRequest) write(w io.Writer, result *countResult) error {
+	options := c.formatOptions
+	options.Stats = result.Stats
+	return customerimporter.WriteDomains(w, result.Domains, c.format, options)
+}
+
+// setStatsHeaders describes how the import went in the X-Rows-Read and X-Rows-Rejected headers, unless it's unknown
+// as for jobs which didn't finish
+func (r *countResult) setStatsHeaders(header http.Header) {
+	if r.Stats == nil {
+		return
+	}
+	header.Set("X-Rows-Read", strconv.FormatInt(r.Stats.RowsRead, 10))
+	header.Set("X-Rows-Rejected", strconv.FormatInt(r.Stats.RowsRejected, 10))
+}
+
+// statusOf returns the status code answering a count which failed with `err`
+func statusOf(err error) int {
+	var request requestError
+	var keyNotFound customerimporter.KeyNotFoundError
+	var parseError *csv.ParseError
+	var syntaxError *json.SyntaxError
+	switch {
+	case errors.As(err, &request):
+		return http.StatusBadRequest
+	case errors.Is(err, errBodyTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.As(err, &keyNotFound) || errors.As(err, &parseError) || errors.As(err, &syntaxError) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF):
+		return http.StatusUnprocessableEntity
+	}
+	return http.StatusInternalServerError
+}
+
+// queryParams parses query parameters, keeping the first error found
+type queryParams struct {
+	query url.Values
+	err   error
+}
+
+func (p *queryParams) string(name, defaultValue string) string {
+	if value, given := p.query[name]; given && value[0] != "" {
+		return value[0]
+	}
+	return defaultValue
+}
+
+func (p *queryParams) list(name string) []string {
+	if value := p.query.Get(name); value != "" {
+		return strings.Split(value, ",")
+	}
+	return nil
+}
+
+func (p *queryParams) bool(name string) bool {
+	value, given := p.query[name]
+	if !given || value[0] == "" {
+		return given
+	}
+	parsed, err := strconv.ParseBool(value[0])
+	p.fail(name, value[0], err)
+	return parsed
+}
+
+func (p *queryParams) int(name string, defaultValue int) int {
+	value := p.string(name, "")
+	if value == "" {
+		return defaultValue
+	}
+	parsed, err := strconv.Atoi(value)
+	p.fail(name, value, err)
+	return parsed
+}
+
+func (p *queryParams) float(name string, defaultValue float64) float64 {
+	value := p.string(name, "")
+	if value == "" {
+		return defaultValue
+	}
+	parsed, err := strconv.ParseFloat(value, 64)
+	p.fail(name, value, err)
+	return parsed
+}
+
+func (p *queryParams) fail(name, value string, err error) {
+	if err != nil && p.err == nil {
+		p.err = requestError{err: fmt.Errorf("invalid value %q for %s", value, name)}
+	}
+}
+
+// parseChar parses the delimiter and comment parameters, a single ASCII character or \t for tabs
+func parseChar(name, value string) (byte, error) {
+	switch {
+	case value == "":
+		return 0, nil
+	case value == `\t`:
+		return '\t', nil
+	case len(value) == 1:
+		return value[0], nil
+	}
+	return 0, requestError{err: fmt.Errorf("%s must be a single character, not %s", name, strconv.Quote(value))}
+}
@@ -0,0 +1,190 @@
+package server
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/IllicLanthresh/TeamworkGoTests/internal/customerimporter"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+// Events sent over /jobs/{id}/events
+const (
+	// EventState carries the status of the job whenever its state changes
+	EventState = "state"
+	// EventProgress carries how much of the upload has been read, sent periodically while the job runs
+	EventProgress = "progress"
+	// EventSnapshot carries the domains with the most customers so far, sent periodically by live jobs
+	EventSnapshot = "snapshot"
+)
+
+const (
+	// maxJobEvents is the number of events of a job kept for clients reconnecting or connecting late
+	maxJobEvents = 64
+	// maxSnapshotDomains is the number of domains kept in snapshots, the most clients can ask for
+	maxSnapshotDomains     = 100
+	defaultSnapshotDomains = 10
+)
+
+//go:embed live.html
+var livePage []byte
+
+// jobEvent is an event of a job, their ids grow by one starting from 1
+type jobEvent struct {
+	id   int64
+	name string
+	data interface{}
+}
+
+// progressEvent is the data of EventProgress
+type progressEvent struct {
+	BytesRead  int64   `json:"bytes_read"`
+	BytesTotal int64   `json:"bytes_total"`
+	Progress   float64 `json:"progress"`
+	// CustomersCounted is only known for live jobs
+	CustomersCounted int64 `json:"customers_counted,omitempty"`
+}
+
+// snapshotEvent is the data of EventSnapshot, holding up to maxSnapshotDomains domains by customer count
+type snapshotEvent struct {
+	CustomersCounted int              `json:"customers_counted"`
+	Complete         bool             `json:"complete"`
+	Domains          []snapshotDomain `json:"domains"`
+}
+
+type snapshotDomain struct {
+	Domain    string `json:"domain"`
+	Customers int    `json:"customers"`
+}
+
+// newSnapshotEvent converts a snapshot of maxSnapshotDomains domains, which come ordered by customer count already
+func newSnapshotEvent(snapshot customerimporter.DomainSnapshot) snapshotEvent {
+	domains := snapshot.Domains
+	event := snapshotEvent{
+		CustomersCounted: snapshot.CustomersCounted,
+		Complete:         snapshot.Complete,
+		Domains:          make([]snapshotDomain, len(domains)),
+	}
+	for i, domain := range domains {
+		event.Domains[i] = snapshotDomain{Domain: domain.Domain, Customers: domain.CustomerCount}
+	}
+	return event
+}
+
+// eventLog keeps the last maxJobEvents events of a job, waking up whoever waits for new ones
+type eventLog struct {
+	mutex  sync.Mutex
+	events []jobEvent
+	lastId int64
+	// closed is set along with the last event of the job
+	closed bool
+	// changed is closed and replaced whenever an event is published
+	changed chan struct{}
+}
+
+func newEventLog() *eventLog {
+	return &eventLog{changed: make(chan struct{})}
+}
+
+// publish adds an event to the log, `last` closing it. Events published once closed are dropped
+func (l *eventLog) publish(name string, data interface{}, last bool) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.closed {
+		return
+	}
+	l.lastId++
+	l.events = append(l.events, jobEvent{id: l.lastId, name: name, data: data})
+	if len(l.events) > maxJobEvents {
+		l.events = append(l.events[:0:0], l.events[len(l.events)-maxJobEvents:]...)
+	}
+	l.closed = last
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
+
+// since returns the events kept after the one with id `lastId`, whether the log is closed and a channel closed once
+// more events are published
+func (l *eventLog) since(lastId int64) (events []jobEvent, closed bool, changed <-chan struct{}) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	for i, event := range l.events {
+		if event.id > lastId {
+			events = append(events, l.events[i:]...)
+			break
+		}
+	}
+	return events, l.closed, l.changed
+}
+
+// streamJobEvents sends the events of a job as Server-Sent Events until it finishes, the client goes away or the server
+// shuts down. Clients reconnecting with a Last-Event-ID header get the events they missed, as long as they're still
+// kept. Snapshots hold the `top` domains asked for in the query, defaultSnapshotDomains by default
+func (s *Server) streamJobEvents(w http.ResponseWriter, r *http.Request, j *job) {
+	flusher, canFlush := w.(http.Flusher)
+	if !canFlush {
+		writeError(w, http.StatusInternalServerError, errors.New("the connection can't stream events"))
+		return
+	}
+	top := defaultSnapshotDomains
+	if value := r.URL.Query().Get("top"); value != "" {
+		var err error
+		if top, err = strconv.Atoi(value); err != nil || top < 1 || top > maxSnapshotDomains {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("top must be a number from 1 to %d, not %q", maxSnapshotDomains, value))
+			return
+		}
+	}
+	// An invalid id is taken as none, sending every event kept
+	lastId, _ := strconv.ParseInt(r.Header.Get("Last-Event-ID"), 10, 64)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	for {
+		events, closed, changed := j.events.since(lastId)
+		for _, event := range events {
+			if snapshot, isSnapshot := event.data.(snapshotEvent); isSnapshot && len(snapshot.Domains) > top {
+				snapshot.Domains = snapshot.Domains[:top]
+				event.data = snapshot
+			}
+			data, err := json.Marshal(event.data)
+			if err != nil {
+				return
+			}
+			if _, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.id, event.name, data); err != nil {
+				return
+			}
+			lastId = event.id
+		}
+		flusher.Flush()
+		if closed {
+			return
+		}
+		select {
+		case <-changed:
+		case <-r.Context().Done():
+			return
+		case <-s.shuttingDown:
+			return
+		}
+	}
+}
+
+// handleLivePage serves a page uploading customers to a live job, or watching an existing one, and rendering its
+// events as they come
+func (s *Server) handleLivePage(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		writeError(w, http.StatusNotFound, errors.New("there's nothing here"))
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		writeError(w, http.StatusMethodNotAllowed, errors.New("use GET"))
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = w.Write(livePage)
+}
@@ -0,0 +1,168 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// sentEvent is an event as read from a Server-Sent Events stream
+type sentEvent struct {
+	id   string
+	name string
+	data string
+}
+
+// readEvents reads the events of a stream until the server closes it
+func readEvents(t *testing.T, url, lastEventId string) []sentEvent {
+	request, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lastEventId != "" {
+		request.Header.Set("Last-Event-ID", lastEventId)
+	}
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK || response.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("GET %s = %d with type %q, want an event stream", url, response.StatusCode, response.Header.Get("Content-Type"))
+	}
+	var events []sentEvent
+	var event sentEvent
+	scanner := bufio.NewScanner(response.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			events = append(events, event)
+			event = sentEvent{}
+		case strings.HasPrefix(line, "id: "):
+			event.id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "event: "):
+			event.name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			event.data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return events
+}
+
+func TestServer_jobEvents(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	customers, err := ioutil.ReadFile("../../test/data/importer/customers-small.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := newTestServer(t, Config{})
+	response, err := http.Post(server.URL+"/jobs?live", "text/csv", strings.NewReader(string(customers)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var submitted jobStatus
+	err = json.NewDecoder(response.Body).Decode(&submitted)
+	_ = response.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	eventsUrl := server.URL + "/jobs/" + submitted.Id + "/events?top=2"
+
+	// The stream ends with the job, replaying whatever was sent before connecting
+	events := readEvents(t, eventsUrl, "")
+	if len(events) < 4 || events[0].name != EventState || events[len(events)-1].name != EventState {
+		t.Fatalf("events = %+v, want them to start and end with the state of the job", events)
+	}
+	var last jobStatus
+	if err := json.Unmarshal([]byte(events[len(events)-1].data), &last); err != nil {
+		t.Fatal(err)
+	}
+	if last.State != JobSucceeded {
+		t.Errorf("last state = %+v, want it succeeded", last)
+	}
+	var snapshot snapshotEvent
+	for _, event := range events {
+		if event.name == EventSnapshot {
+			if err := json.Unmarshal([]byte(event.data), &snapshot); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	want := []snapshotDomain{{Domain: "github.io", Customers: 4}, {Domain: "cyberchimps.com", Customers: 2}}
+	if !snapshot.Complete || snapshot.CustomersCounted != 7 || len(snapshot.Domains) != 2 || snapshot.Domains[0] != want[0] || snapshot.Domains[1] != want[1] {
+		t.Errorf("last snapshot = %+v, want the complete top 2 domains", snapshot)
+	}
+
+	resumed := readEvents(t, eventsUrl, events[1].id)
+	if len(resumed) != len(events)-2 || resumed[0] != events[2] {
+		t.Errorf("events after %s = %+v, want %+v", events[1].id, resumed, events[2:])
+	}
+
+	for _, query := range []string{"?top=0", "?top=101", "?top=all"} {
+		response, err := http.Get(server.URL + "/jobs/" + submitted.Id + "/events" + query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = response.Body.Close()
+		if response.StatusCode != http.StatusBadRequest {
+			t.Errorf("GET events%s = %d, want %d", query, response.StatusCode, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestEventLog(t *testing.T) {
+	events := newEventLog()
+	for i := 0; i < maxJobEvents+2; i++ {
+		events.publish(EventProgress, i, false)
+	}
+	kept, closed, changed := events.since(0)
+	if len(kept) != maxJobEvents || kept[0].id != 3 || closed {
+		t.Fatalf("since(0) = %d events from %d, closed %v, want the last %d", len(kept), kept[0].id, closed, maxJobEvents)
+	}
+	if kept, _, _ := events.since(int64(maxJobEvents + 2)); len(kept) != 0 {
+		t.Errorf("since(last) = %+v, want no events", kept)
+	}
+
+	events.publish(EventState, "finished", true)
+	select {
+	case <-changed:
+	default:
+		t.Error("publish() didn't wake up the waiting clients")
+	}
+	events.publish(EventState, "again", false)
+	if kept, closed, _ := events.since(int64(maxJobEvents + 2)); len(kept) != 1 || kept[0].data != "finished" || !closed {
+		t.Errorf("since() = %+v, %v, want the last event only and the log closed", kept, closed)
+	}
+}
+
+func TestServer_livePage(t *testing.T) {
+	server := newTestServer(t, Config{})
+	tests := []struct {
+		path       string
+		wantStatus int
+	}{
+		{path: "/", wantStatus: http.StatusOK},
+		{path: "/missing", wantStatus: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		response, err := http.Get(server.URL + tt.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = response.Body.Close()
+		if response.StatusCode != tt.wantStatus {
+			t.Errorf("GET %s = %d, want %d", tt.path, response.StatusCode, tt.wantStatus)
+		}
+		if tt.wantStatus == http.StatusOK && response.Header.Get("Content-Type") != "text/html; charset=utf-8" {
+			t.Errorf("GET %s content type = %q, want HTML", tt.path, response.Header.Get("Content-Type"))
+		}
+	}
+}
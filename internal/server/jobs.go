@@ -0,0 +1,462 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/IllicLanthresh/TeamworkGoTests/internal/customerimporter"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// States a job goes through, queued and running ones can be canceled
+const (
+	JobQueued    = "queued"
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+	JobCanceled  = "canceled"
+)
+
+var (
+	errQueueFull   = errors.New("too many jobs are waiting to run, try again later")
+	errQueueClosed = errors.New("the server is shutting down")
+	errJobFinished = errors.New("the job already finished")
+)
+
+// job is a count of an upload run in the background, its upload is kept in a file until it runs and its result in
+// another one until it expires
+type job struct {
+	id         string
+	request    *countRequest
+	uploadPath string
+	resultPath string
+	uploadSize int64
+	retention  time.Duration
+	// bytesRead and customersCounted are updated atomically while the job runs, customers are only counted by live
+	// jobs
+	bytesRead        int64
+	customersCounted int64
+	ctx              context.Context
+	cancel           context.CancelFunc
+	events           *eventLog
+
+	// mutex guards the fields below, which change as the job runs
+	mutex    sync.Mutex
+	state    string
+	err      error
+	created  time.Time
+	started  time.Time
+	finished time.Time
+	stats    *customerimporter.RunStats
+}
+
+// jobStatus describes a job in responses
+type jobStatus struct {
+	Id    string `json:"id"`
+	State string `json:"state"`
+	// Progress is the fraction of the upload read so far, from 0 to 1
+	Progress   float64                    `json:"progress"`
+	BytesRead  int64                      `json:"bytes_read"`
+	BytesTotal int64                      `json:"bytes_total"`
+	Created    time.Time                  `json:"created"`
+	Started    *time.Time                 `json:"started,omitempty"`
+	Finished   *time.Time                 `json:"finished,omitempty"`
+	Expires    *time.Time                 `json:"expires,omitempty"`
+	Error      string                     `json:"error,omitempty"`
+	Stats      *customerimporter.RunStats `json:"stats,omitempty"`
+	ResultUrl  string                     `json:"result_url,omitempty"`
+}
+
+func (j *job) status() jobStatus {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	status := jobStatus{
+		Id:         j.id,
+		State:      j.state,
+		BytesRead:  atomic.LoadInt64(&j.bytesRead),
+		BytesTotal: j.uploadSize,
+		Created:    j.created,
+	}
+	if status.BytesTotal > 0 {
+		status.Progress = float64(status.BytesRead) / float64(status.BytesTotal)
+	}
+	if !j.started.IsZero() {
+		started := j.started
+		status.Started = &started
+	}
+	if !j.finished.IsZero() {
+		finished, expires := j.finished, j.finished.Add(j.retention)
+		status.Finished, status.Expires = &finished, &expires
+	}
+	if j.err != nil {
+		status.Error = j.err.Error()
+	}
+	if j.state == JobSucceeded {
+		status.Stats = j.stats
+		status.ResultUrl = "/jobs/" + j.id + "/result"
+	}
+	return status
+}
+
+// setState moves the job to `state` unless it's already in a final one, or not in `from` when given, sending an
+// EventState which is the last one for final states
+func (j *job) setState(state string, stats *customerimporter.RunStats, err error, from ...string) bool {
+	j.mutex.Lock()
+	if j.state != JobQueued && j.state != JobRunning || len(from) > 0 && j.state != from[0] {
+		j.mutex.Unlock()
+		return false
+	}
+	j.state, j.stats, j.err = state, stats, err
+	final := state != JobQueued && state != JobRunning
+	if final {
+		j.finished = time.Now()
+	} else if state == JobRunning {
+		j.started = time.Now()
+	}
+	j.mutex.Unlock()
+	j.events.publish(EventState, j.status(), final)
+	return true
+}
+
+// finish moves the job to its final state, failed or canceled when `err` is set
+func (j *job) finish(stats *customerimporter.RunStats, err error) {
+	switch {
+	case err == nil:
+		j.setState(JobSucceeded, stats, nil)
+	case errors.Is(err, context.Canceled):
+		j.setState(JobCanceled, nil, nil)
+	default:
+		j.setState(JobFailed, nil, err)
+	}
+}
+
+func (j *job) publishProgress() {
+	progress := progressEvent{
+		BytesRead:        atomic.LoadInt64(&j.bytesRead),
+		BytesTotal:       j.uploadSize,
+		CustomersCounted: atomic.LoadInt64(&j.customersCounted),
+	}
+	if progress.BytesTotal > 0 {
+		progress.Progress = float64(progress.BytesRead) / float64(progress.BytesTotal)
+	}
+	j.events.publish(EventProgress, progress, false)
+}
+
+func (j *job) publishSnapshot(snapshot customerimporter.DomainSnapshot) {
+	atomic.StoreInt64(&j.customersCounted, int64(snapshot.CustomersCounted))
+	j.events.publish(EventSnapshot, newSnapshotEvent(snapshot), false)
+}
+
+// jobReader reads the upload of a job, counting the bytes read and failing once the job is canceled
+type jobReader struct {
+	job    *job
+	reader io.Reader
+}
+
+func (r jobReader) Read(p []byte) (int, error) {
+	if err := r.job.ctx.Err(); err != nil {
+		return 0, err
+	}
+	read, err := r.reader.Read(p)
+	atomic.AddInt64(&r.job.bytesRead, int64(read))
+	return read, err
+}
+
+// jobQueue runs jobs on a fixed number of workers, keeping their results in `dir` for `retention` after they finish.
+// Running jobs send progress events, and live jobs snapshots, every `eventInterval`
+type jobQueue struct {
+	dir           string
+	retention     time.Duration
+	eventInterval time.Duration
+	queue         chan *job
+	done          chan struct{}
+	workers       sync.WaitGroup
+
+	mutex sync.Mutex
+	jobs  map[string]*job
+	// reserved counts the places in the queue held for jobs whose upload is being spooled
+	reserved int
+	// stopped is set once no more jobs are taken, and closed once the ones taken are canceled as well
+	stopped bool
+	closed  bool
+}
+
+// newJobQueue returns a queue holding up to `maxQueued` jobs waiting for a worker, workers are started with start
+func newJobQueue(dir string, maxQueued int, retention, eventInterval time.Duration) *jobQueue {
+	return &jobQueue{
+		dir:           dir,
+		retention:     retention,
+		eventInterval: eventInterval,
+		queue:         make(chan *job, maxQueued),
+		done:          make(chan struct{}),
+		jobs:          make(map[string]*job),
+	}
+}
+
+// start starts `workers` goroutines running the queued jobs, and another one removing expired jobs
+func (q *jobQueue) start(workers int) {
+	for i := 0; i < workers; i++ {
+		q.workers.Add(1)
+		go func() {
+			defer q.workers.Done()
+			for {
+				select {
+				case j := <-q.queue:
+					q.run(j)
+				case <-q.done:
+					return
+				}
+			}
+		}()
+	}
+	q.workers.Add(1)
+	go func() {
+		defer q.workers.Done()
+		interval := time.Minute
+		if q.retention < interval {
+			interval = q.retention
+		}
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case now := <-ticker.C:
+				q.expire(now)
+			case <-q.done:
+				return
+			}
+		}
+	}()
+}
+
+// submit spools `upload` to disk, up to `maxBytes`, and queues a job counting it following `request`. A place in the
+// queue is reserved first, so uploads aren't written to disk only to be turned down
+func (q *jobQueue) submit(request *countRequest, upload io.Reader, maxBytes int64) (*job, error) {
+	q.mutex.Lock()
+	switch {
+	case q.stopped:
+		q.mutex.Unlock()
+		return nil, errQueueClosed
+	case len(q.queue)+q.reserved >= cap(q.queue):
+		q.mutex.Unlock()
+		return nil, errQueueFull
+	}
+	q.reserved++
+	q.mutex.Unlock()
+
+	j, err := q.newJob(request, upload, maxBytes)
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	q.reserved--
+	if err != nil {
+		return nil, err
+	}
+	if q.stopped {
+		err = errQueueClosed
+	} else {
+		select {
+		case q.queue <- j:
+			q.jobs[j.id] = j
+			j.events.publish(EventState, j.status(), false)
+			return j, nil
+		default:
+			// The place reserved makes this unexpected
+			err = errQueueFull
+		}
+	}
+	j.cancel()
+	_ = os.Remove(j.uploadPath)
+	return nil, err
+}
+
+// newJob creates a queued job spooling `upload` to disk, up to `maxBytes`
+func (q *jobQueue) newJob(request *countRequest, upload io.Reader, maxBytes int64) (*job, error) {
+	id, err := newJobId()
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	j := &job{
+		id:         id,
+		request:    request,
+		uploadPath: filepath.Join(q.dir, id+".upload"),
+		resultPath: filepath.Join(q.dir, id+".result"),
+		retention:  q.retention,
+		ctx:        ctx,
+		cancel:     cancel,
+		events:     newEventLog(),
+		state:      JobQueued,
+		created:    time.Now(),
+	}
+	if j.uploadSize, err = spool(j.uploadPath, &limitedBody{reader: upload, remaining: maxBytes}); err != nil {
+		cancel()
+		return nil, err
+	}
+	return j, nil
+}
+
+// spool copies `upload` into a new file at `path`, returning its size
+func spool(path string, upload io.Reader) (size int64, err error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return 0, fmt.Errorf("couldn't store upload: %w", err)
+	}
+	size, err = io.Copy(file, upload)
+	if closeErr := file.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("couldn't store upload: %w", closeErr)
+	}
+	if err != nil {
+		_ = os.Remove(path)
+		return 0, err
+	}
+	return size, nil
+}
+
+// run counts the upload of a job and writes its result, unless it was canceled while queued
+func (q *jobQueue) run(j *job) {
+	if !j.setState(JobRunning, nil, nil, JobQueued) {
+		_ = os.Remove(j.uploadPath)
+		return
+	}
+
+	stopProgress, progressStopped := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(progressStopped)
+		ticker := time.NewTicker(q.eventInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				j.publishProgress()
+			case <-stopProgress:
+				return
+			}
+		}
+	}()
+	result, err := q.count(j)
+	close(stopProgress)
+	<-progressStopped
+	j.publishProgress()
+
+	if err := os.Remove(j.uploadPath); err != nil {
+		log.Printf("couldn't remove upload of job %s: %s", j.id, err)
+	}
+	var stats *customerimporter.RunStats
+	if result != nil {
+		stats = result.Stats
+	}
+	j.finish(stats, err)
+}
+
+func (q *jobQueue) count(j *job) (*countResult, error) {
+	upload, err := os.Open(j.uploadPath)
+	if err != nil {
+		return nil, err
+	}
+	defer upload.Close()
+	result, err := j.request.count(jobReader{job: j, reader: upload}, q.eventInterval, j.publishSnapshot)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Create(j.resultPath)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't store result: %w", err)
+	}
+	err = j.request.write(file, result)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(j.resultPath)
+		return nil, fmt.Errorf("couldn't store result: %w", err)
+	}
+	return result, nil
+}
+
+// get returns the job with the given id, nil if there's none
+func (q *jobQueue) get(id string) *job {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	return q.jobs[id]
+}
+
+// cancel cancels a queued or running job, failing with errJobFinished for the rest
+func (q *jobQueue) cancel(j *job) error {
+	// Workers skip jobs which aren't queued anymore, and set the state of running ones once the count stops
+	if !j.setState(JobCanceled, nil, nil, JobQueued) {
+		j.mutex.Lock()
+		running := j.state == JobRunning
+		j.mutex.Unlock()
+		if !running {
+			return errJobFinished
+		}
+	}
+	j.cancel()
+	return nil
+}
+
+// expire removes the jobs which finished more than the retention period before `now`, along with their results
+func (q *jobQueue) expire(now time.Time) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	for id, j := range q.jobs {
+		j.mutex.Lock()
+		expired := !j.finished.IsZero() && now.Sub(j.finished) >= q.retention
+		j.mutex.Unlock()
+		if !expired {
+			continue
+		}
+		if err := os.Remove(j.resultPath); err != nil && !os.IsNotExist(err) {
+			log.Printf("couldn't remove result of job %s: %s", id, err)
+		}
+		delete(q.jobs, id)
+	}
+}
+
+// stop stops taking jobs, leaving the ones queued or running to finish
+func (q *jobQueue) stop() {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	q.stopped = true
+}
+
+// close stops taking jobs, cancels the ones queued or running and waits for the workers to stop
+func (q *jobQueue) close() {
+	q.mutex.Lock()
+	if q.closed {
+		q.mutex.Unlock()
+		return
+	}
+	q.stopped, q.closed = true, true
+	for _, j := range q.jobs {
+		_ = q.cancel(j)
+	}
+	q.mutex.Unlock()
+	close(q.done)
+	q.workers.Wait()
+	for {
+		select {
+		case j := <-q.queue:
+			_ = os.Remove(j.uploadPath)
+		default:
+			return
+		}
+	}
+}
+
+func newJobId() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", fmt.Errorf("couldn't create job id: %w", err)
+	}
+	return hex.EncodeToString(id), nil
+}
@@ -0,0 +1,233 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServer_jobs(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	customers, err := ioutil.ReadFile("../../test/data/importer/customers-small.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := newTestServer(t, Config{})
+
+	response, err := http.Post(server.URL+"/jobs?order=count&format=csv", "text/csv", strings.NewReader(string(customers)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var submitted jobStatus
+	err = json.NewDecoder(response.Body).Decode(&submitted)
+	_ = response.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.StatusCode != http.StatusAccepted || response.Header.Get("Location") != "/jobs/"+submitted.Id {
+		t.Fatalf("POST /jobs = %d with location %q, want %d with the job", response.StatusCode, response.Header.Get("Location"), http.StatusAccepted)
+	}
+	if submitted.BytesTotal != int64(len(customers)) {
+		t.Errorf("BytesTotal = %d, want %d", submitted.BytesTotal, len(customers))
+	}
+
+	var status jobStatus
+	for deadline := time.Now().Add(5 * time.Second); status.Finished == nil; time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("the job didn't finish, last status %+v", status)
+		}
+		if status, err = getStatus(server.URL + "/jobs/" + submitted.Id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if status.State != JobSucceeded || status.Progress != 1 || status.Stats == nil || status.Stats.RowsRead != 8 {
+		t.Errorf("finished job status = %+v, want it to have succeeded reading 8 rows", status)
+	}
+
+	response, err = http.Get(server.URL + status.ResultUrl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := ioutil.ReadAll(response.Body)
+	_ = response.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "domain,customers\ngithub.io,4\ncyberchimps.com,2\n360.cn,1\n"; string(result) != want {
+		t.Errorf("result = %q, want %q", result, want)
+	}
+	if response.Header.Get("Content-Type") != "text/csv; charset=utf-8" || response.Header.Get("X-Rows-Rejected") != "1" {
+		t.Errorf("result headers = %v", response.Header)
+	}
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		wantStatus int
+	}{
+		{name: "cancel finished job", method: http.MethodDelete, path: "/jobs/" + submitted.Id, wantStatus: http.StatusConflict},
+		{name: "unknown job", method: http.MethodGet, path: "/jobs/unknown", wantStatus: http.StatusNotFound},
+		{name: "unknown job path", method: http.MethodGet, path: "/jobs/" + submitted.Id + "/events/all", wantStatus: http.StatusNotFound},
+		{name: "delete result", method: http.MethodDelete, path: status.ResultUrl, wantStatus: http.StatusMethodNotAllowed},
+		{name: "list jobs", method: http.MethodGet, path: "/jobs", wantStatus: http.StatusMethodNotAllowed},
+		{name: "invalid parameters", method: http.MethodPost, path: "/jobs?order=size", wantStatus: http.StatusBadRequest},
+		{name: "invalid filter", method: http.MethodPost, path: "/jobs?where=gender+%3D%3D", wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request, err := http.NewRequest(tt.method, server.URL+tt.path, strings.NewReader(string(customers)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			response, err := http.DefaultClient.Do(request)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_ = response.Body.Close()
+			if response.StatusCode != tt.wantStatus {
+				t.Errorf("%s %s = %d, want %d", tt.method, tt.path, response.StatusCode, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func getStatus(url string) (status jobStatus, err error) {
+	response, err := http.Get(url)
+	if err != nil {
+		return status, err
+	}
+	defer response.Body.Close()
+	err = json.NewDecoder(response.Body).Decode(&status)
+	return status, err
+}
+
+func newTestRequest(t *testing.T, query string) *countRequest {
+	request, err := parseCountRequest(httptest.NewRequest(http.MethodPost, "/jobs?"+query, nil), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return request
+}
+
+func TestJobQueue_cancel(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	queue := newJobQueue(t.TempDir(), 1, time.Hour, time.Second)
+	queued, err := queue.submit(newTestRequest(t, ""), strings.NewReader("email\njohn@360.cn\n"), 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := queue.submit(newTestRequest(t, ""), strings.NewReader("email\njohn@360.cn\n"), 1024); !errors.Is(err, errQueueFull) {
+		t.Errorf("submit() to a full queue error = %v, want errQueueFull", err)
+	}
+	if err := queue.cancel(queued); err != nil {
+		t.Fatal(err)
+	}
+	queue.start(1)
+	defer queue.close()
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		if _, err := os.Stat(queued.uploadPath); os.IsNotExist(err) {
+			break
+		} else if time.Now().After(deadline) {
+			t.Fatal("the upload of the canceled job wasn't removed")
+		}
+	}
+	if status := queued.status(); status.State != JobCanceled || status.BytesRead != 0 {
+		t.Errorf("canceled job status = %+v, want it canceled before reading anything", status)
+	}
+}
+
+// unreadUpload fails the test if an upload which should have been turned down is read
+type unreadUpload struct {
+	t *testing.T
+}
+
+func (u unreadUpload) Read(p []byte) (int, error) {
+	u.t.Error("the upload was read before being turned down")
+	return 0, io.EOF
+}
+
+func TestJobQueue_submitReserves(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	queue := newJobQueue(t.TempDir(), 1, time.Hour, time.Second)
+	// The only place in the queue is held while the first upload is being spooled
+	upload, writer := io.Pipe()
+	submitted := make(chan error, 1)
+	go func() {
+		_, err := queue.submit(newTestRequest(t, ""), upload, 1024)
+		submitted <- err
+	}()
+	if _, err := writer.Write([]byte("email\n")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := queue.submit(newTestRequest(t, ""), unreadUpload{t: t}, 1024); !errors.Is(err, errQueueFull) {
+		t.Errorf("submit() while the queue is reserved error = %v, want errQueueFull", err)
+	}
+	_ = writer.CloseWithError(errors.New("client went away"))
+	if err := <-submitted; err == nil {
+		t.Fatal("submit() of a failed upload error = nil")
+	}
+
+	if queue.reserved != 0 {
+		t.Errorf("places reserved after spooling failed = %d, want 0", queue.reserved)
+	}
+
+	queue.stop()
+	if _, err := queue.submit(newTestRequest(t, ""), unreadUpload{t: t}, 1024); !errors.Is(err, errQueueClosed) {
+		t.Errorf("submit() once stopped error = %v, want errQueueClosed", err)
+	}
+}
+
+func TestJobQueue_cancelRunning(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	queue := newJobQueue(t.TempDir(), 1, time.Hour, time.Second)
+	running, err := queue.submit(newTestRequest(t, ""), strings.NewReader("email\njohn@360.cn\n"), 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Canceling the context alone is what happens to a job canceled while a worker runs it
+	running.cancel()
+	queue.run(running)
+	if status := running.status(); status.State != JobCanceled || status.Error != "" {
+		t.Errorf("canceled job status = %+v, want it canceled", status)
+	}
+	if _, err := os.Stat(running.resultPath); !os.IsNotExist(err) {
+		t.Errorf("the canceled job has a result")
+	}
+}
+
+func TestJobQueue_expire(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	queue := newJobQueue(t.TempDir(), 1, time.Hour, time.Second)
+	j, err := queue.submit(newTestRequest(t, "format=csv"), strings.NewReader("email\njohn@360.cn\n"), 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	queue.run(j)
+	if status := j.status(); status.State != JobSucceeded {
+		t.Fatalf("job status = %+v, want it succeeded", status)
+	}
+	if result, err := ioutil.ReadFile(j.resultPath); err != nil || string(result) != "domain,customers\n360.cn,1\n" {
+		t.Fatalf("job result = %q, %v", result, err)
+	}
+
+	queue.expire(j.finished.Add(time.Hour - time.Second))
+	if queue.get(j.id) == nil {
+		t.Fatal("the job expired before the retention period")
+	}
+	queue.expire(j.finished.Add(time.Hour))
+	if queue.get(j.id) != nil {
+		t.Error("the job didn't expire after the retention period")
+	}
+	if _, err := os.Stat(j.resultPath); !os.IsNotExist(err) {
+		t.Error("the result of the expired job wasn't removed")
+	}
+}
@@ -0,0 +1,317 @@
+// Package server exposes the customer importer over HTTP, so other services can count the customers of each email
+// domain without shelling out to the command line
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/IllicLanthresh/TeamworkGoTests/internal/customerimporter"
+	"github.com/IllicLanthresh/TeamworkGoTests/pkg/metrics"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Defaults for the settings Config leaves unset
+const (
+	DefaultMaxBodyBytes  = 1 << 30
+	DefaultJobWorkers    = 2
+	DefaultMaxQueuedJobs = 100
+	DefaultJobRetention  = 24 * time.Hour
+	DefaultEventInterval = time.Second
+)
+
+// Config holds the settings of a Server
+type Config struct {
+	// Options are applied to the importer of every upload, before the ones coming from its query parameters
+	Options []customerimporter.ImporterOption
+	// MaxBodyBytes is the size of the biggest upload accepted, bigger ones fail with 413 Request Entity Too Large
+	MaxBodyBytes int64
+	// JobWorkers is the number of jobs run at the same time
+	JobWorkers int
+	// MaxQueuedJobs is the number of jobs which can wait for a worker, more fail with 503 Service Unavailable
+	MaxQueuedJobs int
+	// JobsDir is where uploads wait for their job to run and results are kept, a temporary directory removed on Close
+	// by default
+	JobsDir string
+	// JobRetention is how long jobs and their results are kept after they finish
+	JobRetention time.Duration
+	// EventInterval is how often running jobs send progress events, and live jobs snapshots, to /jobs/{id}/events
+	EventInterval time.Duration
+	// Pprof serves the runtime profiles of net/http/pprof under /debug/pprof/, they shouldn't be public
+	Pprof bool
+}
+
+// Server handles the HTTP API:
+//
+//	POST   /counts           counts the customers of each domain in the csv or NDJSON body, see parseCountRequest
+//	                         for the query parameters, answering with the domains in JSON, CSV, NDJSON or an HTML
+//	                         report
+//	POST   /jobs             like /counts, but the body is stored and counted in the background, answering with the
+//	                         status of the new job
+//	GET    /jobs/{id}        status and progress of a job
+//	DELETE /jobs/{id}        cancels a queued or running job
+//	GET    /jobs/{id}/result the result of a job which succeeded, as /counts would have answered
+//	GET    /jobs/{id}/events Server-Sent Events following a job, see streamJobEvents
+//	GET    /                 a page uploading a file as a live job and rendering its events
+//	GET    /metrics          metrics of the imports run so far in the Prometheus text format, live jobs included
+type Server struct {
+	config  Config
+	mux     *http.ServeMux
+	metrics *metrics.Registry
+	// sortDomains sorts the domains of every count, recording it in metrics
+	sortDomains func(domains []customerimporter.EmailDomain, ordering customerimporter.Ordering) []customerimporter.EmailDomain
+	jobs        *jobQueue
+	// shuttingDown is closed by Shutdown, ending the event streams
+	shuttingDown chan struct{}
+	shutdownOnce sync.Once
+	// tempDir is set when the server created JobsDir itself
+	tempDir string
+}
+
+// NewServer constructor for Server, Close stops the jobs it runs in the background once it's no longer served
+func NewServer(config Config) (*Server, error) {
+	if config.MaxBodyBytes <= 0 {
+		config.MaxBodyBytes = DefaultMaxBodyBytes
+	}
+	if config.JobWorkers <= 0 {
+		config.JobWorkers = DefaultJobWorkers
+	}
+	if config.MaxQueuedJobs <= 0 {
+		config.MaxQueuedJobs = DefaultMaxQueuedJobs
+	}
+	if config.JobRetention <= 0 {
+		config.JobRetention = DefaultJobRetention
+	}
+	if config.EventInterval <= 0 {
+		config.EventInterval = DefaultEventInterval
+	}
+	s := &Server{
+		config:       config,
+		mux:          http.NewServeMux(),
+		metrics:      metrics.NewRegistry(),
+		shuttingDown: make(chan struct{}),
+	}
+	importerMetrics := customerimporter.NewImporterMetrics(s.metrics)
+	s.sortDomains = importerMetrics.SortDomains
+	// The options are copied so the ones of the caller are left as they were
+	s.config.Options = append(append([]customerimporter.ImporterOption(nil), config.Options...),
+		customerimporter.WithMetrics(importerMetrics))
+	if config.JobsDir == "" {
+		dir, err := ioutil.TempDir("", "customer-jobs")
+		if err != nil {
+			return nil, fmt.Errorf("couldn't create jobs directory: %w", err)
+		}
+		s.config.JobsDir, s.tempDir = dir, dir
+	} else if err := os.MkdirAll(config.JobsDir, 0700); err != nil {
+		return nil, fmt.Errorf("couldn't create jobs directory: %w", err)
+	}
+	s.jobs = newJobQueue(s.config.JobsDir, config.MaxQueuedJobs, config.JobRetention, config.EventInterval)
+	s.jobs.start(config.JobWorkers)
+	s.mux.HandleFunc("/counts", s.handleCounts)
+	s.mux.HandleFunc("/jobs", s.handleJobs)
+	s.mux.HandleFunc("/jobs/", s.handleJob)
+	s.mux.HandleFunc("/", s.handleLivePage)
+	s.mux.Handle("/metrics", s.metrics)
+	if config.Pprof {
+		s.mux.HandleFunc("/debug/pprof/", pprof.Index)
+		s.mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+		s.mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		s.mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+		s.mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	}
+	return s, nil
+}
+
+// Shutdown stops taking jobs and ends the event streams, which otherwise only end with their job, so it can be
+// registered with http.Server.RegisterOnShutdown without the requests in flight waiting for jobs to finish. The jobs
+// already taken keep running until Close
+func (s *Server) Shutdown() {
+	s.shutdownOnce.Do(func() {
+		s.jobs.stop()
+		close(s.shuttingDown)
+	})
+}
+
+// Close shuts the server down, cancels the jobs queued or running and waits for them to stop, removing the jobs
+// directory if the server created it. Requests still being served may fail once it's called
+func (s *Server) Close() error {
+	s.Shutdown()
+	s.jobs.close()
+	if s.tempDir != "" {
+		return os.RemoveAll(s.tempDir)
+	}
+	return nil
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// handleCounts counts the customers in the body as it's uploaded, it's never held in memory as a whole
+func (s *Server) handleCounts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeError(w, http.StatusMethodNotAllowed, errors.New("use POST to upload the customers"))
+		return
+	}
+	if r.ContentLength > s.config.MaxBodyBytes {
+		writeError(w, http.StatusRequestEntityTooLarge, errBodyTooLarge)
+		return
+	}
+	request, err := parseCountRequest(r, s.config.Options)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	request.sortDomains = s.sortDomains
+	body := &limitedBody{reader: r.Body, remaining: s.config.MaxBodyBytes}
+	result, err := request.count(body, 0, nil)
+	if err != nil {
+		writeError(w, statusOf(err), err)
+		return
+	}
+	w.Header().Set("Content-Type", contentTypes[request.format])
+	result.setStatsHeaders(w.Header())
+	if err := request.write(w, result); err != nil {
+		// The status was already sent, all that's left is cutting the response short
+		log.Printf("couldn't write counts: %s", err)
+	}
+}
+
+// handleJobs stores the body and queues a job counting it, the query parameters are the ones of /counts
+func (s *Server) handleJobs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeError(w, http.StatusMethodNotAllowed, errors.New("use POST to upload the customers"))
+		return
+	}
+	if r.ContentLength > s.config.MaxBodyBytes {
+		writeError(w, http.StatusRequestEntityTooLarge, errBodyTooLarge)
+		return
+	}
+	request, err := parseCountRequest(r, s.config.Options)
+	if err == nil {
+		request.sortDomains = s.sortDomains
+		err = request.validate()
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	j, err := s.jobs.submit(request, r.Body, s.config.MaxBodyBytes)
+	switch {
+	case errors.Is(err, errBodyTooLarge):
+		writeError(w, http.StatusRequestEntityTooLarge, err)
+	case errors.Is(err, errQueueFull) || errors.Is(err, errQueueClosed):
+		w.Header().Set("Retry-After", "60")
+		writeError(w, http.StatusServiceUnavailable, err)
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, err)
+	default:
+		w.Header().Set("Location", "/jobs/"+j.id)
+		writeJson(w, http.StatusAccepted, j.status())
+	}
+}
+
+// handleJob answers the requests about a single job, under /jobs/{id}
+func (s *Server) handleJob(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/jobs/"), "/")
+	j := s.jobs.get(parts[0])
+	if j == nil || len(parts) > 2 || len(parts) == 2 && parts[1] != "result" && parts[1] != "events" {
+		writeError(w, http.StatusNotFound, errors.New("there's no such job, or it expired"))
+		return
+	}
+	allowed := "GET, DELETE"
+	if len(parts) == 2 {
+		allowed = "GET"
+	}
+	switch {
+	case r.Method == http.MethodGet && len(parts) == 2 && parts[1] == "events":
+		s.streamJobEvents(w, r, j)
+	case r.Method == http.MethodGet && len(parts) == 2:
+		s.writeJobResult(w, j)
+	case r.Method == http.MethodGet:
+		writeJson(w, http.StatusOK, j.status())
+	case r.Method == http.MethodDelete && len(parts) == 1:
+		if err := s.jobs.cancel(j); err != nil {
+			writeError(w, http.StatusConflict, err)
+			return
+		}
+		writeJson(w, http.StatusOK, j.status())
+	default:
+		w.Header().Set("Allow", allowed)
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("use %s", allowed))
+	}
+}
+
+func (s *Server) writeJobResult(w http.ResponseWriter, j *job) {
+	status := j.status()
+	if status.State != JobSucceeded {
+		writeError(w, http.StatusConflict, fmt.Errorf("the job is %s, there's no result", status.State))
+		return
+	}
+	result, err := os.Open(j.resultPath)
+	if err != nil {
+		writeError(w, http.StatusNotFound, errors.New("the result expired"))
+		return
+	}
+	defer result.Close()
+	w.Header().Set("Content-Type", contentTypes[j.request.format])
+	(&countResult{Stats: status.Stats}).setStatsHeaders(w.Header())
+	if _, err := io.Copy(w, result); err != nil {
+		log.Printf("couldn't write result of job %s: %s", j.id, err)
+	}
+}
+
+func writeJson(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(value); err != nil {
+		log.Printf("couldn't write response: %s", err)
+	}
+}
+
+// errorResponse is the body of every error response
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(errorResponse{Error: err.Error()})
+}
+
+var errBodyTooLarge = errors.New("the request body is bigger than the server accepts")
+
+// limitedBody fails with errBodyTooLarge once more than `remaining` bytes are read from `reader`
+type limitedBody struct {
+	reader    io.Reader
+	remaining int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.remaining < 0 {
+		return 0, errBodyTooLarge
+	}
+	// Reading one byte past the limit tells bodies ending right at it apart from bigger ones
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+	read, err := b.reader.Read(p)
+	b.remaining -= int64(read)
+	if b.remaining < 0 {
+		return read, errBodyTooLarge
+	}
+	return read, err
+}
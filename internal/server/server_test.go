@@ -0,0 +1,313 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestServer starts a Server with the given config, closed when the test ends
+func newTestServer(t *testing.T, config Config) *httptest.Server {
+	if config.JobsDir == "" {
+		config.JobsDir = t.TempDir()
+	}
+	api, err := NewServer(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(api)
+	t.Cleanup(func() {
+		server.Close()
+		if err := api.Close(); err != nil {
+			t.Error(err)
+		}
+	})
+	return server
+}
+
+func TestServer_counts(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	customers, err := ioutil.ReadFile("../../test/data/importer/customers-small.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ndjson := `{"email":"mhernandez0@github.io","gender":"Female"}` + "\n" + `{"email":"jhansen3@360.cn","gender":"Male"}` + "\n"
+	server := newTestServer(t, Config{MaxBodyBytes: int64(len(customers))})
+
+	tests := []struct {
+		name        string
+		method      string
+		query       string
+		contentType string
+		body        string
+		wantStatus  int
+		wantBody    string
+		wantHeader  http.Header
+	}{
+		{
+			name:       "csv",
+			body:       string(customers),
+			wantStatus: http.StatusOK,
+			wantBody: "[\n" +
+				"  {\n    \"domain\": \"360.cn\",\n    \"customers\": 1\n  },\n" +
+				"  {\n    \"domain\": \"cyberchimps.com\",\n    \"customers\": 2\n  },\n" +
+				"  {\n    \"domain\": \"github.io\",\n    \"customers\": 4\n  }\n" +
+				"]\n",
+			wantHeader: http.Header{"Content-Type": {"application/json"}, "X-Rows-Read": {"8"}, "X-Rows-Rejected": {"1"}},
+		},
+		{
+			name:       "csv format with options",
+			query:      "order=count&format=csv&percentage&categories=true",
+			body:       string(customers),
+			wantStatus: http.StatusOK,
+			wantBody:   "domain,customers,percentage,category\ngithub.io,4,57.14,corporate\ncyberchimps.com,2,28.57,corporate\n360.cn,1,14.29,corporate\n",
+			wantHeader: http.Header{"Content-Type": {"text/csv; charset=utf-8"}},
+		},
+		{
+			name:       "top",
+			query:      "top=2&format=ndjson&percentage",
+			body:       string(customers),
+			wantStatus: http.StatusOK,
+			wantBody:   `{"domain":"github.io","customers":4,"percentage":57.14}` + "\n" + `{"domain":"cyberchimps.com","customers":2,"percentage":28.57}` + "\n",
+		},
+		{
+			name:       "filter and dialect",
+			query:      "where=" + `gender+%3D%3D+"Female"` + "&delimiter=%3B&format=csv",
+			body:       strings.ReplaceAll(string(customers), ",", ";"),
+			wantStatus: http.StatusOK,
+			wantBody:   "domain,customers\ncyberchimps.com,1\ngithub.io,2\n",
+		},
+		{
+			name:        "ndjson",
+			contentType: "application/x-ndjson",
+			query:       "format=csv",
+			body:        ndjson,
+			wantStatus:  http.StatusOK,
+			wantBody:    "domain,customers\n360.cn,1\ngithub.io,1\n",
+		},
+		{
+			name:       "ndjson input parameter",
+			query:      "input=ndjson&format=csv&where=gender+%3D%3D+%22Male%22",
+			body:       ndjson,
+			wantStatus: http.StatusOK,
+			wantBody:   "domain,customers\n360.cn,1\n",
+		},
+		{
+			name:       "html report",
+			query:      "format=html",
+			body:       string(customers),
+			wantStatus: http.StatusOK,
+			wantHeader: http.Header{"Content-Type": {"text/html; charset=utf-8"}, "X-Rows-Read": {"8"}},
+		},
+		{
+			name:       "invalid order",
+			query:      "order=size",
+			body:       string(customers),
+			wantStatus: http.StatusBadRequest,
+			wantBody:   "{\"error\":\"\\\"size\\\" is not a valid ordering, use domain, count, reversed or tld\"}\n",
+		},
+		{
+			name:       "unescaped semicolon",
+			query:      "delimiter=;",
+			body:       string(customers),
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "invalid number",
+			query:      "top=many",
+			body:       string(customers),
+			wantStatus: http.StatusBadRequest,
+			wantBody:   "{\"error\":\"invalid value \\\"many\\\" for top\"}\n",
+		},
+		{
+			name:       "unserved format",
+			query:      "format=markdown",
+			body:       string(customers),
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "filter syntax error",
+			query:      "where=gender+%3D%3D",
+			body:       string(customers),
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "missing email column",
+			query:      "email-column=mail",
+			body:       string(customers),
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:       "malformed ndjson",
+			query:      "input=ndjson",
+			body:       `{"email":}`,
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:       "empty body",
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:       "too large",
+			body:       string(customers) + "Extra,Row,extra@github.io,Male,127.0.0.1\n",
+			wantStatus: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:       "wrong method",
+			method:     http.MethodGet,
+			wantStatus: http.StatusMethodNotAllowed,
+			wantHeader: http.Header{"Allow": {"POST"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			method := tt.method
+			if method == "" {
+				method = http.MethodPost
+			}
+			request, err := http.NewRequest(method, server.URL+"/counts?"+tt.query, strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.contentType != "" {
+				request.Header.Set("Content-Type", tt.contentType)
+			}
+			response, err := http.DefaultClient.Do(request)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer response.Body.Close()
+			body, err := ioutil.ReadAll(response.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if response.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d, body %q", response.StatusCode, tt.wantStatus, body)
+			}
+			if tt.wantBody != "" && string(body) != tt.wantBody {
+				t.Errorf("body = %q, want %q", body, tt.wantBody)
+			}
+			for name, values := range tt.wantHeader {
+				if got := response.Header.Get(name); got != values[0] {
+					t.Errorf("header %s = %q, want %q", name, got, values[0])
+				}
+			}
+		})
+	}
+}
+
+func TestServer_countsStreamed(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	customers, err := ioutil.ReadFile("../../test/data/importer/customers-small.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A body of unknown length bigger than the limit is only caught while it's read
+	server := newTestServer(t, Config{MaxBodyBytes: int64(len(customers)) - 1})
+	response, err := http.Post(server.URL+"/counts", "text/csv", ioutil.NopCloser(bytes.NewReader(customers)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = response.Body.Close()
+	if response.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("status = %d, want %d", response.StatusCode, http.StatusRequestEntityTooLarge)
+	}
+}
+
+func TestServer_metrics(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	customers, err := ioutil.ReadFile("../../test/data/importer/customers-small.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := newTestServer(t, Config{Pprof: true})
+	response, err := http.Post(server.URL+"/counts?order=count", "text/csv", bytes.NewReader(customers))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = response.Body.Close()
+
+	response, err = http.Get(server.URL + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	written, err := ioutil.ReadAll(response.Body)
+	_ = response.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"customer_imports_total{result=\"success\"} 1\n",
+		"customer_import_rows_read_total 8\n",
+		"customer_import_rows_rejected_total{reason=\"invalid_email\"} 1\n",
+		"customer_import_sort_seconds_count{order=\"count\"} 1\n",
+	} {
+		if !strings.Contains(string(written), want) {
+			t.Errorf("GET /metrics = %q, want it to contain %q", written, want)
+		}
+	}
+
+	tests := []struct {
+		name       string
+		config     Config
+		wantStatus int
+	}{
+		{name: "pprof", config: Config{Pprof: true}, wantStatus: http.StatusOK},
+		{name: "pprof disabled", config: Config{}, wantStatus: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response, err := http.Get(newTestServer(t, tt.config).URL + "/debug/pprof/cmdline")
+			if err != nil {
+				t.Fatal(err)
+			}
+			_ = response.Body.Close()
+			if response.StatusCode != tt.wantStatus {
+				t.Errorf("GET /debug/pprof/cmdline = %d, want %d", response.StatusCode, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestServer_Shutdown(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	api, err := NewServer(Config{JobsDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(api)
+	defer server.Close()
+	// A job which never runs, so its events would be streamed until the server is closed
+	ctx, cancel := context.WithCancel(context.Background())
+	queued := &job{id: "queued", ctx: ctx, cancel: cancel, events: newEventLog(), state: JobQueued, created: time.Now()}
+	api.jobs.mutex.Lock()
+	api.jobs.jobs[queued.id] = queued
+	api.jobs.mutex.Unlock()
+
+	time.AfterFunc(50*time.Millisecond, api.Shutdown)
+	readEvents(t, server.URL+"/jobs/queued/events", "")
+	response, err := http.Post(server.URL+"/jobs", "text/csv", strings.NewReader("email\njohn@360.cn\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = response.Body.Close()
+	if response.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("POST /jobs after Shutdown() = %d, want %d", response.StatusCode, http.StatusServiceUnavailable)
+	}
+	if status, err := getStatus(server.URL + "/jobs/queued"); err != nil || status.State != JobQueued {
+		t.Errorf("GET /jobs/queued after Shutdown() = %+v, %v, want the job still queued", status, err)
+	}
+
+	if err := api.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if queued.status().State != JobCanceled {
+		t.Errorf("job state after Close() = %q, want %q", queued.status().State, JobCanceled)
+	}
+}
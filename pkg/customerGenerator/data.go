@@ -0,0 +1,36 @@
+package customerGenerator
+
+var firstNames = []string{
+	"Mildred", "Bonnie", "Dennis", "Justin", "Norma", "Anna", "Carlos", "Diana", "Ernest", "Fatima", "George", "Hana",
+	"Ivan", "Julia", "Kenji", "Laura", "Mohamed", "Nadia", "Oscar", "Priya", "Quentin", "Rosa", "Samuel", "Teresa",
+	"Umar", "Valeria", "Walter", "Xiomara", "Yusuf", "Zoe",
+}
+
+var lastNames = []string{
+	"Hernandez", "Ortiz", "Henry", "Hansen", "Garcia", "Smith", "Johnson", "Williams", "Brown", "Jones", "Miller",
+	"Davis", "Martinez", "Lopez", "Wilson", "Anderson", "Thomas", "Taylor", "Moore", "Jackson", "Martin", "Lee", "Perez",
+	"Thompson", "White", "Harris", "Sanchez", "Clark", "Ramirez", "Lewis", "Robinson", "Walker", "Young", "Allen",
+	"King", "Wright", "Scott", "Torres", "Nguyen", "Hill", "Flores", "Green", "Adams", "Nelson", "Baker", "Hall",
+}
+
+var popularDomains = []string{
+	"gmail.com", "yahoo.com", "hotmail.com", "outlook.com", "aol.com", "icloud.com", "gmx.de", "yandex.ru", "qq.com",
+	"protonmail.com",
+}
+
+var syllablePool = []string{
+	"ka", "lo", "mi", "ne", "ru", "sa", "te", "vi", "zo", "bar", "cor", "dex", "fin", "gal", "hub", "jet", "lab", "max",
+	"net", "pix", "quo", "sys", "tek", "vox", "web", "zen",
+}
+
+var topLevelDomains = []string{
+	"com", "net", "org", "io", "co", "de", "fr", "es", "it", "nl", "co.uk", "com.br", "cn", "jp", "ru", "info", "biz",
+}
+
+var accentedVowels = map[rune][]rune{
+	'a': {'á', 'à', 'ä', 'å'},
+	'e': {'é', 'è', 'ë'},
+	'i': {'í', 'ï'},
+	'o': {'ó', 'ö', 'ø'},
+	'u': {'ú', 'ü'},
+}
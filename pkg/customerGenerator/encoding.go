@@ -0,0 +1,90 @@
+package customerGenerator
+
+import (
+	"io"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+const (
+	EncodingUtf8 = "utf-8"
+	// EncodingUtf8Bom starts the file with a byte order mark, as some spreadsheet applications do
+	EncodingUtf8Bom = "utf-8-bom"
+	// EncodingUtf16 is little endian with a byte order mark
+	EncodingUtf16 = "utf-16"
+	// EncodingLatin1 is ISO 8859-1, characters out of it are written as '?'
+	EncodingLatin1 = "latin1"
+)
+
+// encodingWriter encodes the UTF-8 text written to it, holding back incomplete characters until the next write
+type encodingWriter struct {
+	w        io.Writer
+	encoding string
+	pending  []byte
+	wroteBom bool
+	buffer   []byte
+}
+
+func newEncodingWriter(w io.Writer, encoding string) (*encodingWriter, error) {
+	switch encoding {
+	case EncodingUtf8, EncodingUtf8Bom, EncodingUtf16, EncodingLatin1:
+		return &encodingWriter{w: w, encoding: encoding}, nil
+	}
+	return nil, InvalidOptionError{option: "encoding", value: encoding}
+}
+
+func (e *encodingWriter) Write(p []byte) (int, error) {
+	if !e.wroteBom {
+		e.wroteBom = true
+		switch e.encoding {
+		case EncodingUtf8Bom:
+			e.buffer = append(e.buffer, 0xef, 0xbb, 0xbf)
+		case EncodingUtf16:
+			e.buffer = append(e.buffer, 0xff, 0xfe)
+		}
+	}
+	if e.encoding == EncodingUtf8 || e.encoding == EncodingUtf8Bom {
+		if err := e.flush(); err != nil {
+			return 0, err
+		}
+		return e.w.Write(p)
+	}
+
+	text := append(e.pending, p...)
+	for len(text) > 0 && utf8.FullRune(text) {
+		r, size := utf8.DecodeRune(text)
+		text = text[size:]
+		switch e.encoding {
+		case EncodingUtf16:
+			for _, unit := range utf16.Encode([]rune{r}) {
+				e.buffer = append(e.buffer, byte(unit), byte(unit>>8))
+			}
+		case EncodingLatin1:
+			if r > 0xff {
+				r = '?'
+			}
+			e.buffer = append(e.buffer, byte(r))
+		}
+	}
+	e.pending = append(e.pending[:0], text...)
+	return len(p), e.flush()
+}
+
+func (e *encodingWriter) flush() error {
+	if len(e.buffer) == 0 {
+		return nil
+	}
+	_, err := e.w.Write(e.buffer)
+	e.buffer = e.buffer[:0]
+	return err
+}
+
+// Close writes any incomplete character left as the replacement character
+func (e *encodingWriter) Close() error {
+	if len(e.pending) == 0 {
+		return nil
+	}
+	e.pending = nil
+	_, err := e.Write([]byte(string(utf8.RuneError)))
+	return err
+}
@@ -0,0 +1,302 @@
+// Package customerGenerator writes synthetic customer files shaped like the ones customerimporter reads, with the same
+// columns as test/data/importer/customers.csv. Files are reproducible, the same options and seed always produce the
+// same bytes
+package customerGenerator
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
+)
+
+const (
+	FormatCsv    = "csv"
+	FormatNdjson = "ndjson"
+
+	DistributionUniform = "uniform"
+	// DistributionZipf makes a few domains hold most customers, like real free-mail providers do
+	DistributionZipf = "zipf"
+)
+
+// Columns are the columns of every generated row, in order
+var Columns = []string{"first_name", "last_name", "email", "gender", "ip_address"}
+
+// Options shapes the generated file, see DefaultOptions
+type Options struct {
+	Rows   int
+	Seed   int64
+	Format string
+	// Domains is the number of different domains customers are spread over
+	Domains      int
+	Distribution string
+	// ZipfExponent must be greater than 1, the higher it is the more customers the most popular domains get
+	ZipfExponent float64
+	// InvalidRatio is the ratio of rows with an invalid email address
+	InvalidRatio float64
+	// DuplicateRatio is the ratio of rows repeating the email address of a previous row
+	DuplicateRatio float64
+	// IdnRatio is the ratio of domains with non ASCII characters
+	IdnRatio float64
+	Encoding string
+}
+
+// DefaultOptions returns options for a thousand valid rows over a hundred domains following a Zipf distribution
+func DefaultOptions() Options {
+	return Options{
+		Rows:         1000,
+		Seed:         1,
+		Format:       FormatCsv,
+		Domains:      100,
+		Distribution: DistributionZipf,
+		ZipfExponent: 1.2,
+		Encoding:     EncodingUtf8,
+	}
+}
+
+type InvalidOptionError struct {
+	option string
+	value  interface{}
+}
+
+func (e InvalidOptionError) Error() string {
+	return fmt.Sprintf("%v is not a valid value for %s", e.value, e.option)
+}
+
+// validate checks every option, returning an InvalidOptionError for the first one out of range
+func (o Options) validate() error {
+	switch {
+	case o.Rows < 0:
+		return InvalidOptionError{option: "rows", value: o.Rows}
+	case o.Format != FormatCsv && o.Format != FormatNdjson:
+		return InvalidOptionError{option: "format", value: o.Format}
+	case o.Domains < 1:
+		return InvalidOptionError{option: "domains", value: o.Domains}
+	case o.Distribution != DistributionUniform && o.Distribution != DistributionZipf:
+		return InvalidOptionError{option: "distribution", value: o.Distribution}
+	case o.Distribution == DistributionZipf && o.ZipfExponent <= 1:
+		return InvalidOptionError{option: "zipf exponent", value: o.ZipfExponent}
+	case o.InvalidRatio < 0 || o.InvalidRatio > 1:
+		return InvalidOptionError{option: "invalid ratio", value: o.InvalidRatio}
+	case o.DuplicateRatio < 0 || o.DuplicateRatio > 1:
+		return InvalidOptionError{option: "duplicate ratio", value: o.DuplicateRatio}
+	case o.IdnRatio < 0 || o.IdnRatio > 1:
+		return InvalidOptionError{option: "IDN ratio", value: o.IdnRatio}
+	}
+	if _, err := newEncodingWriter(nil, o.Encoding); err != nil {
+		return err
+	}
+	return nil
+}
+
+// maxRememberedAddresses caps the addresses kept around to be duplicated, so memory doesn't grow with the rows
+const maxRememberedAddresses = 1 << 16
+
+// generator holds the state of a single Generate call
+type generator struct {
+	options   Options
+	random    *rand.Rand
+	domains   []string
+	zipf      *rand.Zipf
+	addresses []string
+	row       int
+}
+
+// Generate writes a file with `options.Rows` customers to `w`, a csv file with a headers row or one JSON object per
+// line depending on `options.Format`
+func Generate(w io.Writer, options Options) error {
+	if err := options.validate(); err != nil {
+		return err
+	}
+	encoded, err := newEncodingWriter(w, options.Encoding)
+	if err != nil {
+		return err
+	}
+	buffered := bufio.NewWriter(encoded)
+
+	g := &generator{options: options, random: rand.New(rand.NewSource(options.Seed))}
+	g.domains = g.domainPool()
+	if options.Distribution == DistributionZipf {
+		g.zipf = rand.NewZipf(g.random, options.ZipfExponent, 1, uint64(options.Domains-1))
+	}
+
+	var write func(customer []string) error
+	var flush func() error
+	if options.Format == FormatCsv {
+		csvWriter := csv.NewWriter(buffered)
+		if err := csvWriter.Write(Columns); err != nil {
+			return fmt.Errorf("couldn't write headers: %w", err)
+		}
+		write = csvWriter.Write
+		flush = func() error {
+			csvWriter.Flush()
+			return csvWriter.Error()
+		}
+	} else {
+		encoder := json.NewEncoder(buffered)
+		encoder.SetEscapeHTML(false)
+		write = func(customer []string) error {
+			object := make(orderedObject, len(Columns))
+			for i, column := range Columns {
+				object[i] = [2]string{column, customer[i]}
+			}
+			return encoder.Encode(object)
+		}
+		flush = func() error { return nil }
+	}
+
+	for g.row = 0; g.row < options.Rows; g.row++ {
+		if err := write(g.customer()); err != nil {
+			return fmt.Errorf("couldn't write customer: %w", err)
+		}
+	}
+	if err := flush(); err != nil {
+		return fmt.Errorf("couldn't write customer: %w", err)
+	}
+	if err := buffered.Flush(); err != nil {
+		return fmt.Errorf("couldn't write customer: %w", err)
+	}
+	return encoded.Close()
+}
+
+// orderedObject is marshalled as a JSON object with its keys in order, unlike a map
+type orderedObject [][2]string
+
+func (o orderedObject) MarshalJSON() ([]byte, error) {
+	var builder strings.Builder
+	builder.WriteByte('{')
+	for i, pair := range o {
+		if i > 0 {
+			builder.WriteByte(',')
+		}
+		for j, value := range pair {
+			encoded, err := json.Marshal(value)
+			if err != nil {
+				return nil, err
+			}
+			builder.Write(encoded)
+			if j == 0 {
+				builder.WriteByte(':')
+			}
+		}
+	}
+	builder.WriteByte('}')
+	return []byte(builder.String()), nil
+}
+
+// customer returns the columns of the next row
+func (g *generator) customer() []string {
+	firstName := firstNames[g.random.Intn(len(firstNames))]
+	lastName := lastNames[g.random.Intn(len(lastNames))]
+	gender := "Female"
+	if g.random.Intn(2) == 0 {
+		gender = "Male"
+	}
+	return []string{firstName, lastName, g.address(firstName, lastName), gender, g.ipAddress()}
+}
+
+// address returns an invalid address, a duplicate of a previous one or a new one for the row, following the ratios
+func (g *generator) address(firstName, lastName string) string {
+	roll := g.random.Float64()
+	if roll < g.options.InvalidRatio {
+		return g.invalidAddress(firstName, lastName)
+	}
+	if roll < g.options.InvalidRatio+g.options.DuplicateRatio && len(g.addresses) > 0 {
+		return g.addresses[g.random.Intn(len(g.addresses))]
+	}
+
+	address := strings.ToLower(firstName[:1]+lastName) + strconv.Itoa(g.row) + "@" + g.domain()
+	if len(g.addresses) < maxRememberedAddresses {
+		g.addresses = append(g.addresses, address)
+	} else {
+		g.addresses[g.random.Intn(maxRememberedAddresses)] = address
+	}
+	return address
+}
+
+func (g *generator) invalidAddress(firstName, lastName string) string {
+	localPart := strings.ToLower(firstName[:1] + lastName)
+	switch g.random.Intn(5) {
+	case 0:
+		return ""
+	case 1:
+		return localPart
+	case 2:
+		return localPart + "@"
+	case 3:
+		return "@" + g.domain()
+	default:
+		return localPart + " at " + g.domain()
+	}
+}
+
+func (g *generator) domain() string {
+	if g.zipf != nil {
+		return g.domains[g.zipf.Uint64()]
+	}
+	return g.domains[g.random.Intn(len(g.domains))]
+}
+
+// ipAddress returns a random IPv4 address, or an IPv6 one for about one in ten rows
+func (g *generator) ipAddress() string {
+	if g.random.Intn(10) == 0 {
+		ip := make(net.IP, net.IPv6len)
+		copy(ip, []byte{0x20, 0x01, 0x0d, 0xb8})
+		g.random.Read(ip[4:])
+		return ip.String()
+	}
+	return net.IPv4(byte(1+g.random.Intn(223)), byte(g.random.Intn(256)), byte(g.random.Intn(256)), byte(g.random.Intn(256))).String()
+}
+
+// domainPool returns `options.Domains` different domains, starting with the most popular free-mail providers so
+// they are the ones getting most customers with a Zipf distribution
+func (g *generator) domainPool() []string {
+	domains := make([]string, 0, g.options.Domains)
+	seen := make(map[string]bool)
+	for _, domain := range popularDomains {
+		if len(domains) == g.options.Domains {
+			break
+		}
+		domains = append(domains, domain)
+		seen[domain] = true
+	}
+	for len(domains) < g.options.Domains {
+		var label string
+		for syllables := 2 + g.random.Intn(3); syllables > 0; syllables-- {
+			label += syllablePool[g.random.Intn(len(syllablePool))]
+		}
+		if g.random.Float64() < g.options.IdnRatio {
+			label = idnLabel(label, g.random)
+		}
+		domain := label + "." + topLevelDomains[g.random.Intn(len(topLevelDomains))]
+		if seen[domain] {
+			continue
+		}
+		domains = append(domains, domain)
+		seen[domain] = true
+	}
+	return domains
+}
+
+// idnLabel replaces a vowel of `label` with an accented one, or appends one if it has none
+func idnLabel(label string, random *rand.Rand) string {
+	runes := []rune(label)
+	var vowels []int
+	for i, r := range runes {
+		if _, found := accentedVowels[r]; found {
+			vowels = append(vowels, i)
+		}
+	}
+	if len(vowels) == 0 {
+		return label + "ü"
+	}
+	i := vowels[random.Intn(len(vowels))]
+	accented := accentedVowels[runes[i]]
+	runes[i] = accented[random.Intn(len(accented))]
+	return string(runes)
+}
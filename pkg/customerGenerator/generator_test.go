@@ -0,0 +1,262 @@
+package customerGenerator
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// generate returns the csv records generated with `options`, headers row included
+func generate(t *testing.T, options Options) [][]string {
+	var output bytes.Buffer
+	if err := Generate(&output, options); err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(&output).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return records
+}
+
+func TestGenerate(t *testing.T) {
+	validAddress := regexp.MustCompile(`^[a-z]+[0-9]+@[^@ ]+\.[a-z.]+$`)
+	tests := []struct {
+		name          string
+		options       func(options *Options)
+		wantInvalid   float64
+		wantDuplicate float64
+		wantIdn       bool
+	}{
+		{
+			name:    "defaults",
+			options: func(options *Options) {},
+		},
+		{
+			name: "invalid rows",
+			options: func(options *Options) {
+				options.InvalidRatio = 0.2
+			},
+			wantInvalid: 0.2,
+		},
+		{
+			name: "duplicates",
+			options: func(options *Options) {
+				options.DuplicateRatio = 0.3
+				options.Distribution = DistributionUniform
+			},
+			wantDuplicate: 0.3,
+		},
+		{
+			name: "IDN domains",
+			options: func(options *Options) {
+				options.Domains = 500
+				options.IdnRatio = 0.5
+			},
+			wantIdn: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := DefaultOptions()
+			options.Rows = 5000
+			tt.options(&options)
+			records := generate(t, options)
+			if len(records) != options.Rows+1 || strings.Join(records[0], ",") != strings.Join(Columns, ",") {
+				t.Fatalf("Generate() wrote %d rows with headers %v, want %d rows", len(records)-1, records[0], options.Rows)
+			}
+
+			invalid, duplicates, idn := 0, 0, false
+			seen := make(map[string]bool)
+			domains := make(map[string]bool)
+			for _, record := range records[1:] {
+				address := record[2]
+				if !validAddress.MatchString(address) {
+					invalid++
+					continue
+				}
+				if seen[address] {
+					duplicates++
+				}
+				seen[address] = true
+				domain := address[strings.LastIndexByte(address, '@')+1:]
+				domains[domain] = true
+				for _, r := range domain {
+					idn = idn || r >= utf8.RuneSelf
+				}
+			}
+			if ratio := float64(invalid) / float64(options.Rows); ratio < tt.wantInvalid*0.8 || ratio > tt.wantInvalid*1.2 {
+				t.Errorf("Generate() wrote %v invalid addresses, want about %v", ratio, tt.wantInvalid)
+			}
+			if ratio := float64(duplicates) / float64(options.Rows); ratio < tt.wantDuplicate*0.8 || ratio > tt.wantDuplicate*1.2 {
+				t.Errorf("Generate() wrote %v duplicate addresses, want about %v", ratio, tt.wantDuplicate)
+			}
+			if idn != tt.wantIdn {
+				t.Errorf("Generate() IDN domains = %v, want %v", idn, tt.wantIdn)
+			}
+			if len(domains) > options.Domains {
+				t.Errorf("Generate() wrote %d domains, want at most %d", len(domains), options.Domains)
+			}
+		})
+	}
+}
+
+func TestGenerate_reproducible(t *testing.T) {
+	options := DefaultOptions()
+	options.InvalidRatio, options.DuplicateRatio, options.IdnRatio = 0.1, 0.1, 0.1
+	var first, second, otherSeed bytes.Buffer
+	for _, output := range []*bytes.Buffer{&first, &second} {
+		if err := Generate(output, options); err != nil {
+			t.Fatal(err)
+		}
+	}
+	options.Seed++
+	if err := Generate(&otherSeed, options); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(first.Bytes(), second.Bytes()) {
+		t.Error("Generate() wrote different files with the same seed")
+	}
+	if bytes.Equal(first.Bytes(), otherSeed.Bytes()) {
+		t.Error("Generate() wrote the same file with different seeds")
+	}
+}
+
+func TestGenerate_distribution(t *testing.T) {
+	// The share of customers of the most popular domain
+	topShare := func(distribution string) float64 {
+		options := DefaultOptions()
+		options.Rows = 10000
+		options.Distribution = distribution
+		counts := make(map[string]int)
+		top := 0
+		for _, record := range generate(t, options)[1:] {
+			domain := record[2][strings.LastIndexByte(record[2], '@')+1:]
+			counts[domain]++
+			if counts[domain] > top {
+				top = counts[domain]
+			}
+		}
+		return float64(top) / float64(options.Rows)
+	}
+	if uniform := topShare(DistributionUniform); uniform > 0.05 {
+		t.Errorf("the most popular domain got %v of the customers with a uniform distribution over 100 domains", uniform)
+	}
+	if zipf := topShare(DistributionZipf); zipf < 0.2 {
+		t.Errorf("the most popular domain got %v of the customers with a Zipf distribution", zipf)
+	}
+}
+
+func TestGenerate_ndjson(t *testing.T) {
+	options := DefaultOptions()
+	options.Rows = 10
+	options.Format = FormatNdjson
+	var output bytes.Buffer
+	if err := Generate(&output, options); err != nil {
+		t.Fatal(err)
+	}
+	scanner := bufio.NewScanner(&output)
+	lines := 0
+	for ; scanner.Scan(); lines++ {
+		var customer map[string]string
+		if err := json.Unmarshal(scanner.Bytes(), &customer); err != nil {
+			t.Fatal(err)
+		}
+		if len(customer) != len(Columns) || customer["email"] == "" {
+			t.Errorf("Generate() wrote %v, want every column", customer)
+		}
+		if !strings.HasPrefix(scanner.Text(), `{"first_name":`) {
+			t.Errorf("Generate() wrote %s, want the columns in order", scanner.Text())
+		}
+	}
+	if lines != options.Rows {
+		t.Errorf("Generate() wrote %d lines, want %d", lines, options.Rows)
+	}
+}
+
+func TestGenerate_encodings(t *testing.T) {
+	options := DefaultOptions()
+	options.Rows = 200
+	options.IdnRatio = 1
+	var utf8Output bytes.Buffer
+	if err := Generate(&utf8Output, options); err != nil {
+		t.Fatal(err)
+	}
+	text := utf8Output.String()
+
+	tests := []struct {
+		encoding string
+		decode   func(encoded []byte) string
+	}{
+		{
+			encoding: EncodingUtf8Bom,
+			decode: func(encoded []byte) string {
+				return string(bytes.TrimPrefix(encoded, []byte{0xef, 0xbb, 0xbf}))
+			},
+		},
+		{
+			encoding: EncodingUtf16,
+			decode: func(encoded []byte) string {
+				encoded = bytes.TrimPrefix(encoded, []byte{0xff, 0xfe})
+				units := make([]uint16, len(encoded)/2)
+				for i := range units {
+					units[i] = uint16(encoded[2*i]) | uint16(encoded[2*i+1])<<8
+				}
+				return string(utf16.Decode(units))
+			},
+		},
+		{
+			encoding: EncodingLatin1,
+			decode: func(encoded []byte) string {
+				runes := make([]rune, len(encoded))
+				for i, b := range encoded {
+					runes[i] = rune(b)
+				}
+				return string(runes)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.encoding, func(t *testing.T) {
+			options.Encoding = tt.encoding
+			var output bytes.Buffer
+			if err := Generate(&output, options); err != nil {
+				t.Fatal(err)
+			}
+			if got := tt.decode(output.Bytes()); got != text {
+				t.Errorf("Generate() decoded output differs from the UTF-8 one")
+			}
+		})
+	}
+}
+
+func TestGenerate_invalidOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		options func(options *Options)
+	}{
+		{name: "format", options: func(options *Options) { options.Format = "xml" }},
+		{name: "distribution", options: func(options *Options) { options.Distribution = "normal" }},
+		{name: "zipf exponent", options: func(options *Options) { options.ZipfExponent = 1 }},
+		{name: "domains", options: func(options *Options) { options.Domains = 0 }},
+		{name: "ratio", options: func(options *Options) { options.InvalidRatio = 1.5 }},
+		{name: "encoding", options: func(options *Options) { options.Encoding = "ebcdic" }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := DefaultOptions()
+			tt.options(&options)
+			var invalidOption InvalidOptionError
+			if err := Generate(&bytes.Buffer{}, options); !errors.As(err, &invalidOption) {
+				t.Errorf("Generate() error = %v, want an InvalidOptionError", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package hyperLogLog
+
+import (
+	"math"
+	"strconv"
+	"testing"
+)
+
+func TestSketch_Estimate(t *testing.T) {
+	tests := []struct {
+		name      string
+		precision int
+		values    int
+		repeats   int
+		// tolerance is the relative error allowed, about three standard errors
+		tolerance float64
+	}{
+		{name: "empty", precision: 14, values: 0, repeats: 1},
+		{name: "a few values", precision: 14, values: 7, repeats: 1},
+		{name: "repeated values", precision: 14, values: 1000, repeats: 3, tolerance: 0.01},
+		{name: "many values", precision: 14, values: 200000, repeats: 1, tolerance: 0.025},
+		{name: "low precision", precision: 8, values: 50000, repeats: 1, tolerance: 0.2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sketch := NewSketch(tt.precision)
+			for repeat := 0; repeat < tt.repeats; repeat++ {
+				for i := 0; i < tt.values; i++ {
+					sketch.Add([]byte("customer" + strconv.Itoa(i) + "@example.com"))
+				}
+			}
+			got := sketch.Estimate()
+			if relativeError := math.Abs(float64(got)-float64(tt.values)) / math.Max(float64(tt.values), 1); relativeError > tt.tolerance {
+				t.Errorf("Estimate() = %d, want %d within %.1f%%", got, tt.values, tt.tolerance*100)
+			}
+		})
+	}
+}
+
+func TestSketch_Merge(t *testing.T) {
+	whole, first, second := NewSketch(12), NewSketch(12), NewSketch(12)
+	for i := 0; i < 10000; i++ {
+		value := []byte(strconv.Itoa(i))
+		whole.Add(value)
+		if i < 6000 {
+			first.Add(value)
+		}
+		if i >= 4000 {
+			second.Add(value)
+		}
+	}
+	first.Merge(second)
+	if got, want := first.Estimate(), whole.Estimate(); got != want {
+		t.Errorf("Estimate() after Merge() = %d, want %d like adding every value to one sketch", got, want)
+	}
+}
+
+func TestNewSketch_precision(t *testing.T) {
+	for _, precision := range []int{MinPrecision - 1, MaxPrecision + 1} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("NewSketch(%d) didn't panic", precision)
+				}
+			}()
+			NewSketch(precision)
+		}()
+	}
+}
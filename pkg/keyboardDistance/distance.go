@@ -0,0 +1,102 @@
+// Package keyboardDistance implements an edit distance between strings aware of the QWERTY layout, so the typos a
+// person is most likely to make, like hitting a neighbour key or swapping two letters, are cheaper than the rest
+package keyboardDistance
+
+const (
+	insertionCost = 1.0
+	deletionCost  = 1.0
+	// substitutionCost is used when substituting a character with any other than its keyboard neighbours
+	substitutionCost = 1.0
+	// adjacentSubstitutionCost is used when substituting a character with one of its keyboard neighbours
+	adjacentSubstitutionCost = 0.5
+	// transpositionCost is used when two consecutive characters are swapped
+	transpositionCost = 0.5
+)
+
+type keyPosition struct {
+	row int
+	x   float64
+}
+
+// keyPositions maps each key to its row and horizontal position, rows are staggered as in a physical keyboard
+var keyPositions = func() map[byte]keyPosition {
+	rows := []struct {
+		keys   string
+		offset float64
+	}{
+		{keys: "1234567890-", offset: 0},
+		{keys: "qwertyuiop", offset: 0.5},
+		{keys: "asdfghjkl", offset: 0.75},
+		{keys: "zxcvbnm", offset: 1.25},
+	}
+	positions := make(map[byte]keyPosition)
+	for row, r := range rows {
+		for column := 0; column < len(r.keys); column++ {
+			positions[r.keys[column]] = keyPosition{row: row, x: float64(column) + r.offset}
+		}
+	}
+	return positions
+}()
+
+// Adjacent returns whether `a` and `b` are neighbour keys in a QWERTY keyboard, letters are expected lowercase
+func Adjacent(a, b byte) bool {
+	positionA, foundA := keyPositions[a]
+	positionB, foundB := keyPositions[b]
+	if !foundA || !foundB || a == b {
+		return false
+	}
+	rowDistance := positionA.row - positionB.row
+	xDistance := positionA.x - positionB.x
+	if xDistance < 0 {
+		xDistance = -xDistance
+	}
+	switch rowDistance {
+	case 0:
+		return xDistance <= 1
+	case 1, -1:
+		return xDistance <= 0.75
+	default:
+		return false
+	}
+}
+
+// Distance returns the weighted optimal string alignment distance between `a` and `b`, a Damerau-Levenshtein
+// variant where substituting keyboard neighbours and transposing characters cost less than other edits.
+// Strings are compared byte by byte, so it's meant for ASCII input like domain names
+func Distance(a, b string) float64 {
+	// distances[i][j] holds the distance between a[:i] and b[:j]
+	distances := make([][]float64, len(a)+1)
+	for i := range distances {
+		distances[i] = make([]float64, len(b)+1)
+		distances[i][0] = float64(i) * deletionCost
+	}
+	for j := range distances[0] {
+		distances[0][j] = float64(j) * insertionCost
+	}
+
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			cost := 0.0
+			if a[i-1] != b[j-1] {
+				cost = substitutionCost
+				if Adjacent(a[i-1], b[j-1]) {
+					cost = adjacentSubstitutionCost
+				}
+			}
+			distance := distances[i-1][j-1] + cost
+			if deletion := distances[i-1][j] + deletionCost; deletion < distance {
+				distance = deletion
+			}
+			if insertion := distances[i][j-1] + insertionCost; insertion < distance {
+				distance = insertion
+			}
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] && a[i-1] != a[i-2] {
+				if transposition := distances[i-2][j-2] + transpositionCost; transposition < distance {
+					distance = transposition
+				}
+			}
+			distances[i][j] = distance
+		}
+	}
+	return distances[len(a)][len(b)]
+}
@@ -0,0 +1,52 @@
+package keyboardDistance
+
+import "testing"
+
+func TestAdjacent(t *testing.T) {
+	tests := []struct {
+		a, b byte
+		want bool
+	}{
+		{a: 'g', b: 'h', want: true},
+		{a: 'g', b: 't', want: true},
+		{a: 'g', b: 'y', want: true},
+		{a: 'g', b: 'b', want: true},
+		{a: 'g', b: 'v', want: true},
+		{a: 'g', b: 'c', want: false},
+		{a: 'g', b: 'g', want: false},
+		{a: 'q', b: 'p', want: false},
+		{a: 'a', b: 'q', want: true},
+		{a: '.', b: 'm', want: false},
+	}
+	for _, tt := range tests {
+		t.Run(string([]byte{tt.a, tt.b}), func(t *testing.T) {
+			if got := Adjacent(tt.a, tt.b); got != tt.want {
+				t.Errorf("Adjacent() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDistance(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want float64
+	}{
+		{name: "equal", a: "gmail.com", b: "gmail.com", want: 0},
+		{name: "empty", a: "", b: "abc", want: 3},
+		{name: "transposition", a: "gmial.com", b: "gmail.com", want: 0.5},
+		{name: "adjacent substitution", a: "gmaik.com", b: "gmail.com", want: 0.5},
+		{name: "distant substitution", a: "gmaiz.com", b: "gmail.com", want: 1},
+		{name: "deletion", a: "yaho.com", b: "yahoo.com", want: 1},
+		{name: "insertion", a: "gmaill.com", b: "gmail.com", want: 1},
+		{name: "several edits", a: "hotmial.co", b: "hotmail.com", want: 1.5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Distance(tt.a, tt.b); got != tt.want {
+				t.Errorf("Distance() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
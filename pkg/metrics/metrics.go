@@ -0,0 +1,275 @@
+// Package metrics keeps counters and histograms, optionally split by labels, and writes them in the Prometheus text
+// exposition format so they can be scraped over HTTP or dumped to a file
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ContentType is the content type of the text exposition format written by Registry.WriteText
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// family is a metric along with every labelled series of it
+type family interface {
+	writeText(w *bufio.Writer)
+}
+
+// Registry holds metrics by name, its zero value isn't usable, see NewRegistry
+type Registry struct {
+	mutex    sync.Mutex
+	families map[string]family
+}
+
+// NewRegistry Constructor for an empty Registry
+func NewRegistry() *Registry {
+	return &Registry{families: make(map[string]family)}
+}
+
+// register adds a metric to the registry, panicking when its name is already taken as that's a programming error
+func (r *Registry) register(name string, metric family) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if _, taken := r.families[name]; taken {
+		panic(fmt.Sprintf("metric %s registered twice", name))
+	}
+	r.families[name] = metric
+}
+
+// WriteText writes every metric in the registry, sorted by name, in the Prometheus text exposition format
+func (r *Registry) WriteText(w io.Writer) error {
+	r.mutex.Lock()
+	names := make([]string, 0, len(r.families))
+	for name := range r.families {
+		names = append(names, name)
+	}
+	families := make([]family, len(names))
+	sort.Strings(names)
+	for i, name := range names {
+		families[i] = r.families[name]
+	}
+	r.mutex.Unlock()
+
+	buffered := bufio.NewWriter(w)
+	for _, metric := range families {
+		metric.writeText(buffered)
+	}
+	return buffered.Flush()
+}
+
+// ServeHTTP answers with the metrics in the registry, as WriteText writes them
+func (r *Registry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", ContentType)
+	_ = r.WriteText(w)
+}
+
+// metricInfo is what every kind of metric has in common
+type metricInfo struct {
+	name   string
+	help   string
+	labels []string
+}
+
+// key joins label values into the key of their series, values are checked against the label names as passing the
+// wrong number of them is a programming error
+func (m *metricInfo) key(labelValues []string) string {
+	if len(labelValues) != len(m.labels) {
+		panic(fmt.Sprintf("metric %s takes %d label values, not %d", m.name, len(m.labels), len(labelValues)))
+	}
+	return strings.Join(labelValues, "\xff")
+}
+
+func (m *metricInfo) writeHeader(w *bufio.Writer, kind string) {
+	help := strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(m.help)
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", m.name, help, m.name, kind)
+}
+
+// labelPairs renders the labels of a series, along with an extra one when `extraName` is set, as `{name="value"}`
+func (m *metricInfo) labelPairs(key string, extraName, extraValue string) string {
+	var pairs []string
+	if len(m.labels) > 0 {
+		values := strings.Split(key, "\xff")
+		for i, label := range m.labels {
+			pairs = append(pairs, label+"="+quoteLabelValue(values[i]))
+		}
+	}
+	if extraName != "" {
+		pairs = append(pairs, extraName+"="+quoteLabelValue(extraValue))
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func quoteLabelValue(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value) + `"`
+}
+
+func formatValue(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// Counter is a value which only goes up, like the number of rows read
+type Counter struct {
+	metricInfo
+	mutex  sync.Mutex
+	values map[string]float64
+}
+
+// NewCounter registers a counter named `name` with a series for every combination of values of `labels`. A counter
+// without labels starts at zero, labelled ones appear once added to
+func (r *Registry) NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{metricInfo: metricInfo{name: name, help: help, labels: labels}, values: make(map[string]float64)}
+	if len(labels) == 0 {
+		c.values[""] = 0
+	}
+	r.register(name, c)
+	return c
+}
+
+// Add adds `value`, which can't be negative, to the series of the counter with `labelValues`
+func (c *Counter) Add(value float64, labelValues ...string) {
+	if value < 0 {
+		panic(fmt.Sprintf("counter %s can't go down", c.name))
+	}
+	key := c.key(labelValues)
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.values[key] += value
+}
+
+// Value returns the value of the series of the counter with `labelValues`
+func (c *Counter) Value(labelValues ...string) float64 {
+	key := c.key(labelValues)
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.values[key]
+}
+
+func (c *Counter) writeText(w *bufio.Writer) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.writeHeader(w, "counter")
+	// Series are sorted so they are always written in the same order
+	keys := make([]string, 0, len(c.values))
+	for key := range c.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Fprintf(w, "%s%s %s\n", c.name, c.labelPairs(key, "", ""), formatValue(c.values[key]))
+	}
+}
+
+// histogramSeries holds the observations of a series of a histogram, `counts` has one more bucket than the
+// histogram bounds for the observations above them all
+type histogramSeries struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// Histogram counts observations, like durations, in buckets with an upper bound each
+type Histogram struct {
+	metricInfo
+	buckets []float64
+	mutex   sync.Mutex
+	series  map[string]*histogramSeries
+}
+
+// NewHistogram registers a histogram named `name` counting observations in `buckets`, upper bounds sorted in
+// increasing order, with a series for every combination of values of `labels`
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	if !sort.Float64sAreSorted(buckets) {
+		panic(fmt.Sprintf("buckets of histogram %s aren't sorted", name))
+	}
+	h := &Histogram{
+		metricInfo: metricInfo{name: name, help: help, labels: labels},
+		buckets:    buckets,
+		series:     make(map[string]*histogramSeries),
+	}
+	if len(labels) == 0 {
+		h.series[""] = &histogramSeries{counts: make([]uint64, len(buckets)+1)}
+	}
+	r.register(name, h)
+	return h
+}
+
+// Observe adds `value` to the series of the histogram with `labelValues`
+func (h *Histogram) Observe(value float64, labelValues ...string) {
+	key := h.key(labelValues)
+	bucket := sort.SearchFloat64s(h.buckets, value)
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	series, found := h.series[key]
+	if !found {
+		series = &histogramSeries{counts: make([]uint64, len(h.buckets)+1)}
+		h.series[key] = series
+	}
+	series.counts[bucket]++
+	series.sum += value
+	series.count++
+}
+
+// Count returns the number of observations of the series of the histogram with `labelValues`
+func (h *Histogram) Count(labelValues ...string) uint64 {
+	key := h.key(labelValues)
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if series, found := h.series[key]; found {
+		return series.count
+	}
+	return 0
+}
+
+func (h *Histogram) writeText(w *bufio.Writer) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.writeHeader(w, "histogram")
+	keys := make([]string, 0, len(h.series))
+	for key := range h.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		series := h.series[key]
+		// Buckets are cumulative in the exposition format
+		var cumulative uint64
+		for i, count := range series.counts {
+			cumulative += count
+			bound := math.Inf(1)
+			if i < len(h.buckets) {
+				bound = h.buckets[i]
+			}
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.labelPairs(key, "le", formatValue(bound)), cumulative)
+		}
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, h.labelPairs(key, "", ""), formatValue(series.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, h.labelPairs(key, "", ""), series.count)
+	}
+}
+
+// ExponentialBuckets returns `count` bucket bounds starting at `start`, each one `factor` times the one before it
+func ExponentialBuckets(start, factor float64, count int) []float64 {
+	buckets := make([]float64, count)
+	for i := range buckets {
+		buckets[i] = start
+		start *= factor
+	}
+	return buckets
+}
@@ -0,0 +1,106 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestRegistry_WriteText(t *testing.T) {
+	registry := NewRegistry()
+	rows := registry.NewCounter("rows_total", "Rows read.")
+	rejected := registry.NewCounter("rejected_total", "Rows rejected,\nby reason.", "reason")
+	durations := registry.NewHistogram("duration_seconds", "How long it took.", []float64{0.1, 1}, "step")
+	registry.NewHistogram("unused_seconds", "Never observed.", []float64{1})
+
+	rows.Add(3)
+	rows.Add(2)
+	rejected.Add(1, "invalid_email")
+	rejected.Add(2, `bad "quote"`)
+	durations.Observe(0.1, "sort")
+	durations.Observe(0.5, "sort")
+	durations.Observe(3, "sort")
+
+	var got bytes.Buffer
+	if err := registry.WriteText(&got); err != nil {
+		t.Fatal(err)
+	}
+	want := "# HELP duration_seconds How long it took.\n" +
+		"# TYPE duration_seconds histogram\n" +
+		"duration_seconds_bucket{step=\"sort\",le=\"0.1\"} 1\n" +
+		"duration_seconds_bucket{step=\"sort\",le=\"1\"} 2\n" +
+		"duration_seconds_bucket{step=\"sort\",le=\"+Inf\"} 3\n" +
+		"duration_seconds_sum{step=\"sort\"} 3.6\n" +
+		"duration_seconds_count{step=\"sort\"} 3\n" +
+		"# HELP rejected_total Rows rejected,\\nby reason.\n" +
+		"# TYPE rejected_total counter\n" +
+		"rejected_total{reason=\"bad \\\"quote\\\"\"} 2\n" +
+		"rejected_total{reason=\"invalid_email\"} 1\n" +
+		"# HELP rows_total Rows read.\n" +
+		"# TYPE rows_total counter\n" +
+		"rows_total 5\n" +
+		"# HELP unused_seconds Never observed.\n" +
+		"# TYPE unused_seconds histogram\n" +
+		"unused_seconds_bucket{le=\"1\"} 0\n" +
+		"unused_seconds_bucket{le=\"+Inf\"} 0\n" +
+		"unused_seconds_sum 0\n" +
+		"unused_seconds_count 0\n"
+	if got.String() != want {
+		t.Errorf("WriteText() = %q, want %q", got.String(), want)
+	}
+	if rows.Value() != 5 || rejected.Value("invalid_email") != 1 || durations.Count("sort") != 3 || durations.Count("merge") != 0 {
+		t.Errorf("Value() and Count() don't match the observations")
+	}
+}
+
+func TestRegistry_ServeHTTP(t *testing.T) {
+	registry := NewRegistry()
+	registry.NewCounter("rows_total", "Rows read.").Add(1)
+	recorder := httptest.NewRecorder()
+	registry.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if recorder.Header().Get("Content-Type") != ContentType {
+		t.Errorf("content type = %q, want %q", recorder.Header().Get("Content-Type"), ContentType)
+	}
+	if want := "# HELP rows_total Rows read.\n# TYPE rows_total counter\nrows_total 1\n"; recorder.Body.String() != want {
+		t.Errorf("body = %q, want %q", recorder.Body.String(), want)
+	}
+}
+
+func TestRegistry_misuse(t *testing.T) {
+	tests := []struct {
+		name string
+		fn   func(registry *Registry)
+	}{
+		{name: "name taken", fn: func(registry *Registry) {
+			registry.NewCounter("rows_total", "")
+			registry.NewHistogram("rows_total", "", nil)
+		}},
+		{name: "missing label value", fn: func(registry *Registry) {
+			registry.NewCounter("rejected_total", "", "reason").Add(1)
+		}},
+		{name: "negative counter", fn: func(registry *Registry) {
+			registry.NewCounter("rows_total", "").Add(-1)
+		}},
+		{name: "unsorted buckets", fn: func(registry *Registry) {
+			registry.NewHistogram("duration_seconds", "", []float64{1, 0.1})
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("want a panic")
+				}
+			}()
+			tt.fn(NewRegistry())
+		})
+	}
+}
+
+func TestExponentialBuckets(t *testing.T) {
+	if got, want := ExponentialBuckets(1, 10, 4), []float64{1, 10, 100, 1000}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ExponentialBuckets() = %v, want %v", got, want)
+	}
+}
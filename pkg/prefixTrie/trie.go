@@ -0,0 +1,129 @@
+// Package prefixTrie counts IP addresses by network prefix using a binary trie, where every node holds the number
+// of addresses added below it so any prefix shorter than the one used to add them can be queried as well
+package prefixTrie
+
+import (
+	"net"
+)
+
+type trieNode struct {
+	children [2]*trieNode
+	count    int
+}
+
+// prefixTrie is a binary trie for addresses of a single family, IPv4 or IPv6
+type prefixTrie struct {
+	root *trieNode
+	bits int
+}
+
+// NewIPv4PrefixTrie Constructor for a prefixTrie holding IPv4 addresses
+func NewIPv4PrefixTrie() *prefixTrie {
+	return &prefixTrie{root: &trieNode{}, bits: net.IPv4len * 8}
+}
+
+// NewIPv6PrefixTrie Constructor for a prefixTrie holding IPv6 addresses
+func NewIPv6PrefixTrie() *prefixTrie {
+	return &prefixTrie{root: &trieNode{}, bits: net.IPv6len * 8}
+}
+
+// Bits returns the length in bits of the addresses held by the trie
+func (t *prefixTrie) Bits() int {
+	return t.bits
+}
+
+// normalize returns `ip` with the byte length of the trie family, or nil if it belongs to the other family
+func (t *prefixTrie) normalize(ip net.IP) net.IP {
+	if t.bits == net.IPv4len*8 {
+		return ip.To4()
+	}
+	if ip.To4() != nil {
+		return nil
+	}
+	return ip.To16()
+}
+
+// Add counts `ip` in every prefix of it up to `prefixLength` bits, anything longer than that is discarded to save
+// memory. It returns false if `ip` doesn't belong to the trie family
+func (t *prefixTrie) Add(ip net.IP, prefixLength int) bool {
+	ip = t.normalize(ip)
+	if ip == nil {
+		return false
+	}
+	if prefixLength > t.bits {
+		prefixLength = t.bits
+	}
+
+	node := t.root
+	node.count++
+	for bit := 0; bit < prefixLength; bit++ {
+		side := bitAt(ip, bit)
+		if node.children[side] == nil {
+			node.children[side] = &trieNode{}
+		}
+		node = node.children[side]
+		node.count++
+	}
+	return true
+}
+
+// Count returns how many addresses were added within `network`
+func (t *prefixTrie) Count(network *net.IPNet) int {
+	ip := t.normalize(network.IP)
+	if ip == nil {
+		return 0
+	}
+	prefixLength, _ := network.Mask.Size()
+
+	node := t.root
+	for bit := 0; bit < prefixLength && node != nil; bit++ {
+		node = node.children[bitAt(ip, bit)]
+	}
+	if node == nil {
+		return 0
+	}
+	return node.count
+}
+
+// Walk calls `fn` for every network of `prefixLength` bits holding any addresses, in ascending numeric order.
+// Addresses added with a shorter prefix length than `prefixLength` are not visited
+func (t *prefixTrie) Walk(prefixLength int, fn func(network *net.IPNet, count int)) {
+	if prefixLength > t.bits {
+		prefixLength = t.bits
+	}
+	ip := make(net.IP, t.bits/8)
+	mask := net.CIDRMask(prefixLength, t.bits)
+
+	var walk func(node *trieNode, depth int)
+	walk = func(node *trieNode, depth int) {
+		if depth == prefixLength {
+			network := &net.IPNet{IP: make(net.IP, len(ip)), Mask: mask}
+			copy(network.IP, ip)
+			fn(network, node.count)
+			return
+		}
+		for side, child := range node.children {
+			if child == nil {
+				continue
+			}
+			setBit(ip, depth, side)
+			walk(child, depth+1)
+		}
+		setBit(ip, depth, 0)
+	}
+	if t.root.count > 0 {
+		walk(t.root, 0)
+	}
+}
+
+func bitAt(ip net.IP, bit int) int {
+	return int(ip[bit/8]>>(7-uint(bit%8))) & 1
+}
+
+func setBit(ip net.IP, bit int, value int) {
+	if value == 1 {
+		ip[bit/8] |= 1 << (7 - uint(bit%8))
+	} else {
+		ip[bit/8] &^= 1 << (7 - uint(bit%8))
+	}
+}
@@ -0,0 +1,99 @@
+package prefixTrie
+
+import (
+	"net"
+	"reflect"
+	"testing"
+)
+
+func Test_prefixTrie_Walk(t *testing.T) {
+	tests := []struct {
+		name         string
+		trie         *prefixTrie
+		ips          []string
+		addLength    int
+		walkLength   int
+		wantNetworks []string
+		wantCounts   []int
+	}{
+		{
+			name:         "ipv4 /24",
+			trie:         NewIPv4PrefixTrie(),
+			ips:          []string{"38.194.51.128", "10.0.0.1", "38.194.51.1", "38.194.7.217", "2001:db8::1"},
+			addLength:    24,
+			walkLength:   24,
+			wantNetworks: []string{"10.0.0.0/24", "38.194.7.0/24", "38.194.51.0/24"},
+			wantCounts:   []int{1, 1, 2},
+		},
+		{
+			name:         "ipv4 rolled up to /8",
+			trie:         NewIPv4PrefixTrie(),
+			ips:          []string{"38.194.51.128", "10.0.0.1", "38.194.51.1", "38.1.7.217"},
+			addLength:    24,
+			walkLength:   8,
+			wantNetworks: []string{"10.0.0.0/8", "38.0.0.0/8"},
+			wantCounts:   []int{1, 3},
+		},
+		{
+			name:         "ipv6 /48",
+			trie:         NewIPv6PrefixTrie(),
+			ips:          []string{"2001:db8:85a3::1", "2001:db8:85a3:1::1", "2001:db8:1::1", "10.0.0.1"},
+			addLength:    48,
+			walkLength:   48,
+			wantNetworks: []string{"2001:db8:1::/48", "2001:db8:85a3::/48"},
+			wantCounts:   []int{1, 2},
+		},
+		{
+			name:         "empty",
+			trie:         NewIPv4PrefixTrie(),
+			addLength:    16,
+			walkLength:   16,
+			wantNetworks: nil,
+			wantCounts:   nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, ip := range tt.ips {
+				tt.trie.Add(net.ParseIP(ip), tt.addLength)
+			}
+			var gotNetworks []string
+			var gotCounts []int
+			tt.trie.Walk(tt.walkLength, func(network *net.IPNet, count int) {
+				gotNetworks = append(gotNetworks, network.String())
+				gotCounts = append(gotCounts, count)
+			})
+			if !reflect.DeepEqual(gotNetworks, tt.wantNetworks) {
+				t.Errorf("Walk() networks = %v, want %v", gotNetworks, tt.wantNetworks)
+			}
+			if !reflect.DeepEqual(gotCounts, tt.wantCounts) {
+				t.Errorf("Walk() counts = %v, want %v", gotCounts, tt.wantCounts)
+			}
+		})
+	}
+}
+
+func Test_prefixTrie_Count(t *testing.T) {
+	trie := NewIPv4PrefixTrie()
+	for _, ip := range []string{"38.194.51.128", "38.194.51.1", "38.1.7.217"} {
+		trie.Add(net.ParseIP(ip), 32)
+	}
+	tests := []struct {
+		network string
+		want    int
+	}{
+		{network: "0.0.0.0/0", want: 3},
+		{network: "38.0.0.0/8", want: 3},
+		{network: "38.194.0.0/16", want: 2},
+		{network: "38.194.51.1/32", want: 1},
+		{network: "10.0.0.0/8", want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.network, func(t *testing.T) {
+			_, network, _ := net.ParseCIDR(tt.network)
+			if got := trie.Count(network); got != tt.want {
+				t.Errorf("Count() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package radixSorter
+
+import "strings"
+
+// StableOrder returns the indexes of `keys` in ascending order of their key, indexes of equal keys keep their
+// relative order. It's a least significant digit radix sort, one byte at a time, which skips the bytes all keys
+// share, so small keys only take a couple passes
+func StableOrder(keys []uint64) (order []int) {
+	order = make([]int, len(keys))
+	for i := range order {
+		order[i] = i
+	}
+	if len(keys) < 2 {
+		return
+	}
+	buffer := make([]int, len(keys))
+
+	for shift := uint(0); shift < 64; shift += 8 {
+		var counts [256]int
+		for _, key := range keys {
+			counts[byte(key>>shift)]++
+		}
+		if counts[byte(keys[0]>>shift)] == len(keys) {
+			// Every key has the same byte here, this pass wouldn't change the order
+			continue
+		}
+
+		// Turn counts into the position where each byte bucket starts
+		position := 0
+		for b, count := range counts {
+			counts[b] = position
+			position += count
+		}
+		for _, index := range order {
+			b := byte(keys[index] >> shift)
+			buffer[counts[b]] = index
+			counts[b]++
+		}
+		order, buffer = buffer, order
+	}
+	return
+}
+
+// StringOrder returns the indexes of `strs` in alphabetical order ignoring case, like the radix sorter sorts them.
+// The radix sorter drops duplicates and empty strings, here strings equal ignoring case keep their relative order and
+// empty strings go first
+func StringOrder(strs []string) (order []int) {
+	order = make([]int, 0, len(strs))
+	sorter := NewRadixSorter()
+	indexesByKey := make(map[string][]int)
+	for i, str := range strs {
+		if str == "" {
+			order = append(order, i)
+			continue
+		}
+		key := strings.ToLower(str)
+		if _, exists := indexesByKey[key]; !exists {
+			sorter.Add(key)
+		}
+		indexesByKey[key] = append(indexesByKey[key], i)
+	}
+	for _, key := range sorter.Sort() {
+		order = append(order, indexesByKey[key]...)
+	}
+	return
+}
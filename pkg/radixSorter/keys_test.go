@@ -0,0 +1,108 @@
+package radixSorter
+
+import (
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestStableOrder(t *testing.T) {
+	tests := []struct {
+		name      string
+		keys      []uint64
+		wantOrder []int
+	}{
+		{
+			name:      "empty",
+			keys:      []uint64{},
+			wantOrder: []int{},
+		},
+		{
+			name:      "single",
+			keys:      []uint64{42},
+			wantOrder: []int{0},
+		},
+		{
+			name:      "small keys",
+			keys:      []uint64{3, 1, 2},
+			wantOrder: []int{1, 2, 0},
+		},
+		{
+			name:      "stable",
+			keys:      []uint64{2, 1, 2, 1, 0},
+			wantOrder: []int{4, 1, 3, 0, 2},
+		},
+		{
+			name:      "wide keys",
+			keys:      []uint64{1 << 40, 1 << 8, ^uint64(0), 1},
+			wantOrder: []int{3, 1, 0, 2},
+		},
+		{
+			name:      "complemented keys sort descending",
+			keys:      []uint64{^uint64(5), ^uint64(300), ^uint64(5), ^uint64(1)},
+			wantOrder: []int{1, 0, 2, 3},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if gotOrder := StableOrder(tt.keys); !reflect.DeepEqual(gotOrder, tt.wantOrder) {
+				t.Errorf("StableOrder() = %v, want %v", gotOrder, tt.wantOrder)
+			}
+		})
+	}
+}
+
+func TestStableOrder_matchesSortStable(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	keys := make([]uint64, 10000)
+	for i := range keys {
+		keys[i] = uint64(random.Intn(5000)) << uint(random.Intn(40))
+	}
+	want := make([]int, len(keys))
+	for i := range want {
+		want[i] = i
+	}
+	sort.SliceStable(want, func(i, j int) bool {
+		return keys[want[i]] < keys[want[j]]
+	})
+	if got := StableOrder(keys); !reflect.DeepEqual(got, want) {
+		t.Errorf("StableOrder() doesn't match sort.SliceStable")
+	}
+}
+
+func TestStringOrder(t *testing.T) {
+	tests := []struct {
+		name      string
+		strs      []string
+		wantOrder []int
+	}{
+		{
+			name:      "empty",
+			strs:      nil,
+			wantOrder: []int{},
+		},
+		{
+			name:      "alphabetical",
+			strs:      []string{"pear", "apple", "fig"},
+			wantOrder: []int{1, 2, 0},
+		},
+		{
+			name:      "ignoring case",
+			strs:      []string{"b", "A", "a", "B", "a"},
+			wantOrder: []int{1, 2, 4, 0, 3},
+		},
+		{
+			name:      "empty strings and prefixes",
+			strs:      []string{"abc", "", "ab", "", "abd"},
+			wantOrder: []int{1, 3, 2, 0, 4},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if gotOrder := StringOrder(tt.strs); !reflect.DeepEqual(gotOrder, tt.wantOrder) {
+				t.Errorf("StringOrder() = %v, want %v", gotOrder, tt.wantOrder)
+			}
+		})
+	}
+}
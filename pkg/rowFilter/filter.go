@@ -0,0 +1,236 @@
+// Package rowFilter implements a small expression language to select rows by the values of their named columns.
+//
+// Comparisons take the form `column operator operand`, operands being quoted strings, numbers or lists of them:
+//
+//	gender == "Female"                    equality, also !=
+//	age >= 18                             ordering (<, <=, >, >=), numeric when the operand is a number
+//	gender in ("Female", "Male")          membership, also `not in`
+//	email =~ "\.org$"                     regular expression match, also !~
+//	ip_address within "10.0.0.0/8"        CIDR containment, accepts a list too, also `not within`
+//
+// Comparisons can be combined with `and`, `or`, `not` (or `&&`, `||`, `!`) and parentheses.
+// Columns with spaces or named like a keyword can be quoted with backticks.
+package rowFilter
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Expression is a parsed filter, it must be bound to the headers of the data source with Bind before matching rows
+type Expression struct {
+	source  string
+	root    node
+	columns []string
+}
+
+// Parse parses `source` into an Expression, syntax errors are returned as a *ParseError
+func Parse(source string) (*Expression, error) {
+	tokens, err := lex(source)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 1 {
+		return nil, newParseError(source, 0, "empty expression")
+	}
+	p := &parser{source: source, tokens: tokens}
+	root, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+	if trailing := p.peek(); trailing.kind != tokenEOF {
+		return nil, p.errorAt(trailing, "unexpected "+trailing.describe())
+	}
+	return &Expression{source: source, root: root, columns: p.columns}, nil
+}
+
+// String returns the source of the expression
+func (e *Expression) String() string {
+	return e.source
+}
+
+// Columns returns the names of the columns referenced by the expression
+func (e *Expression) Columns() []string {
+	return append([]string(nil), e.columns...)
+}
+
+// Bind resolves the columns referenced by the expression against `headers`
+func (e *Expression) Bind(headers []string) (*Matcher, error) {
+	indexes := make([]int, len(e.columns))
+	for slot, column := range e.columns {
+		indexes[slot] = -1
+		for i, header := range headers {
+			if header == column {
+				indexes[slot] = i
+				break
+			}
+		}
+		if indexes[slot] == -1 {
+			return nil, UnknownColumnError{Column: column, Headers: headers}
+		}
+	}
+	return &Matcher{root: e.root, indexes: indexes}, nil
+}
+
+// Matcher is an Expression bound to a set of headers
+type Matcher struct {
+	root    node
+	indexes []int
+}
+
+// Match evaluates the expression against `row`, which must follow the headers the Matcher was bound to.
+// Missing fields are treated as empty strings
+func (m *Matcher) Match(row []string) bool {
+	return m.root.match(row, m.indexes)
+}
+
+type node interface {
+	match(row []string, indexes []int) bool
+}
+
+type andNode struct {
+	left, right node
+}
+
+func (n andNode) match(row []string, indexes []int) bool {
+	return n.left.match(row, indexes) && n.right.match(row, indexes)
+}
+
+type orNode struct {
+	left, right node
+}
+
+func (n orNode) match(row []string, indexes []int) bool {
+	return n.left.match(row, indexes) || n.right.match(row, indexes)
+}
+
+type notNode struct {
+	operand node
+}
+
+func (n notNode) match(row []string, indexes []int) bool {
+	return !n.operand.match(row, indexes)
+}
+
+type literal struct {
+	text     string
+	number   float64
+	isNumber bool
+}
+
+// compare returns -1, 0 or 1 comparing `value` to the literal, numeric literals can only be compared to numeric values
+func (l literal) compare(value string) (result int, comparable bool) {
+	if !l.isNumber {
+		return strings.Compare(value, l.text), true
+	}
+	number, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil {
+		return 0, false
+	}
+	switch {
+	case number < l.number:
+		return -1, true
+	case number > l.number:
+		return 1, true
+	default:
+		return 0, true
+	}
+}
+
+type comparisonNode struct {
+	slot     int
+	operator string
+	negated  bool
+	literals []literal
+	regex    *regexp.Regexp
+	networks []*net.IPNet
+}
+
+func (n *comparisonNode) match(row []string, indexes []int) bool {
+	var value string
+	if index := indexes[n.slot]; index < len(row) {
+		value = row[index]
+	}
+
+	switch n.operator {
+	case "=~":
+		return n.regex.MatchString(value)
+	case "!~":
+		return !n.regex.MatchString(value)
+	case "in":
+		return n.in(value) != n.negated
+	case "within":
+		return n.within(value) != n.negated
+	}
+
+	result, comparable := n.literals[0].compare(value)
+	switch n.operator {
+	case "==":
+		return comparable && result == 0
+	case "!=":
+		return !comparable || result != 0
+	case "<":
+		return comparable && result < 0
+	case "<=":
+		return comparable && result <= 0
+	case ">":
+		return comparable && result > 0
+	case ">=":
+		return comparable && result >= 0
+	}
+	panic("This should never happen, unknown operator " + n.operator)
+}
+
+func (n *comparisonNode) in(value string) bool {
+	for _, l := range n.literals {
+		if result, comparable := l.compare(value); comparable && result == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (n *comparisonNode) within(value string) bool {
+	ip := net.ParseIP(strings.TrimSpace(value))
+	if ip == nil {
+		return false
+	}
+	for _, network := range n.networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseError describes a syntax error in a filter expression, Offset being the byte position in Expression
+type ParseError struct {
+	Expression string
+	Offset     int
+	Message    string
+}
+
+func newParseError(expression string, offset int, message string) *ParseError {
+	return &ParseError{Expression: expression, Offset: offset, Message: message}
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("invalid filter at column %d: %s", e.Offset+1, e.Message)
+}
+
+// Pointer returns the expression followed by a line pointing to the error position, for displaying on a terminal
+func (e *ParseError) Pointer() string {
+	return e.Expression + "\n" + strings.Repeat(" ", len([]rune(e.Expression[:e.Offset]))) + "^"
+}
+
+type UnknownColumnError struct {
+	Column  string
+	Headers []string
+}
+
+func (e UnknownColumnError) Error() string {
+	return fmt.Sprintf("filter references column \"%s\" which is not in %v", e.Column, e.Headers)
+}
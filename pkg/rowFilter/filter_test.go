@@ -0,0 +1,121 @@
+package rowFilter
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+var testHeaders = []string{"first_name", "email", "gender", "age", "ip_address"}
+
+func TestParse_errors(t *testing.T) {
+	tests := []struct {
+		name       string
+		source     string
+		wantOffset int
+	}{
+		{name: "empty", source: "  ", wantOffset: 0},
+		{name: "missing operand", source: "gender == ", wantOffset: 10},
+		{name: "missing operator", source: "gender \"Female\"", wantOffset: 7},
+		{name: "unterminated string", source: "gender == \"Female", wantOffset: 10},
+		{name: "unbalanced parens", source: "(gender == \"Female\"", wantOffset: 19},
+		{name: "trailing tokens", source: "gender == \"Female\" \"Male\"", wantOffset: 19},
+		{name: "invalid regex", source: "email =~ \"(\"", wantOffset: 9},
+		{name: "invalid cidr", source: "ip_address within \"10.0.0.0/33\"", wantOffset: 18},
+		{name: "unknown operator", source: "age = 18", wantOffset: 4},
+		{name: "dangling not", source: "gender not \"Female\"", wantOffset: 11},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.source)
+			var parseError *ParseError
+			if !errors.As(err, &parseError) {
+				t.Fatalf("Parse() error = %v, want a *ParseError", err)
+			}
+			if parseError.Offset != tt.wantOffset {
+				t.Errorf("Parse() error offset = %d, want %d (%s)", parseError.Offset, tt.wantOffset, parseError)
+			}
+		})
+	}
+}
+
+func TestExpression_Columns(t *testing.T) {
+	expression, err := Parse("gender == \"Female\" or (`age` > 18 and gender != \"Male\")")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := expression.Columns(), []string{"gender", "age"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Columns() = %v, want %v", got, want)
+	}
+}
+
+func TestExpression_Bind(t *testing.T) {
+	expression, err := Parse("quux == \"foo\"")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = expression.Bind(testHeaders)
+	var unknownColumnError UnknownColumnError
+	if !errors.As(err, &unknownColumnError) || unknownColumnError.Column != "quux" {
+		t.Errorf("Bind() error = %v, want UnknownColumnError for quux", err)
+	}
+}
+
+func TestMatcher_Match(t *testing.T) {
+	row := []string{"Norma", "nallen8@cnet.com", "Female", "42", "168.67.162.1"}
+	tests := []struct {
+		name   string
+		source string
+		want   bool
+	}{
+		{name: "equal", source: "gender == \"Female\"", want: true},
+		{name: "equal is case sensitive", source: "gender == \"female\"", want: false},
+		{name: "not equal", source: "gender != 'Male'", want: true},
+		{name: "numeric greater", source: "age > 9", want: true},
+		{name: "string greater", source: "age > \"9\"", want: false},
+		{name: "numeric equal", source: "age == 42.0", want: true},
+		{name: "numeric on non numeric", source: "first_name >= 0", want: false},
+		{name: "in", source: "gender in (\"Male\", \"Female\")", want: true},
+		{name: "not in", source: "gender not in (\"Male\", \"Female\")", want: false},
+		{name: "regex", source: "email =~ \"\\.com$\"", want: true},
+		{name: "negated regex", source: "email !~ \"^n\"", want: false},
+		{name: "within", source: "ip_address within \"168.67.0.0/16\"", want: true},
+		{name: "within list", source: "ip_address within (\"10.0.0.0/8\", \"192.168.0.0/16\")", want: false},
+		{name: "not within", source: "ip_address not within \"10.0.0.0/8\"", want: true},
+		{name: "and", source: "gender == \"Female\" and age < 30", want: false},
+		{name: "or", source: "gender == \"Male\" || age >= 42", want: true},
+		{name: "not", source: "not gender == \"Male\"", want: true},
+		{name: "precedence", source: "gender == \"Male\" and age > 100 or first_name == \"Norma\"", want: true},
+		{name: "parens", source: "gender == \"Male\" and (age > 100 or first_name == \"Norma\")", want: false},
+		{name: "keywords are case insensitive", source: "NOT gender == \"Male\" AND age > 1", want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expression, err := Parse(tt.source)
+			if err != nil {
+				t.Fatal(err)
+			}
+			matcher, err := expression.Bind(testHeaders)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := matcher.Match(row); got != tt.want {
+				t.Errorf("Match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatcher_Match_shortRow(t *testing.T) {
+	expression, err := Parse("ip_address == \"\"")
+	if err != nil {
+		t.Fatal(err)
+	}
+	matcher, err := expression.Bind(testHeaders)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !matcher.Match([]string{"Norma"}) {
+		t.Errorf("Match() = false, want missing fields to be empty")
+	}
+}
@@ -0,0 +1,152 @@
+package rowFilter
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdentifier
+	tokenString
+	tokenNumber
+	tokenOperator
+	tokenLeftParen
+	tokenRightParen
+	tokenComma
+)
+
+type token struct {
+	kind tokenKind
+	// text holds the unquoted value for strings, and the source text for everything else
+	text   string
+	offset int
+}
+
+// describe returns a human friendly representation of the token, to be used in error messages
+func (t token) describe() string {
+	switch t.kind {
+	case tokenEOF:
+		return "end of expression"
+	case tokenString:
+		return "string \"" + t.text + "\""
+	default:
+		return "\"" + t.text + "\""
+	}
+}
+
+// keywords are lexed as operators instead of identifiers, columns with these names must be quoted using backticks
+var keywords = map[string]string{
+	"and":    "and",
+	"or":     "or",
+	"not":    "not",
+	"in":     "in",
+	"within": "within",
+}
+
+// lex splits `source` into tokens, it always ends with a tokenEOF
+func lex(source string) (tokens []token, err error) {
+	offset := 0
+	for offset < len(source) {
+		char, width := utf8.DecodeRuneInString(source[offset:])
+		switch {
+		case unicode.IsSpace(char):
+			offset += width
+		case char == '(':
+			tokens = append(tokens, token{kind: tokenLeftParen, text: "(", offset: offset})
+			offset++
+		case char == ')':
+			tokens = append(tokens, token{kind: tokenRightParen, text: ")", offset: offset})
+			offset++
+		case char == ',':
+			tokens = append(tokens, token{kind: tokenComma, text: ",", offset: offset})
+			offset++
+		case char == '"' || char == '\'':
+			text, end, err := lexString(source, offset)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{kind: tokenString, text: text, offset: offset})
+			offset = end
+		case char == '`':
+			end := strings.IndexByte(source[offset+1:], '`')
+			if end == -1 {
+				return nil, newParseError(source, offset, "unterminated quoted column name")
+			}
+			tokens = append(tokens, token{kind: tokenIdentifier, text: source[offset+1 : offset+1+end], offset: offset})
+			offset += end + 2
+		case strings.ContainsRune("=!<>&|", char):
+			operator := lexOperator(source[offset:])
+			if operator == "" {
+				return nil, newParseError(source, offset, "unknown operator \""+string(char)+"\"")
+			}
+			tokens = append(tokens, token{kind: tokenOperator, text: operator, offset: offset})
+			offset += len(operator)
+		case char == '-' || char == '.' || unicode.IsDigit(char):
+			end := offset + 1
+			for end < len(source) && (isDigit(source[end]) || source[end] == '.' || source[end] == 'e' || source[end] == 'E') {
+				end++
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: source[offset:end], offset: offset})
+			offset = end
+		case char == '_' || unicode.IsLetter(char):
+			end := offset
+			for end < len(source) {
+				next, nextWidth := utf8.DecodeRuneInString(source[end:])
+				if next != '_' && next != '-' && next != '.' && !unicode.IsLetter(next) && !unicode.IsDigit(next) {
+					break
+				}
+				end += nextWidth
+			}
+			word := source[offset:end]
+			if keyword, isKeyword := keywords[strings.ToLower(word)]; isKeyword {
+				tokens = append(tokens, token{kind: tokenOperator, text: keyword, offset: offset})
+			} else {
+				tokens = append(tokens, token{kind: tokenIdentifier, text: word, offset: offset})
+			}
+			offset = end
+		default:
+			return nil, newParseError(source, offset, "unexpected character \""+string(char)+"\"")
+		}
+	}
+	return append(tokens, token{kind: tokenEOF, offset: len(source)}), nil
+}
+
+// lexOperator returns the longest symbolic operator at the start of `source`, or an empty string if there's none
+func lexOperator(source string) string {
+	for _, operator := range []string{"==", "!=", "<=", ">=", "=~", "!~", "&&", "||", "<", ">", "!"} {
+		if strings.HasPrefix(source, operator) {
+			return operator
+		}
+	}
+	return ""
+}
+
+// lexString reads the string literal starting at `start`, supporting backslash escapes for quotes and backslashes,
+// any other backslash is kept as is, so regular expressions can be written without double escaping.
+// It returns the unquoted string and the offset right after the closing quote
+func lexString(source string, start int) (text string, end int, err error) {
+	quote := source[start]
+	var builder strings.Builder
+	for i := start + 1; i < len(source); i++ {
+		switch source[i] {
+		case '\\':
+			if i+1 < len(source) && (source[i+1] == quote || source[i+1] == '\\') {
+				i++
+			}
+			builder.WriteByte(source[i])
+		case quote:
+			return builder.String(), i + 1, nil
+		default:
+			builder.WriteByte(source[i])
+		}
+	}
+	return "", 0, newParseError(source, start, "unterminated string")
+}
+
+func isDigit(char byte) bool {
+	return char >= '0' && char <= '9'
+}
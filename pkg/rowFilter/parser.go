@@ -0,0 +1,224 @@
+package rowFilter
+
+import (
+	"net"
+	"regexp"
+	"strconv"
+)
+
+// parser is a recursive descent parser for the grammar below, lowest precedence first:
+//
+//	expression := and { ("or" | "||") and }
+//	and        := unary { ("and" | "&&") unary }
+//	unary      := ("not" | "!") unary | "(" expression ")" | comparison
+//	comparison := column operator operand
+type parser struct {
+	source   string
+	tokens   []token
+	position int
+	columns  []string
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.position]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.position]
+	if t.kind != tokenEOF {
+		p.position++
+	}
+	return t
+}
+
+func (p *parser) isOperator(operators ...string) bool {
+	t := p.peek()
+	if t.kind != tokenOperator {
+		return false
+	}
+	for _, operator := range operators {
+		if t.text == operator {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *parser) errorAt(t token, message string) *ParseError {
+	return newParseError(p.source, t.offset, message)
+}
+
+func (p *parser) parseExpression() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOperator("or", "||") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orNode{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOperator("and", "&&") {
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = andNode{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if p.isOperator("not", "!") {
+		p.next()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{operand: operand}, nil
+	}
+	if p.peek().kind == tokenLeftParen {
+		p.next()
+		inner, err := p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.kind != tokenRightParen {
+			return nil, p.errorAt(closing, "expected \")\" but found "+closing.describe())
+		}
+		return inner, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (node, error) {
+	column := p.next()
+	if column.kind != tokenIdentifier {
+		return nil, p.errorAt(column, "expected a column name but found "+column.describe())
+	}
+	comparison := &comparisonNode{slot: p.slotOf(column.text)}
+
+	operator := p.next()
+	if operator.kind != tokenOperator {
+		return nil, p.errorAt(operator, "expected an operator after column \""+column.text+"\" but found "+operator.describe())
+	}
+	comparison.operator = operator.text
+	if operator.text == "not" {
+		// `not in` and `not within` are negated versions of their operators
+		negated := p.next()
+		if negated.kind != tokenOperator || (negated.text != "in" && negated.text != "within") {
+			return nil, p.errorAt(negated, "expected \"in\" or \"within\" after \"not\" but found "+negated.describe())
+		}
+		comparison.operator = negated.text
+		comparison.negated = true
+	}
+
+	switch comparison.operator {
+	case "==", "!=", "<", "<=", ">", ">=":
+		value, err := p.parseLiteral()
+		if err != nil {
+			return nil, err
+		}
+		comparison.literals = []literal{value}
+	case "=~", "!~":
+		pattern := p.next()
+		if pattern.kind != tokenString {
+			return nil, p.errorAt(pattern, "expected a quoted regular expression but found "+pattern.describe())
+		}
+		regex, err := regexp.Compile(pattern.text)
+		if err != nil {
+			return nil, p.errorAt(pattern, "invalid regular expression: "+err.Error())
+		}
+		comparison.regex = regex
+	case "in":
+		values, err := p.parseList()
+		if err != nil {
+			return nil, err
+		}
+		comparison.literals = values
+	case "within":
+		start := p.peek()
+		values, err := p.parseList()
+		if err != nil {
+			return nil, err
+		}
+		for _, value := range values {
+			_, network, err := net.ParseCIDR(value.text)
+			if err != nil {
+				return nil, p.errorAt(start, "invalid CIDR \""+value.text+"\"")
+			}
+			comparison.networks = append(comparison.networks, network)
+		}
+	default:
+		return nil, p.errorAt(operator, "operator "+operator.describe()+" can't be used in a comparison")
+	}
+	return comparison, nil
+}
+
+// parseList parses a parenthesized, comma separated list of literals, a single literal is also accepted
+func (p *parser) parseList() (values []literal, err error) {
+	if p.peek().kind != tokenLeftParen {
+		value, err := p.parseLiteral()
+		if err != nil {
+			return nil, err
+		}
+		return []literal{value}, nil
+	}
+	p.next()
+	for {
+		value, err := p.parseLiteral()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+
+		separator := p.next()
+		switch separator.kind {
+		case tokenComma:
+			continue
+		case tokenRightParen:
+			return values, nil
+		default:
+			return nil, p.errorAt(separator, "expected \",\" or \")\" but found "+separator.describe())
+		}
+	}
+}
+
+func (p *parser) parseLiteral() (literal, error) {
+	t := p.next()
+	switch t.kind {
+	case tokenString:
+		return literal{text: t.text}, nil
+	case tokenNumber:
+		number, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return literal{}, p.errorAt(t, "invalid number \""+t.text+"\"")
+		}
+		return literal{text: t.text, number: number, isNumber: true}, nil
+	default:
+		return literal{}, p.errorAt(t, "expected a quoted string or a number but found "+t.describe())
+	}
+}
+
+// slotOf returns the position of `column` in the columns referenced by the expression, adding it if needed
+func (p *parser) slotOf(column string) int {
+	for slot, existing := range p.columns {
+		if existing == column {
+			return slot
+		}
+	}
+	p.columns = append(p.columns, column)
+	return len(p.columns) - 1
+}